@@ -7,6 +7,7 @@ import (
 	"github.com/zooyer/gobox/box/cat"
 	"github.com/zooyer/gobox/box/echo"
 	"github.com/zooyer/gobox/box/pwd"
+	"github.com/zooyer/gobox/box/test"
 	"github.com/zooyer/gobox/types"
 )
 
@@ -16,6 +17,8 @@ var cmd = map[string]types.NewFunc{
 	"true":  bool.True,
 	"false": bool.False,
 	"pwd":   pwd.New,
+	"test":  test.New,
+	"[":     test.New,
 }
 
 func New(name string) types.NewFunc {
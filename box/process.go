@@ -2,6 +2,7 @@ package box
 
 import (
 	"os"
+	"slices"
 	"sync"
 	"syscall"
 
@@ -79,3 +80,18 @@ func (p *Process) Notify(c chan<- os.Signal, sig ...os.Signal) {
 		}
 	}
 }
+
+// StopNotify 取消 Notify 订阅的全部信号，返回后不再向 c 发送信号
+func (p *Process) StopNotify(c chan<- os.Signal) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for s, channels := range p.signal {
+		channels = slices.DeleteFunc(channels, func(channel chan<- os.Signal) bool { return channel == c })
+		if len(channels) == 0 {
+			delete(p.signal, s)
+		} else {
+			p.signal[s] = channels
+		}
+	}
+}
@@ -55,3 +55,29 @@ func TestProcess_Run(t *testing.T) {
 	p.Main()
 	time.Sleep(1 * time.Second)
 }
+
+func TestProcess_StopNotify(t *testing.T) {
+	var (
+		p   Process
+		ch1 = make(chan os.Signal, 1)
+		ch2 = make(chan os.Signal, 1)
+	)
+
+	p.Notify(ch1, syscall.SIGINT, syscall.SIGTERM)
+	p.Notify(ch2, syscall.SIGINT)
+	p.StopNotify(ch1)
+
+	// 取消订阅后发送信号不会阻塞在已满的通道上
+	p.Signal(syscall.SIGTERM)
+	p.Signal(syscall.SIGINT)
+
+	select {
+	case sig := <-ch1:
+		t.Fatalf("unexpected signal: %v", sig)
+	default:
+	}
+
+	if sig := <-ch2; sig != syscall.SIGINT {
+		t.Fatalf("expected: %v, got: %v", syscall.SIGINT, sig)
+	}
+}
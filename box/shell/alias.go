@@ -0,0 +1,164 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const aliasUsage = `alias: alias [-p] [name[=value] ... ]
+    Define or display aliases.
+
+    Without arguments, 'alias' prints the list of aliases in the reusable
+    form 'alias NAME=VALUE' on standard output.
+
+    Otherwise, an alias is defined for each NAME whose VALUE is given.
+    A trailing space in VALUE causes the next word to be checked for
+    alias substitution when the alias is expanded.
+
+    Options:
+      -p	print all defined aliases in a reusable format
+
+    Exit Status:
+    alias returns true unless a NAME is supplied for which no alias has been
+    defined.
+`
+
+const unaliasUsage = `unalias: unalias [-a] name [name ...]
+    Remove each NAME from the list of defined aliases.
+
+    Options:
+      -a	remove all alias definitions
+
+    Return success unless a NAME is not an existing alias.
+`
+
+// isAliasName 是否为合法的别名
+func isAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n|&;()<>'\"`\\$=/")
+}
+
+// displayAlias 输出可作为 shell 输入的 alias 命令
+func displayAlias(opt types.Option, name, value string) {
+	_, _ = fmt.Fprintf(opt.Stdout, "alias %s='%s'\n", name, strings.ReplaceAll(value, "'", `'\''`))
+}
+
+// expandAliases 是否展开别名：交互式 shell、POSIX 模式或设置了 expand_aliases
+func (sh *Gosh) expandAliases() bool {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	return sh.interactive || sh.posix || sh.shopts["expand_aliases"]
+}
+
+// lookupAlias 查找别名，未启用别名展开时返回 false
+func (sh *Gosh) lookupAlias(name string) (value string, ok bool) {
+	if !sh.expandAliases() {
+		return
+	}
+
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	value, ok = sh.aliases[name]
+
+	return
+}
+
+// alias 定义或输出别名
+func (sh *Gosh) alias(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, aliasUsage)
+		return
+	}
+
+	var display bool
+
+	args, err := parseOptions(args[1:], "p", func(option byte, value string) error {
+		display = true
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("alias: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, aliasUsage)
+		return 2
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if display || len(args) == 0 {
+		for _, name := range slices.Sorted(maps.Keys(sh.aliases)) {
+			displayAlias(opt, name, sh.aliases[name])
+		}
+	}
+
+	for _, arg := range args {
+		var name, value, assign = strings.Cut(arg, "=")
+
+		if !assign {
+			if value, exists := sh.aliases[name]; exists {
+				displayAlias(opt, name, value)
+			} else {
+				writeError(opt, fmt.Errorf("alias: %s: not found", name))
+				code = 1
+			}
+			continue
+		}
+
+		if !isAliasName(name) {
+			writeError(opt, fmt.Errorf("alias: `%s': invalid alias name", name))
+			code = 1
+			continue
+		}
+
+		sh.aliases[name] = value
+	}
+
+	return
+}
+
+// unalias 删除别名
+func (sh *Gosh) unalias(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, unaliasUsage)
+		return
+	}
+
+	var all bool
+
+	args, err := parseOptions(args[1:], "a", func(option byte, value string) error {
+		all = true
+		return nil
+	})
+	if err != nil || !all && len(args) == 0 {
+		if err != nil {
+			writeError(opt, fmt.Errorf("unalias: %w", err))
+		}
+		_, _ = fmt.Fprint(opt.Stderr, unaliasUsage)
+		return 2
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if all {
+		clear(sh.aliases)
+		return
+	}
+
+	for _, name := range args {
+		if _, exists := sh.aliases[name]; !exists {
+			writeError(opt, fmt.Errorf("unalias: %s: not found", name))
+			code = 1
+			continue
+		}
+
+		delete(sh.aliases, name)
+	}
+
+	return
+}
@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	var tests = []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{
+			name:     "expand",
+			script:   "alias say='echo hello'\nsay world",
+			expected: "hello world\n",
+		},
+		{
+			name:     "same line",
+			script:   "alias say='echo hello'; say world",
+			expected: "hello world\n",
+		},
+		{
+			name:     "recursive",
+			script:   "alias echo='echo x'\necho y",
+			expected: "x y\n",
+		},
+		{
+			name:     "mutual",
+			script:   "alias a=b b=a\na; echo $?",
+			expected: "shell: a: command not found\n127\n",
+		},
+		{
+			name:     "chain",
+			script:   "alias run='command ' say='echo hi'\nalias command=\nrun say",
+			expected: "hi\n",
+		},
+		{
+			name:     "argument",
+			script:   "alias say='echo hi'\necho say",
+			expected: "say\n",
+		},
+		{
+			name:     "quoted",
+			script:   "alias say='echo hi'\n'say'; \\say",
+			expected: "shell: say: command not found\nshell: say: command not found\n",
+		},
+		{
+			name:     "operators",
+			script:   "alias both='echo a && echo b'\nboth; x=1 both",
+			expected: "a\nb\na\nb\n",
+		},
+		{
+			name:     "list",
+			script:   "alias b='echo it'\\''s' a=ls\nalias; alias b",
+			expected: "alias a='ls'\nalias b='echo it'\\''s'\nalias b='echo it'\\''s'\n",
+		},
+		{
+			name:     "unalias",
+			script:   "alias a=ls b=ls\nunalias a; alias; unalias -a; alias; unalias x",
+			expected: "alias b='ls'\nshell: unalias: x: not found\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			sh.shopts["expand_aliases"] = true
+
+			_, _ = sh.Run(strings.NewReader(test.script), sh.Option)
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestAliasDisabled(t *testing.T) {
+	var sh, stdout = newTestGosh()
+
+	var script = "alias say='echo hi'\nsay\nshopt expand_aliases; shopt -s expand_aliases\nsay; shopt expand_aliases"
+	_, _ = sh.Run(strings.NewReader(script), sh.Option)
+
+	var expected = "shell: say: command not found\nexpand_aliases \toff\nhi\nexpand_aliases \ton\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %q, got: %q", expected, stdout.String())
+	}
+}
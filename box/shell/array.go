@@ -0,0 +1,513 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseSubscript 拆分数组元素 `name[subscript]`
+func parseSubscript(s string) (name, subscript string, ok bool) {
+	var i = strings.IndexByte(s, '[')
+	if i <= 0 || !strings.HasSuffix(s, "]") || !isName(s[:i]) {
+		return
+	}
+
+	return s[:i], s[i+1 : len(s)-1], true
+}
+
+// isAssignName 是否为赋值的左侧：name 或 name[subscript]，带 `+` 时为追加
+func isAssignName(s string) bool {
+	s = strings.TrimSuffix(s, "+")
+	if isName(s) {
+		return true
+	}
+
+	var _, _, ok = parseSubscript(s)
+
+	return ok
+}
+
+// assignBase 赋值左侧的变量名
+func assignBase(s string) string {
+	s = strings.TrimSuffix(s, "+")
+	if name, _, ok := parseSubscript(s); ok {
+		return name
+	}
+
+	return s
+}
+
+// isArrayAssign 单词是否为数组赋值 `name=(` 或 `name+=(` 中 `(` 之前的部分
+func isArrayAssign(s string) bool {
+	var name, ok = strings.CutSuffix(s, "=")
+
+	return ok && isName(strings.TrimSuffix(name, "+"))
+}
+
+// clone 复制变量，修改副本的数组不影响原变量
+func (v *Variable) clone() *Variable {
+	var copied = *v
+	copied.Array = slices.Clone(v.Array)
+	copied.Index = slices.Clone(v.Index)
+	copied.Assoc = maps.Clone(v.Assoc)
+
+	return &copied
+}
+
+// indexAt 索引数组中第 i 个元素的下标
+func (v *Variable) indexAt(i int) int {
+	if v.Index == nil {
+		return i
+	}
+
+	return v.Index[i]
+}
+
+// nextIndex 追加元素时的下标，即最大的下标加一
+func (v *Variable) nextIndex() int {
+	if len(v.Array) == 0 {
+		return 0
+	}
+
+	return v.indexAt(len(v.Array)-1) + 1
+}
+
+// element 索引数组中下标为 index 的元素，普通变量的值为下标 0 的元素
+func (v *Variable) element(index int) (value string, ok bool) {
+	switch {
+	case v.Array == nil:
+		return v.Value, index == 0
+	case v.Index == nil:
+		if index >= 0 && index < len(v.Array) {
+			return v.Array[index], true
+		}
+		return
+	}
+
+	if i, found := slices.BinarySearch(v.Index, index); found {
+		return v.Array[i], true
+	}
+
+	return
+}
+
+// sparse 将下标连续的索引数组转换为记录下标的形式
+func (v *Variable) sparse() {
+	if v.Index != nil {
+		return
+	}
+
+	v.Index = make([]int, len(v.Array))
+	for i := range v.Index {
+		v.Index[i] = i
+	}
+}
+
+// setElement 设置索引数组中下标为 index 的元素，普通变量转换为数组
+func (v *Variable) setElement(index int, value string) {
+	if v.Array == nil {
+		v.Array, v.Value = []string{v.Value}, ""
+	}
+
+	if v.Index == nil {
+		switch {
+		case index < len(v.Array):
+			v.Array[index] = value
+			return
+		case index == len(v.Array):
+			v.Array = append(v.Array, value)
+			return
+		}
+		v.sparse()
+	}
+
+	var i, found = slices.BinarySearch(v.Index, index)
+	if found {
+		v.Array[i] = value
+		return
+	}
+
+	v.Index, v.Array = slices.Insert(v.Index, i, index), slices.Insert(v.Array, i, value)
+}
+
+// unsetElement 删除索引数组中下标为 index 的元素
+func (v *Variable) unsetElement(index int) {
+	if v.Array == nil {
+		v.Array = []string{v.Value}
+	}
+
+	if v.Index == nil {
+		switch {
+		case index < 0 || index >= len(v.Array):
+			return
+		case index == len(v.Array)-1:
+			v.Array = v.Array[:index]
+			return
+		}
+		v.sparse()
+	}
+
+	if i, found := slices.BinarySearch(v.Index, index); found {
+		v.Index, v.Array = slices.Delete(v.Index, i, i+1), slices.Delete(v.Array, i, i+1)
+	}
+}
+
+// keys 数组的全部下标，关联数组按下标排序，普通变量只有下标 0
+func (v *Variable) keys() (keys []string) {
+	switch {
+	case v.Assoc != nil:
+		return slices.Sorted(maps.Keys(v.Assoc))
+	case v.Array == nil:
+		return []string{"0"}
+	}
+
+	for i := range v.Array {
+		keys = append(keys, strconv.Itoa(v.indexAt(i)))
+	}
+
+	return
+}
+
+// values 数组的全部元素，顺序与 keys 相同
+func (v *Variable) values() (values []string) {
+	switch {
+	case v.Assoc != nil:
+		for _, key := range v.keys() {
+			values = append(values, v.Assoc[key])
+		}
+		return
+	case v.Array == nil:
+		return []string{v.Value}
+	}
+
+	return slices.Clone(v.Array)
+}
+
+// evalInt 计算整数表达式：展开后为整数，或为值为整数的变量名，空值为 0
+func (sh *Gosh) evalInt(expr string) (n int, err error) {
+	var s string
+	if s, err = sh.expandString(expr); err != nil {
+		return
+	}
+
+	if s = strings.TrimSpace(s); isName(s) {
+		s = strings.TrimSpace(sh.getenv(s))
+	}
+
+	if s == "" {
+		return 0, nil
+	}
+
+	if n, err = strconv.Atoi(s); err != nil {
+		return 0, fmt.Errorf("%s: syntax error: operand expected", s)
+	}
+
+	return
+}
+
+// isAssoc 变量是否为关联数组
+func (sh *Gosh) isAssoc(name string) bool {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	var v = sh.vars[name]
+
+	return v != nil && v.Assoc != nil
+}
+
+// subscript 计算数组下标：关联数组的下标为展开后的字符串，索引数组的下标为整数表达式
+func (sh *Gosh) subscript(name, sub string) (key string, index int, assoc bool, err error) {
+	if assoc = sh.isAssoc(name); assoc {
+		key, err = sh.expandString(sub)
+		return
+	}
+
+	index, err = sh.evalInt(sub)
+
+	return
+}
+
+// lookupElement 获取数组元素，下标为 @ 或 * 时以空格连接全部元素
+func (sh *Gosh) lookupElement(name, sub string) (value string, set bool) {
+	if sub == "@" || sub == "*" {
+		var values []string
+		values, set = sh.arrayValues(name)
+		return strings.Join(values, " "), set
+	}
+
+	var key, index, assoc, err = sh.subscript(name, sub)
+	if err != nil {
+		return
+	}
+
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	var v = sh.vars[name]
+	switch {
+	case v == nil:
+		return
+	case assoc:
+		value, set = v.Assoc[key]
+		return
+	case index < 0:
+		index += v.nextIndex()
+	}
+
+	return v.element(index)
+}
+
+// arrayValues 数组的全部元素，变量不存在或数组为空时 set 为 false
+func (sh *Gosh) arrayValues(name string) (values []string, set bool) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	if v := sh.vars[name]; v != nil {
+		values = v.values()
+	}
+
+	return values, len(values) > 0
+}
+
+// arrayKeys 数组的全部下标
+func (sh *Gosh) arrayKeys(name string) (keys []string) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	if v := sh.vars[name]; v != nil {
+		keys = v.keys()
+	}
+
+	return
+}
+
+// setElement 设置数组元素 name[sub]，add 为 true 时追加到原值之后
+func (sh *Gosh) setElement(name, sub, value string, add bool) (err error) {
+	if err = sh.restrictVar(name); err != nil {
+		return
+	}
+
+	var key, index, assoc, e = sh.subscript(name, sub)
+	if e != nil {
+		return e
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	var v = sh.vars[name]
+	if v == nil {
+		v = &Variable{Array: []string{}}
+		sh.vars[name] = v
+	}
+
+	if assoc {
+		if add {
+			value = v.Assoc[key] + value
+		}
+		v.Assoc[key] = value
+		return
+	}
+
+	if index < 0 {
+		if index += v.nextIndex(); index < 0 {
+			return fmt.Errorf("%s[%s]: bad array subscript", name, unmark(sub))
+		}
+	}
+
+	if add {
+		var old, _ = v.element(index)
+		value = old + value
+	}
+
+	v.setElement(index, value)
+
+	return
+}
+
+// unsetElement 删除数组元素 name[sub]，下标为 @ 或 * 时删除整个数组
+func (sh *Gosh) unsetElement(name, sub string) (err error) {
+	if sub == "@" || sub == "*" {
+		sh.unsetvar(name)
+		return
+	}
+
+	var key, index, assoc, e = sh.subscript(name, sub)
+	if e != nil {
+		return e
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	var v = sh.vars[name]
+	switch {
+	case v == nil:
+	case assoc:
+		delete(v.Assoc, key)
+	default:
+		if index < 0 {
+			if index += v.nextIndex(); index < 0 {
+				return fmt.Errorf("%s[%s]: bad array subscript", name, sub)
+			}
+		}
+		v.unsetElement(index)
+	}
+
+	return
+}
+
+// splitElements 拆分数组赋值中的元素，转义的标记不作为分隔
+func splitElements(word string) (elements []string) {
+	word = strings.TrimPrefix(word, string(markArray))
+
+	var start int
+	for i := 0; i < len(word); i++ {
+		switch word[i] {
+		case markEscape:
+			i++
+		case markArray:
+			elements, start = append(elements, word[start:i]), i+1
+		}
+	}
+
+	return append(elements, word[start:])
+}
+
+// parseKeyed 拆分数组赋值中 `[key]=value` 形式的元素
+func parseKeyed(element string) (key, value string, ok bool) {
+	if !strings.HasPrefix(element, "[") {
+		return
+	}
+
+	for i := 1; i < len(element); i++ {
+		switch element[i] {
+		case markEscape:
+			i++
+		case ']':
+			if i+1 < len(element) && element[i+1] == '=' {
+				return element[1:i], element[i+2:], true
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// assignArray 执行数组赋值 name=(...)，add 为 true 时追加到原数组
+func (sh *Gosh) assignArray(name, word string, add bool) (err error) {
+	if err = sh.restrictVar(name); err != nil {
+		return
+	}
+
+	sh.mutex.RLock()
+	var old = sh.vars[name]
+	if old != nil {
+		old = old.clone()
+	}
+	sh.mutex.RUnlock()
+
+	var result = &Variable{Array: []string{}}
+	switch {
+	case old != nil && add:
+		result = old
+		if result.Array == nil && result.Assoc == nil {
+			result.setElement(0, result.Value)
+		}
+	case old != nil && old.Assoc != nil:
+		result = &Variable{Assoc: make(map[string]string), Export: old.Export}
+	case old != nil:
+		result.Export = old.Export
+	}
+
+	var next = result.nextIndex()
+	for _, element := range splitElements(word) {
+		if key, value, ok := parseKeyed(element); ok {
+			if value, err = sh.expandString(value); err != nil {
+				return
+			}
+
+			if result.Assoc != nil {
+				if key, err = sh.expandString(key); err != nil {
+					return
+				}
+				result.Assoc[key] = value
+				continue
+			}
+
+			var index int
+			if index, err = sh.evalInt(key); err != nil {
+				return
+			}
+			if index < 0 {
+				if index += result.nextIndex(); index < 0 {
+					return fmt.Errorf("%s: [%s]: bad array subscript", name, unmark(key))
+				}
+			}
+
+			result.setElement(index, value)
+			next = index + 1
+			continue
+		}
+
+		if result.Assoc != nil {
+			return fmt.Errorf("%s: %s: must use subscript when assigning associative array", name, unmark(element))
+		}
+
+		var list []string
+		if list, err = sh.expand(element); err != nil {
+			return
+		}
+
+		for _, value := range list {
+			result.setElement(next, value)
+			next++
+		}
+	}
+
+	sh.mutex.Lock()
+	sh.vars[name] = result
+	sh.mutex.Unlock()
+
+	return
+}
+
+// assignVar 执行一个变量赋值，name 为赋值的左侧，word 为未展开的值
+func (sh *Gosh) assignVar(name, word string) (err error) {
+	var add bool
+	if name, add = strings.CutSuffix(name, "+"); add && !isAssignName(name) {
+		return fmt.Errorf("`%s+': not a valid identifier", name)
+	}
+
+	if base, sub, ok := parseSubscript(name); ok {
+		var value string
+		if value, err = sh.expandString(word); err != nil {
+			return
+		}
+		return sh.setElement(base, sub, value, add)
+	}
+
+	if !isName(name) {
+		return fmt.Errorf("`%s': not a valid identifier", name)
+	}
+
+	if strings.HasPrefix(word, string(markArray)) {
+		return sh.assignArray(name, word, add)
+	}
+
+	var value string
+	if value, err = sh.expandString(word); err != nil {
+		return
+	}
+
+	// 追加到普通变量或数组下标 0 的元素
+	if add {
+		value = sh.getenv(name) + value
+	}
+
+	return sh.setvar(name, value)
+}
@@ -0,0 +1,101 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestArray(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			// 数组赋值与元素展开
+			script:   `a=(x "y z" w); echo ${a[1]} ${a[-1]} $a ${#a[@]} ${#a[1]}`,
+			expected: "y z w x 3 3\n",
+		},
+		{
+			// "${a[@]}" 每个元素为独立字段，"${a[*]}" 以 IFS 连接，引号外参与字段分割
+			script:   `a=(x "y z" ''); printf '<%s>' "${a[@]}"; echo; printf '<%s>' "${a[*]}"; echo; printf '<%s>' ${a[@]}; echo`,
+			expected: "<x><y z><>\n<x y z >\n<x><y><z>\n",
+		},
+		{
+			// 空数组展开为零个字段
+			script:   `a=(); printf '<%s>' x "${a[@]}" y; echo ${#a[@]}`,
+			expected: "<x><y>0\n",
+		},
+		{
+			// 追加与截取
+			script:   `a=(a b); a+=(c d); echo "${a[@]:1:2}" "${a[@]:2}" "${a[@]: -1}"`,
+			expected: "b c c d d\n",
+		},
+		{
+			// 删除元素后下标不连续
+			script:   `a=(a b c); unset 'a[1]'; echo ${!a[@]} ${a[@]}; a[5]=f; echo ${!a[@]}; unset a; echo ${#a[@]}`,
+			expected: "0 2 a c\n0 2 5\n0\n",
+		},
+		{
+			// 元素赋值、下标变量和追加到元素
+			script:   `i=2; a[i]=x; a[$i]+=y; a[0]=z; echo ${a[@]} ${a[i]}; s=v; s[1]=w; echo ${s[@]}`,
+			expected: "z xy xy\nv w\n",
+		},
+		{
+			// 多行数组赋值，注释忽略，元素中的引号和展开
+			script:   "v=\"1 2\"\na=( # hosts\n  $v \"$v\" \\\n  '$v' [5]=q\n)\necho ${#a[@]} ${a[2]} ${a[3]} ${a[5]}",
+			expected: "5 1 2 $v q\n",
+		},
+		{
+			// 关联数组
+			script:   `declare -A m; m[key]=v; m[b]="x y"; echo ${!m[@]} ${m[key]} ${#m[@]}; printf '<%s>' "${m[@]}"; echo`,
+			expected: "b key v 2\n<x y><v>\n",
+		},
+		{
+			script:   `declare -A m=([k]=1 [j]=2); unset 'm[k]'; declare -p m; declare m=(x); echo $?`,
+			expected: "declare -A m=([j]=\"2\" )\nshell: declare: m: x: must use subscript when assigning associative array\n1\n",
+		},
+		{
+			// declare 的赋值不再次展开参数的值
+			script:   `v='$HOME'; declare -a a=("$v" b); declare x=$v; declare -p a x; declare -x x; declare -p x`,
+			expected: "declare -a a=([0]=\"\\$HOME\" [1]=\"b\")\ndeclare -- x=\"\\$HOME\"\ndeclare -x x=\"\\$HOME\"\n",
+		},
+		{
+			script:   `a=(1); declare -A a; echo $?; declare -p nope; echo $?`,
+			expected: "shell: declare: a: cannot convert indexed to associative array\n1\nshell: declare: nope: not found\n1\n",
+		},
+		{
+			// 位置参数的截取和个数
+			script:   `set -- a b c; echo ${@:2} ${@:1:1} ${#@}`,
+			expected: "b c a 3\n",
+		},
+		{
+			// 字符串截取
+			script:   `s=hello; echo ${s:1:3} ${s: -2} ${s:1:-1}`,
+			expected: "ell lo ell\n",
+		},
+		{
+			// 对每个元素去除前后缀
+			script:   `a=(a.go b.go); echo ${a[@]%.go} ${a[@]#?.}`,
+			expected: "a b go go\n",
+		},
+		{
+			// 子 shell 中的修改不影响当前 shell
+			script:   `a=(1 2); cat <(a[0]=x; echo ${a[@]}) /dev/null; echo ${a[@]}`,
+			expected: "x 2\n1 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
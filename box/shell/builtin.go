@@ -1,18 +1,71 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
-	"testing"
 
 	"github.com/zooyer/gobox/types"
 )
 
+// parseOptions 解析内置命令的短选项，spec 中后跟 `:` 的选项需要参数，返回剩余参数
+func parseOptions(args []string, spec string, handle func(option byte, value string) error) (rest []string, err error) {
+	var i int
+	for ; i < len(args); i++ {
+		var arg = args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		for j := 1; j < len(arg); j++ {
+			var (
+				c     = arg[j]
+				index = strings.IndexByte(spec, c)
+			)
+
+			if c == ':' || index < 0 {
+				return nil, fmt.Errorf("-%c: invalid option", c)
+			}
+
+			if index+1 >= len(spec) || spec[index+1] != ':' {
+				if err = handle(c, ""); err != nil {
+					return
+				}
+				continue
+			}
+
+			// 选项参数可以紧跟选项或作为下一个参数
+			var value = arg[j+1:]
+			if value == "" {
+				if i++; i >= len(args) {
+					return nil, fmt.Errorf("-%c: option requires an argument", c)
+				}
+				value = args[i]
+			}
+
+			if err = handle(c, value); err != nil {
+				return
+			}
+
+			break
+		}
+	}
+
+	return args[i:], nil
+}
+
 func expandHome(path string) (_ string, err error) {
 	if !strings.HasPrefix(path, "~") {
 		return path, nil
@@ -40,177 +93,551 @@ func expandHome(path string) (_ string, err error) {
 	return dir, nil
 }
 
-func expandLast(opt types.Option, path string) (_ string, err error) {
-	if !strings.HasPrefix(path, "-") {
-		return path, nil
-	}
+const exitUsage = `exit: exit [n]
+    Exit the shell.
+    
+    Exits the shell with a status of N.  If N is omitted, the exit status
+    is that of the last command executed.
+`
+
+func Exit(opt types.Option, args []string) (code int) {
+	var err error
+
+	for _, arg := range args[1:] {
+		switch arg {
+		case "-h", "--help":
+			_, _ = fmt.Fprint(opt.Stdout, exitUsage)
 
-	var index int
-	if len(path) > 1 {
-		if index, err = strconv.Atoi(path[1:]); err != nil {
 			return
 		}
+
+		if code, err = strconv.Atoi(arg); err != nil {
+			writeError(opt, err)
+			return -1
+		}
 	}
 
-	// TODO 获取历史工作目录
-	_ = index
+	return
+}
 
-	var dir = os.Getenv("OLDPWD")
-	if dir == "" {
-		return ".", nil
+// exit 退出 shell，未指定退出码时使用上个命令的退出码
+func (sh *Gosh) exit(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		return Exit(opt, args)
 	}
 
-	return dir, nil
+	if code = sh.status; len(args) > 1 {
+		code = Exit(opt, args)
+	}
+
+	sh.exited = true
+
+	return
 }
 
-func compareDir(old, new string) (same bool, err error) {
-	if old, err = filepath.Abs(old); err != nil {
-		return
+const sourceUsage = `source: source filename [arguments]
+    Execute commands from a file in the current shell.
+    
+    Read and execute commands from FILENAME in the current shell.  The
+    entries in $PATH are used to find the directory containing FILENAME.
+    If any ARGUMENTS are supplied, they become the positional parameters
+    when FILENAME is executed.
+`
+
+// lookupSource 查找 source 的脚本，不含 `/` 时依次在 PATH 和当前目录中查找
+func (sh *Gosh) lookupSource(name string) string {
+	if strings.ContainsRune(name, '/') {
+		return name
 	}
 
-	if new, err = filepath.Abs(new); err != nil {
-		return
+	for _, dir := range filepath.SplitList(sh.getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+
+		var path = filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
 	}
 
-	return old == new, nil
+	return name
 }
 
-func Cd(opt types.Option, args []string) (code int) {
+// source 在当前 shell 中读取并执行脚本
+func (sh *Gosh) source(opt types.Option, args []string) (code int) {
+	if len(args) < 2 {
+		writeError(opt, fmt.Errorf("%s: filename argument required", args[0]))
+		_, _ = fmt.Fprint(opt.Stderr, sourceUsage)
+		return 2
+	}
+
+	switch args[1] {
+	case "-h", "--help":
+		_, _ = fmt.Fprint(opt.Stdout, sourceUsage)
+		return
+	}
+
 	var (
-		err      error
-		end      bool
-		set      bool
-		dir      string
-		escape   = true
-		logical  bool
-		physical bool
+		err  error
+		file *os.File
 	)
 
-	for _, arg := range args[1:] {
-		if end {
-			if set {
-				writeError(opt, fmt.Errorf("too many arguments"))
-				return 1
-			}
-			dir = arg
-			set = true
-			escape = false
-			continue
+	var path = sh.lookupSource(args[1])
+	if file, err = os.Open(path); err != nil {
+		writeError(opt, err)
+		return 1
+	}
+
+	// 脚本中的命令位于新的调用层，结束后恢复行号
+	defer sh.pushCall("source", path)()
+
+	defer func() {
+		if err = file.Close(); err != nil {
+			writeError(opt, err)
 		}
+	}()
 
-		switch arg {
-		case "--":
-			end = true
-		case "-P":
-			physical = true
-		case "-L":
-			logical = true
-		default:
-			if set {
-				writeError(opt, fmt.Errorf("too many arguments"))
-				return 1
-			}
-			dir = arg
-			set = true
-			escape = true
+	// 指定参数时临时替换位置参数
+	if len(args) > 2 {
+		var saved = sh.args
+		sh.setArgs(args[2:])
+		defer func() { sh.setArgs(saved) }()
+	}
+
+	code, _ = sh.Run(file, opt)
+
+	// 脚本执行结束时执行 RETURN trap
+	sh.status = code
+	sh.runTrap(trapReturn)
+
+	return
+}
+
+// eval 将参数拼接为命令，在当前 shell 中重新解析并执行
+func (sh *Gosh) eval(opt types.Option, args []string) (code int) {
+	if len(args) < 2 {
+		return
+	}
+
+	code, _ = sh.Run(strings.NewReader(strings.Join(args[1:], " ")), opt)
+
+	return
+}
+
+const shiftUsage = `shift: shift [n]
+    Shift positional parameters.
+
+    Rename the positional parameters $N+1,$N+2 ... to $1,$2 ...  If N is
+    not given, it is assumed to be 1.
+`
+
+// shift 左移位置参数
+func (sh *Gosh) shift(opt types.Option, args []string) (code int) {
+	var n = 1
+
+	if len(args) > 1 {
+		switch args[1] {
+		case "-h", "--help":
+			_, _ = fmt.Fprint(opt.Stdout, shiftUsage)
+			return
+		}
+
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil {
+			writeError(opt, fmt.Errorf("shift: %s: numeric argument required", args[1]))
+			return 1
+		}
+
+		if n < 0 {
+			writeError(opt, fmt.Errorf("shift: %s: shift count out of range", args[1]))
+			return 1
 		}
 	}
 
-	if dir == "" {
-		dir = "~"
+	if n > len(sh.args) {
+		return 1
 	}
 
-	switch dir[0] {
-	case '-':
-		if escape {
-			if dir, err = expandLast(opt, dir); err != nil {
-				writeError(opt, err)
+	sh.setArgs(sh.args[n:])
+
+	return
+}
+
+const setUsage = `set: set [-r] [-o option-name] [--] [arg ...]
+    Set shell options and positional parameters.
+
+    Without arguments, display the names and values of shell variables.
+    Any arguments remaining after option processing are assigned to the
+    positional parameters $1, $2, ... $n.
+
+    Options:
+      -o option-name
+          Set the variable corresponding to option-name:
+              pipefail     the return value of a pipeline is the status of
+                           the last command to exit with a non-zero status,
+                           or zero if no command exited with a non-zero status
+      -r	Enable restricted mode.  Restricted mode cannot be disabled.
+      --	Assign any remaining arguments to the positional parameters.
+    		If there are no remaining arguments, the positional parameters
+    		are unset.
+      -	Assign any remaining arguments to the positional parameters.
+
+    Using + rather than - causes these flags to be turned off.
+`
+
+// setOptions set -o 支持的选项
+var setOptions = []string{"pipefail"}
+
+// set 设置选项和位置参数，无参数时输出全部变量
+func (sh *Gosh) set(opt types.Option, args []string) (code int) {
+	if len(args) < 2 {
+		sh.mutex.RLock()
+		var names = slices.Sorted(maps.Keys(sh.vars))
+		for _, name := range names {
+			_, _ = fmt.Fprintf(opt.Stdout, "%s=%s\n", name, quote(sh.vars[name].String()))
+		}
+		sh.mutex.RUnlock()
+		return
+	}
+
+	switch args[1] {
+	case "-h", "--help":
+		_, _ = fmt.Fprint(opt.Stdout, setUsage)
+		return
+	case "--", "-":
+		sh.setArgs(slices.Clone(args[2:]))
+		return
+	}
+
+	var index = 1
+	for ; index < len(args) && len(args[index]) > 1 && (args[index][0] == '-' || args[index][0] == '+'); index++ {
+		var arg = args[index]
+		if arg == "--" {
+			index++
+			break
+		}
+
+		for _, option := range arg[1:] {
+			switch {
+			case option == 'r' && arg[0] == '-':
+				sh.restricted = true
+			case option == 'r' && sh.restricted:
+				writeError(opt, fmt.Errorf("set: +r: restricted"))
+				return 1
+			case option == 'r':
+			case option == 'o' && index+1 >= len(args):
+				sh.listOptions(opt, arg[0] == '+')
+			case option == 'o':
+				if index++; !slices.Contains(setOptions, args[index]) {
+					writeError(opt, fmt.Errorf("set: %s: invalid option name", args[index]))
+					return 2
+				}
+				sh.options[args[index]] = arg[0] == '-'
+			default:
+				writeError(opt, fmt.Errorf("set: %c%c: invalid option", arg[0], option))
+				_, _ = fmt.Fprint(opt.Stderr, setUsage)
 				return 2
 			}
 		}
-	case '~':
-		if dir, err = expandHome(dir); err != nil {
-			writeError(opt, err)
-			return 3
-		}
 	}
 
-	_ = logical
-	if physical {
-		dir, err = filepath.EvalSymlinks(dir)
-		if err != nil {
-			writeError(opt, err)
-			return 4
+	// 选项之后有参数或以 -- 结束时设置位置参数
+	if index < len(args) || args[index-1] == "--" {
+		sh.setArgs(slices.Clone(args[index:]))
+	}
+
+	return
+}
+
+// listOptions 输出 set -o 的选项，reusable 为 true 时以 set 命令的形式输出
+func (sh *Gosh) listOptions(opt types.Option, reusable bool) {
+	for _, name := range setOptions {
+		switch {
+		case reusable && sh.options[name]:
+			_, _ = fmt.Fprintf(opt.Stdout, "set -o %s\n", name)
+		case reusable:
+			_, _ = fmt.Fprintf(opt.Stdout, "set +o %s\n", name)
+		case sh.options[name]:
+			_, _ = fmt.Fprintf(opt.Stdout, "%-15s\ton\n", name)
+		default:
+			_, _ = fmt.Fprintf(opt.Stdout, "%-15s\toff\n", name)
 		}
-	} else {
-		dir = filepath.Clean(dir)
 	}
+}
 
-	var old string
-	if old, err = os.Getwd(); err != nil {
-		writeError(opt, err)
-		return 5
+const shoptUsage = `shopt: shopt [-pqsu] [optname ...]
+    Set and unset shell options.
+
+    Change the setting of each shell option OPTNAME.  Without any option
+    arguments, list each supplied OPTNAME, or all shell options if no
+    OPTNAMEs are given, with an indication of whether or not each is set.
+
+    Options:
+      -p	print each shell option with an indication of its status
+      -q	suppress output
+      -s	enable (set) each OPTNAME
+      -u	disable (unset) each OPTNAME
+
+    Exit Status:
+    Returns success if OPTNAME is enabled; fails if an invalid option is
+    given or OPTNAME is disabled.
+`
+
+// shoptNames shopt 支持的选项
+var shoptNames = []string{
+	"expand_aliases",
+	"prefer_external",
+}
+
+// shopt 设置 shell 选项
+func (sh *Gosh) shopt(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, shoptUsage)
+		return
 	}
 
-	var same bool
-	if same, err = compareDir(old, dir); err != nil {
-		writeError(opt, err)
-		return 6
+	var display, quiet, set, unset bool
+
+	args, err := parseOptions(args[1:], "pqsu", func(option byte, value string) error {
+		switch option {
+		case 'p':
+			display = true
+		case 'q':
+			quiet = true
+		case 's':
+			set = true
+		case 'u':
+			unset = true
+		}
+		return nil
+	})
+	if err == nil && set && unset {
+		err = errors.New("cannot set and unset shell options simultaneously")
+	}
+	if err != nil {
+		writeError(opt, fmt.Errorf("shopt: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, shoptUsage)
+		return 2
 	}
-	if same {
+
+	for _, name := range args {
+		if !slices.Contains(shoptNames, name) {
+			writeError(opt, fmt.Errorf("shopt: %s: invalid shell option name", name))
+			return 1
+		}
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if (set || unset) && len(args) > 0 {
+		for _, name := range args {
+			sh.shopts[name] = set
+		}
 		return
 	}
 
-	if err = os.Chdir(dir); err != nil {
-		writeError(opt, err)
-		return 7
+	var names = args
+	if len(names) == 0 {
+		names = shoptNames
 	}
 
-	// TODO 保存到历史工作目录，相同目录则不保存
-	if err = os.Setenv("OLDPWD", old); err != nil {
-		writeError(opt, err)
-		return 8
+	for _, name := range names {
+		var on = sh.shopts[name]
+
+		// -s、-u 不带选项名时仅输出已设置或未设置的选项
+		if set && !on || unset && on {
+			continue
+		}
+
+		if !on {
+			code = 1
+		}
+
+		switch {
+		case quiet:
+		case display:
+			var flag = "-u"
+			if on {
+				flag = "-s"
+			}
+			_, _ = fmt.Fprintf(opt.Stdout, "shopt %s %s\n", flag, name)
+		default:
+			var status = "off"
+			if on {
+				status = "on"
+			}
+			_, _ = fmt.Fprintf(opt.Stdout, "%-15s\t%s\n", name, status)
+		}
 	}
 
-	if err = os.Setenv("PWD", dir); err != nil {
-		writeError(opt, err)
-		return 9
+	return
+}
+
+// quote 使用单引号转义包含特殊字符的值
+func quote(value string) string {
+	if value != "" && !strings.ContainsFunc(value, func(r rune) bool {
+		return !(r == '_' || r == '-' || r == '.' || r == '/' || r == ':' || r == ',' || r == '=' || r == '+' || r == '@' || r == '%' ||
+			r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) {
+		return value
 	}
 
-	return 0
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
 }
 
-const exitUsage = `exit: exit [n]
-    Exit the shell.
+const execUsage = `exec: exec [-cl] [-a name] [command [argument ...]] [redirection ...]
+    Replace the shell with the given command.
     
-    Exits the shell with a status of N.  If N is omitted, the exit status
-    is that of the last command executed.
+    Execute COMMAND, replacing this shell with the specified program.
+    ARGUMENTS become the arguments to COMMAND.  If COMMAND is not specified,
+    any redirections take effect in the current shell.
+    
+    Options:
+      -a name	pass NAME as the zeroth argument to COMMAND
+      -c	execute COMMAND with an empty environment
+      -l	place a dash in the zeroth argument to COMMAND
 `
 
-func Exit(opt types.Option, args []string) (code int) {
-	// TODO 获取上个命令的退出码
+// exec 以命令替换当前 shell，内置命令和内部命令在进程内执行后退出 shell
+func (sh *Gosh) exec(opt types.Option, args []string) (code int) {
+	var (
+		err   error
+		name  string
+		clear bool
+		login bool
+		index = 1
+	)
 
-	var err error
+	for ; index < len(args) && strings.HasPrefix(args[index], "-"); index++ {
+		var arg = args[index]
+		if arg == "--" {
+			index++
+			break
+		}
 
-	for _, arg := range args[1:] {
 		switch arg {
 		case "-h", "--help":
-			_, _ = fmt.Fprint(opt.Stdout, exitUsage)
-
+			_, _ = fmt.Fprint(opt.Stdout, execUsage)
 			return
+		case "-c":
+			clear = true
+		case "-l":
+			login = true
+		case "-cl", "-lc":
+			clear, login = true, true
+		case "-a":
+			if index++; index >= len(args) {
+				writeError(opt, errors.New("exec: -a: option requires an argument"))
+				return 2
+			}
+			name = args[index]
+		default:
+			writeError(opt, fmt.Errorf("exec: %s: invalid option", arg))
+			_, _ = fmt.Fprint(opt.Stderr, execUsage)
+			return 2
 		}
+	}
 
-		if code, err = strconv.Atoi(arg); err != nil {
+	// 仅有重定向时已由 Exec 作用于当前 shell
+	if index >= len(args) {
+		return
+	}
+
+	var argv = slices.Clone(args[index:])
+	if name != "" {
+		argv[0] = name
+	}
+	if login {
+		argv[0] = "-" + argv[0]
+	}
+
+	if clear {
+		opt.Env = []string{}
+	}
+
+	// 与执行命令时相同，按 PATH 变量和 hash 表查找
+	switch command, applet := args[index], sh.applet(args[index]); {
+	case sh.Builtin[command] != nil:
+		code = sh.Builtin[command](opt, argv)
+	case applet != nil:
+		code = applet(opt).Main(argv)
+	default:
+		var path string
+		if path, err = sh.lookPath(command); err != nil {
 			writeError(opt, err)
-			return -1
+			return 127
 		}
-	}
 
-	if testing.Testing() {
-		syscall.Exit(code)
+		var env = opt.Env
+		if env == nil {
+			env = os.Environ()
+		}
+
+		// 成功时不会返回
+		err = replace(opt, sh.files, path, argv, env)
+		writeError(opt, err)
+
+		return 126
 	}
 
-	os.Exit(code)
+	sh.exited = true
 
 	return
 }
+
+// replace 将标准输入输出和 exec 打开的文件描述符复制到对应位置后替换当前进程，
+// 先将全部来源复制到不与目标冲突的临时描述符，再按目标的顺序复制，避免覆盖尚未复制的来源
+func replace(opt types.Option, files map[int]*os.File, path string, argv, env []string) (err error) {
+	var sources = make(map[int]int)
+	for fd, stream := range map[int]any{0: opt.Stdin, 1: opt.Stdout, 2: opt.Stderr} {
+		if file, ok := stream.(*os.File); ok && file != nil {
+			sources[fd] = int(file.Fd())
+		}
+	}
+
+	for fd, file := range files {
+		if file != nil {
+			sources[fd] = int(file.Fd())
+		}
+	}
+
+	var above int
+	for fd, source := range sources {
+		above = max(above, fd+1, source+1)
+	}
+
+	// 临时描述符在 exec 时关闭
+	var temps = make(map[int]int, len(sources))
+	defer func() {
+		for _, temp := range temps {
+			_ = syscall.Close(temp)
+		}
+	}()
+
+	for fd, source := range sources {
+		if source == fd {
+			continue
+		}
+
+		if temps[fd], err = dupAbove(source, above); err != nil {
+			delete(temps, fd)
+			return
+		}
+	}
+
+	for _, fd := range slices.Sorted(maps.Keys(sources)) {
+		if temp, ok := temps[fd]; ok {
+			err = dup2(temp, fd)
+		} else {
+			err = clearCloexec(fd)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return syscall.Exec(path, argv, env)
+}
@@ -1,7 +1,12 @@
 package shell
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/zooyer/gobox/types"
@@ -80,3 +85,274 @@ func TestExit(t *testing.T) {
 		t.Fatal("Exit failed:", code)
 	}
 }
+
+func newTestGosh() (sh *Gosh, stdout *bytes.Buffer) {
+	stdout = new(bytes.Buffer)
+
+	sh = NewGosh(types.Option{
+		Env:    os.Environ(),
+		Stdin:  strings.NewReader(""),
+		Stdout: stdout,
+		Stderr: stdout,
+	})
+
+	return
+}
+
+func TestSource(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		script = filepath.Join(dir, "script.sh")
+	)
+
+	if err := os.WriteFile(script, []byte("echo hello\necho world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		env  []string
+		args []string
+	}{
+		{name: "path", args: []string{"source", script}},
+		{name: "dot", args: []string{".", script, "a", "b"}},
+		{name: "lookup", env: []string{"PATH=" + dir}, args: []string{"source", "script.sh"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			for _, env := range test.env {
+				name, value, _ := strings.Cut(env, "=")
+				if err := sh.setvar(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if code := sh.source(sh.Option, test.args); code != 0 {
+				t.Fatal("source failed:", code, stdout.String())
+			}
+
+			if stdout.String() != "hello\nworld\n" {
+				t.Fatalf("unexpected output: %q", stdout.String())
+			}
+
+			if sh.args != nil {
+				t.Fatal("positional parameters not restored:", sh.args)
+			}
+		})
+	}
+
+	var sh, _ = newTestGosh()
+	if code := sh.source(sh.Option, []string{"source"}); code != 2 {
+		t.Fatal("source without filename:", code)
+	}
+
+	if code := sh.source(sh.Option, []string{"source", filepath.Join(dir, "none.sh")}); code != 1 {
+		t.Fatal("source missing file:", code)
+	}
+}
+
+func TestEval(t *testing.T) {
+	var sh, stdout = newTestGosh()
+
+	if code := sh.eval(sh.Option, []string{"eval", "echo", "a;", "echo b"}); code != 0 {
+		t.Fatal("eval failed:", code)
+	}
+
+	if stdout.String() != "a\nb\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	if code := sh.eval(sh.Option, []string{"eval", "false"}); code != 1 {
+		t.Fatal("eval false:", code)
+	}
+}
+
+func TestExec(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		log  = filepath.Join(dir, "log.txt")
+		out  = filepath.Join(dir, "out.txt")
+		read = func(name string) string {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(data)
+		}
+	)
+
+	t.Run("fd", func(t *testing.T) {
+		var sh, stdout = newTestGosh()
+
+		var script = "exec 3> " + log + "\necho one >&3\necho two 1>&3\nexec 3>&-\necho done\n"
+		if code, err := sh.Run(strings.NewReader(script), sh.Option); code != 0 || err != nil {
+			t.Fatal("exec failed:", code, err)
+		}
+
+		if content := read(log); content != "one\ntwo\n" {
+			t.Fatalf("unexpected log: %q", content)
+		}
+
+		if stdout.String() != "done\n" {
+			t.Fatalf("unexpected output: %q", stdout.String())
+		}
+
+		if len(sh.files) != 0 || len(sh.opened) != 0 {
+			t.Fatal("fd 3 not closed:", sh.files, sh.opened)
+		}
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		var sh, stdout = newTestGosh()
+
+		if code, err := sh.Run(strings.NewReader("exec > "+out+"\necho a\necho b\n"), sh.Option); code != 0 || err != nil {
+			t.Fatal("exec failed:", code, err)
+		}
+
+		if content := read(out); content != "a\nb\n" {
+			t.Fatalf("unexpected output file: %q", content)
+		}
+
+		if stdout.Len() != 0 || sh.Option.Stdout != stdout {
+			t.Fatal("stdout not restored after run")
+		}
+	})
+
+	t.Run("command", func(t *testing.T) {
+		var sh, stdout = newTestGosh()
+
+		if code, err := sh.Run(strings.NewReader("exec echo hi; echo unreachable"), sh.Option); code != 0 || err != nil {
+			t.Fatal("exec failed:", code, err)
+		}
+
+		if stdout.String() != "hi\n" || !sh.exited {
+			t.Fatalf("shell not replaced: %q", stdout.String())
+		}
+
+		sh, _ = newTestGosh()
+		if code, _ := sh.Run(strings.NewReader("exec -a name false"), sh.Option); code != 1 || !sh.exited {
+			t.Fatal("exec false:", code)
+		}
+	})
+
+	t.Run("notfound", func(t *testing.T) {
+		var sh, _ = newTestGosh()
+
+		if code := sh.exec(sh.Option, []string{"exec", "gosh-command-not-found"}); code != 127 || sh.exited {
+			t.Fatal("exec not found:", code)
+		}
+	})
+}
+
+// execHelper 在子进程中执行 exec 的脚本，脚本中的 %d 为已打开文件的描述符
+func execHelper(path, script string) {
+	var file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		os.Exit(3)
+	}
+
+	var sh = NewGosh(types.Option{Env: os.Environ(), Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr})
+	sh.files = map[int]*os.File{int(file.Fd()): file}
+
+	_, _ = sh.Run(strings.NewReader(fmt.Sprintf(script, file.Fd())), sh.Option)
+
+	os.Exit(4)
+}
+
+func TestExecReplace(t *testing.T) {
+	if path := os.Getenv("GOSH_TEST_EXEC"); path != "" {
+		execHelper(path, os.Getenv("GOSH_TEST_SCRIPT"))
+		return
+	}
+
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "kept.txt")
+		bin  = filepath.Join(dir, "bin")
+	)
+
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "gosh-test-prog"), []byte("#!/bin/sh\necho found >&$1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name   string
+		script string
+		stdout string
+		stderr string
+		kept   string
+	}{
+		{
+			// 交换标准输出和标准错误，与来源相同的描述符在 exec 后保持打开
+			name:   "fds",
+			script: "exec 9>&1 1>&2 2>&9 sh -c 'echo out >&2; echo err; echo kept >&%d'",
+			stdout: "out\n",
+			stderr: "err\n",
+			kept:   "kept\n",
+		},
+		{
+			// 按 shell 的 PATH 变量查找
+			name:   "path",
+			script: "PATH=" + bin + ":$PATH; exec gosh-test-prog %d",
+			kept:   "found\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 多次执行，复制描述符的顺序固定
+			for range 5 {
+				var stdout, stderr bytes.Buffer
+
+				var cmd = exec.Command(os.Args[0], "-test.run=^TestExecReplace$")
+				cmd.Env = append(os.Environ(), "GOSH_TEST_EXEC="+path, "GOSH_TEST_SCRIPT="+test.script)
+				cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+				if err := cmd.Run(); err != nil {
+					t.Fatal(err, stderr.String())
+				}
+
+				if stdout.String() != test.stdout || stderr.String() != test.stderr {
+					t.Fatalf("unexpected output: %q, %q", stdout.String(), stderr.String())
+				}
+
+				if data, _ := os.ReadFile(path); string(data) != test.kept {
+					t.Fatalf("unexpected file: %q", data)
+				}
+				_ = os.Remove(path)
+			}
+		})
+	}
+}
+
+func TestShift(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{script: `set -- a b c; shift; echo $# "$@"`, expected: "2 b c\n"},
+		{script: `set -- a b c; shift 2; echo $# "$@"`, expected: "1 c\n"},
+		{script: `set -- a b; shift 3; echo $? $#`, expected: "1 2\n"},
+		{script: `set -- a b; shift x; echo $?`, expected: "shell: shift: x: numeric argument required\n1\n"},
+		{script: `set a "b c"; echo $1; echo $2; set --; echo $#`, expected: "a\nb c\n0\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal("run failed:", err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// astCommand 输出语法树的命令，单词移除展开标记，数组赋值输出为 astArray，
+// 其余字段与 Command 相同
+type astCommand struct {
+	Path any         `json:"path"`
+	Args []any       `json:"args,omitempty"`
+	Pipe *astCommand `json:"pipe,omitempty"`
+	Or   *astCommand `json:"or,omitempty"`
+	And  *astCommand `json:"and,omitempty"`
+	*Command
+}
+
+// astArray 语法树中的数组赋值 `name=(...)` 和 `name+=(...)`
+type astArray struct {
+	Name     string   `json:"array"`
+	Append   bool     `json:"append,omitempty"`
+	Elements []string `json:"elements"`
+}
+
+// astWord 语法树中的单词，数组赋值拆分为变量名和元素
+func astWord(word string) any {
+	var name, value, _ = strings.Cut(word, "=")
+	if !strings.HasPrefix(value, string(markArray)) || !isArrayAssign(name+"=") {
+		return unmark(word)
+	}
+
+	var array = astArray{Name: strings.TrimSuffix(name, "+"), Append: strings.HasSuffix(name, "+"), Elements: []string{}}
+	for _, element := range splitElements(value) {
+		if element != "" {
+			array.Elements = append(array.Elements, unmark(element))
+		}
+	}
+
+	return array
+}
+
+// ast 移除命令中单词的展开标记，用于输出语法树
+func (c *Command) ast() *astCommand {
+	if c == nil {
+		return nil
+	}
+
+	var command = *c
+
+	command.Input, command.Output, command.Append = unmark(c.Input), unmark(c.Output), unmark(c.Append)
+
+	command.Redirects = make([]Redirect, 0, len(c.Redirects))
+	for _, r := range c.Redirects {
+		r.Target = unmark(r.Target)
+		command.Redirects = append(command.Redirects, r)
+	}
+
+	var tree = astCommand{Path: astWord(c.Path), Pipe: c.Pipe.ast(), Or: c.Or.ast(), And: c.And.ast(), Command: &command}
+	for _, arg := range c.Args {
+		tree.Args = append(tree.Args, astWord(arg))
+	}
+
+	return &tree
+}
+
+// check 只解析不执行，报告全部语法错误，dump 为 true 时以 JSON 输出语法树
+func (sh *Gosh) check(input io.Reader, name string, dump bool) (code int) {
+	var commands, err = Parse(input)
+
+	for _, e := range syntaxErrors(err) {
+		_, _ = fmt.Fprintf(sh.Option.Stderr, "%s: %v\n", name, e)
+		code = 2
+	}
+
+	if !dump {
+		return
+	}
+
+	var tree = make([]*astCommand, 0, len(commands))
+	for i := range commands {
+		tree = append(tree, commands[i].ast())
+	}
+
+	var encoder = json.NewEncoder(sh.Option.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	if err = encoder.Encode(tree); err != nil {
+		writeError(sh.Option, err)
+		return 1
+	}
+
+	return
+}
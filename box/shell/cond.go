@@ -0,0 +1,296 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/zooyer/gobox/box/test"
+	"github.com/zooyer/gobox/types"
+)
+
+// condUnary `[[ ]]` 的单目运算符
+var condUnary = []string{"-a", "-b", "-c", "-d", "-e", "-f", "-g", "-h", "-k", "-L", "-n", "-p", "-r", "-s", "-S", "-u", "-v", "-w", "-x", "-z"}
+
+// condBinary `[[ ]]` 的双目运算符
+var condBinary = []string{"==", "=", "!=", "=~", "<", ">", "-eq", "-ne", "-lt", "-le", "-gt", "-ge", "-nt", "-ot", "-ef"}
+
+// errCondSyntax 条件表达式的语法错误
+var errCondSyntax = errors.New("syntax error in conditional expression")
+
+// cond 解析并计算 `[[ ]]` 的条件表达式，单词为未展开的原单词，
+// 只有未加引号的单词作为运算符，操作数只在需要时展开且不进行字段分割
+type cond struct {
+	sh    *Gosh
+	opt   types.Option
+	words []string
+	pos   int
+}
+
+// peek 下一个单词
+func (c *cond) peek() string {
+	if c.pos < len(c.words) {
+		return c.words[c.pos]
+	}
+
+	return ""
+}
+
+// next 读取下一个单词
+func (c *cond) next() (word string, err error) {
+	if c.pos >= len(c.words) {
+		return "", fmt.Errorf("%w: unexpected end of expression", errCondSyntax)
+	}
+
+	c.pos++
+
+	return c.words[c.pos-1], nil
+}
+
+// or expr || expr，eval 为 false 时只解析不计算
+func (c *cond) or(eval bool) (result bool, err error) {
+	if result, err = c.and(eval); err != nil {
+		return
+	}
+
+	for c.peek() == "||" {
+		c.pos++
+
+		var right bool
+		if right, err = c.and(eval && !result); err != nil {
+			return
+		}
+		result = result || right
+	}
+
+	return
+}
+
+// and expr && expr
+func (c *cond) and(eval bool) (result bool, err error) {
+	if result, err = c.not(eval); err != nil {
+		return
+	}
+
+	for c.peek() == "&&" {
+		c.pos++
+
+		var right bool
+		if right, err = c.not(eval && result); err != nil {
+			return
+		}
+		result = result && right
+	}
+
+	return
+}
+
+// not ! expr
+func (c *cond) not(eval bool) (result bool, err error) {
+	if c.peek() == "!" {
+		c.pos++
+		result, err = c.not(eval)
+		return !result, err
+	}
+
+	return c.primary(eval)
+}
+
+// primary ( expr )、单目运算、双目运算或单个字符串
+func (c *cond) primary(eval bool) (result bool, err error) {
+	var word string
+	if word, err = c.next(); err != nil {
+		return
+	}
+
+	switch {
+	case word == "(":
+		if result, err = c.or(eval); err != nil {
+			return
+		}
+		if word, err = c.next(); err == nil && word != ")" {
+			err = fmt.Errorf("%w: expected `)'", errCondSyntax)
+		}
+		return
+	case word == ")" || word == "&&" || word == "||":
+		return false, fmt.Errorf("%w: unexpected token `%s'", errCondSyntax, word)
+	case slices.Contains(condUnary, word) && c.pos < len(c.words) && !slices.Contains([]string{"&&", "||", ")"}, c.peek()):
+		var operand string
+		if operand, err = c.next(); err != nil || !eval {
+			return
+		}
+		if operand, err = c.sh.expandString(operand); err != nil {
+			return
+		}
+		return c.sh.unaryTest(c.opt, word, operand), nil
+	}
+
+	if op := c.peek(); slices.Contains(condBinary, op) {
+		c.pos++
+
+		var right string
+		if right, err = c.next(); err != nil || !eval {
+			return
+		}
+		return c.binary(word, op, right)
+	}
+
+	if !eval {
+		return
+	}
+
+	var value string
+	if value, err = c.sh.expandString(word); err != nil {
+		return
+	}
+
+	return value != "", nil
+}
+
+// binary 计算双目运算，== 和 != 的右侧为模式，=~ 的右侧为正则表达式
+func (c *cond) binary(leftWord, op, rightWord string) (result bool, err error) {
+	var left, right string
+	if left, err = c.sh.expandString(leftWord); err != nil {
+		return
+	}
+
+	switch op {
+	case "==", "=", "!=":
+		if right, err = c.sh.expandPattern(rightWord); err != nil {
+			return
+		}
+		return matchPattern(right, left) != (op == "!="), nil
+	case "=~":
+		if right, err = c.sh.expandQuoting(rightWord, regexp.QuoteMeta); err != nil {
+			return
+		}
+		return c.sh.matchRegexp(left, right)
+	}
+
+	if right, err = c.sh.expandString(rightWord); err != nil {
+		return
+	}
+
+	return c.sh.binaryTest(c.opt, left, op, right)
+}
+
+// matchRegexp 匹配正则表达式，匹配的内容和子表达式保存到 BASH_REMATCH
+func (sh *Gosh) matchRegexp(s, expr string) (matched bool, err error) {
+	var re *regexp.Regexp
+	if re, err = regexp.Compile(expr); err != nil {
+		return false, fmt.Errorf("%s: invalid regular expression", expr)
+	}
+
+	// 与 POSIX 相同，匹配最左最长的内容
+	re.Longest()
+
+	var match = re.FindStringSubmatch(s)
+	if err = sh.setArray("BASH_REMATCH", match); err != nil {
+		return
+	}
+
+	return match != nil, nil
+}
+
+// testPath 文件测试的路径，相对路径基于命令的工作目录
+func testPath(opt types.Option, path string) string {
+	if opt.Dir != "" && !filepath.IsAbs(path) {
+		return filepath.Join(opt.Dir, path)
+	}
+
+	return path
+}
+
+// unaryTest 计算单目运算
+func (sh *Gosh) unaryTest(opt types.Option, op, operand string) bool {
+	switch op {
+	case "-n":
+		return operand != ""
+	case "-z":
+		return operand == ""
+	case "-v":
+		var _, set = sh.lookup(operand)
+		return set
+	}
+
+	return test.FileTest(op, testPath(opt, operand))
+}
+
+// binaryTest 计算字符串比较、整数比较和文件比较
+func (sh *Gosh) binaryTest(opt types.Option, left, op, right string) (result bool, err error) {
+	switch op {
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-nt", "-ot", "-ef":
+		return test.FileCompare(testPath(opt, left), op, testPath(opt, right)), nil
+	}
+
+	var a, b int
+	if a, err = sh.evalInt(escapeWord(left)); err != nil {
+		return
+	}
+	if b, err = sh.evalInt(escapeWord(right)); err != nil {
+		return
+	}
+
+	return compareInt(a, op, b), nil
+}
+
+// compareInt 整数比较
+func compareInt(a int, op string, b int) bool {
+	switch op {
+	case "-eq":
+		return a == b
+	case "-ne":
+		return a != b
+	case "-lt":
+		return a < b
+	case "-le":
+		return a <= b
+	case "-gt":
+		return a > b
+	case "-ge":
+		return a >= b
+	}
+
+	return false
+}
+
+// test 内置的 test 和 [，与同名的 box 命令相同，相对路径基于命令的工作目录
+func (sh *Gosh) test(opt types.Option, args []string) int {
+	return test.New(opt).Main(args)
+}
+
+// conditional `[[ expr ]]` 条件表达式，参数为未展开的原单词
+func (sh *Gosh) conditional(opt types.Option, args []string) (code int) {
+	if len(args) < 2 || args[len(args)-1] != "]]" {
+		sh.writeError(opt, fmt.Errorf("%w: missing `]]'", errCondSyntax))
+		return 2
+	}
+
+	var c = &cond{sh: sh, opt: opt, words: args[1 : len(args)-1]}
+	if len(c.words) == 0 {
+		sh.writeError(opt, fmt.Errorf("%w: empty expression", errCondSyntax))
+		return 2
+	}
+
+	var result, err = c.or(true)
+	if err == nil && c.pos < len(c.words) {
+		err = fmt.Errorf("%w: unexpected token `%s'", errCondSyntax, unmark(c.peek()))
+	}
+
+	if err != nil {
+		sh.writeError(opt, err)
+		return 2
+	}
+
+	if !result {
+		return 1
+	}
+
+	return
+}
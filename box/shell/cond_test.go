@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditional(t *testing.T) {
+	var dir = t.TempDir()
+
+	// 相对路径基于工作目录
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "empty"), time.Now(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			// 右侧未加引号的部分为模式
+			script:   `x="hello world"; p='h*'; [[ $x == hello* ]]; echo $?; [[ $x == "hello*" ]]; echo $?; [[ hello == $p ]]; echo $?; [[ hello == "$p" ]]; echo $?; [[ $x != *d ]]; echo $?`,
+			expected: "0\n1\n0\n1\n1\n",
+		},
+		{
+			// 操作数不进行字段分割，引号内的运算符为字符串
+			script:   `x="a b"; [[ $x = "a b" ]]; echo $?; [[ "&&" == "&&" && ! -z $x ]]; echo $?; [[ $unset ]]; echo $?`,
+			expected: "0\n0\n1\n",
+		},
+		{
+			// 正则表达式和 BASH_REMATCH
+			script:   `[[ 2024-01-15 =~ ^([0-9]+)-([0-9]+)-([0-9]+)$ ]] && echo ${BASH_REMATCH[@]}; [[ foo =~ ^(bar|foo)$ ]]; echo $? ${#BASH_REMATCH[@]}; [[ x =~ y ]]; echo $? ${#BASH_REMATCH[@]}`,
+			expected: "2024-01-15 2024 01 15\n0 2\n1 0\n",
+		},
+		{
+			// 引号内的正则字符按字面量匹配
+			script:   `d=.; [[ a.b =~ "." ]]; echo $?; [[ ab =~ "." ]]; echo $?; [[ ab =~ $d ]]; echo $?; [[ ab =~ "$d" ]]; echo $?`,
+			expected: "0\n1\n0\n1\n",
+		},
+		{
+			// 逻辑运算、括号和换行
+			script:   "[[ ( 1 -lt 2 || a > b ) && ! -n \"\" ]]; echo $?\n[[ a == a &&\n  b < a ]]; echo $?",
+			expected: "0\n1\n",
+		},
+		{
+			// 短路时不展开右侧
+			script:   `[[ -z "" || ${x?unset} ]]; echo $?`,
+			expected: "0\n",
+		},
+		{
+			// 文件测试
+			script:   `[[ -f file && -s file && ! -s empty && -x empty && -d sub && ! -f sub && -L link && -e link && ! -e nope ]]; echo $?`,
+			expected: "0\n",
+		},
+		{
+			script:   `[[ file -nt empty && empty -ot file && link -ef file && ! sub -ef file ]]; echo $?`,
+			expected: "0\n",
+		},
+		{
+			// 语法错误的退出码为 2
+			script:   `[[ a -eq ]]; echo $?; [[ 1 -eq 1x ]]; echo $?; [[ x =~ ( ]]; echo $?; [[ x =~ "(" ]]; echo $?`,
+			expected: "shell: syntax error in conditional expression: unexpected end of expression\n2\nshell: 1x: syntax error: operand expected\n2\nshell: (: invalid regular expression\n2\n1\n",
+		},
+		{
+			script:   `[[ a && b ; echo $?; [[ a; echo $?`,
+			expected: "shell: syntax error in conditional expression: missing `]]'\n2\nshell: syntax error in conditional expression: missing `]]'\n2\n",
+		},
+		{
+			// 内置的 test 和 [ 同样基于工作目录
+			script:   `[ -f file ] && test -d sub -a ! -e nope; echo $?; [ a = b ]; echo $?; [ a; echo $?`,
+			expected: "0\n1\n[: missing `]'\n2\n",
+		},
+		{
+			script:   `type [[; [[ x ]] && echo yes`,
+			expected: "[[ is a shell keyword\nyes\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			sh.Option.Dir = dir
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestParseConditional(t *testing.T) {
+	var commands, err = ParseCommands(`[[ a < b && ( c || d ) ]] > out && echo x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commands[0].Path != "[[" || len(commands[0].Args) != 10 || commands[0].Output != "out" || commands[0].And == nil {
+		t.Errorf("unexpected commands: %+v", commands)
+	}
+
+	if _, err = ParseCommands("[[ a & b ]]"); err == nil || !strings.Contains(err.Error(), "conditional expression") {
+		t.Errorf("expected syntax error, got: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const coprocUsage = `coproc: coproc [NAME] command [redirections]
+    Create a coprocess named NAME.
+
+    Execute COMMAND asynchronously, with the standard output and standard
+    input of the command connected via a pipe to file descriptors assigned
+    to indices 0 and 1 of an array variable NAME in the executing shell.
+    The default NAME is "COPROC".  If COMMAND is a single external command,
+    the variable NAME_PID is set to its process ID.  Otherwise the coprocess
+    runs in a subshell inside the shell process, and NAME_PID is set to the
+    process ID of the shell, the same as $$.
+
+    Exit Status:
+    The coproc command returns an exit status of 0.
+`
+
+// coproc 在子 shell 中后台执行命令，命令的输出和输入通过管道连接到 ${NAME[0]} 和 ${NAME[1]}，
+// 参数为 lexer 读取的协程名和命令文本
+func (sh *Gosh) coproc(opt types.Option, args []string) (code int) {
+	if len(args) == 2 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, coprocUsage)
+		return
+	}
+
+	if len(args) != 3 {
+		_, _ = fmt.Fprint(opt.Stderr, coprocUsage)
+		return 2
+	}
+
+	var name, script = args[1], args[2]
+	if !isName(name) {
+		writeError(opt, fmt.Errorf("coproc: `%s': not a valid identifier", name))
+		return 1
+	}
+
+	var err error
+	if err = sh.restrictVar(name); err != nil {
+		sh.writeError(opt, fmt.Errorf("coproc: %w", err))
+		return 1
+	}
+
+	// output 为协程的输出，input 为协程的输入
+	var outR, outW, inR, inW *os.File
+	if outR, outW, err = os.Pipe(); err != nil {
+		writeError(opt, fmt.Errorf("coproc: %w", err))
+		return 1
+	}
+	if inR, inW, err = os.Pipe(); err != nil {
+		_, _ = outR.Close(), outW.Close()
+		writeError(opt, fmt.Errorf("coproc: %w", err))
+		return 1
+	}
+
+	// 在加入 shell 的文件描述符之前创建子 shell，协程不持有 shell 一侧的管道
+	var inner = opt
+	inner.Stdin, inner.Stdout = inR, outW
+
+	var child = sh.subshell(inner)
+
+	var readFd, _ = fileFd(outR)
+	var writeFd, _ = fileFd(inW)
+
+	if sh.files == nil {
+		sh.files = make(map[int]*os.File)
+	}
+	sh.files[readFd], sh.files[writeFd] = outR, inW
+	sh.opened = append(sh.opened, outR, inW)
+
+	var untrack = sh.track(child.notify)
+
+	// 单个命令为外部命令时 NAME_PID 为命令的进程号，否则协程是 shell 进程内的子 shell，NAME_PID 与 $$ 相同
+	var pid = make(chan int, 1)
+	if singleCommand(script) {
+		child.onStart = func(p int) { pid <- p }
+	} else {
+		pid <- 0
+	}
+
+	go func() {
+		defer untrack()
+		_, _ = child.Run(strings.NewReader(script), inner)
+		_, _ = inR.Close(), outW.Close()
+
+		// 没有执行任何命令
+		child.started(0)
+	}()
+
+	var value = os.Getpid()
+	if p := <-pid; p != 0 {
+		value = p
+	}
+
+	if err = sh.setArray(name, []string{strconv.Itoa(readFd), strconv.Itoa(writeFd)}); err == nil {
+		err = sh.setvar(name+"_PID", strconv.Itoa(value))
+	}
+
+	if err != nil {
+		sh.writeError(opt, fmt.Errorf("coproc: %w", err))
+		return 1
+	}
+
+	return
+}
+
+// singleCommand 命令文本是否为单个命令，不包括管道、&&、|| 和复合命令
+func singleCommand(script string) bool {
+	var commands, err = ParseCommands(script)
+	if err != nil || len(commands) != 1 {
+		return false
+	}
+
+	var command = commands[0]
+
+	return command.Pipe == nil && command.And == nil && command.Or == nil && !strings.HasPrefix(command.Path, "(")
+}
@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoproc(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			// 写入 ${NAME[1]}，从 ${NAME[0]} 读取协程的输出
+			script:   `coproc C { cat; }; echo hi >&${C[1]}; read -u ${C[0]} x; echo $x ${#C[@]}; [[ $C_PID -eq $$ ]] && echo pid`,
+			expected: "hi 2\npid\n",
+		},
+		{
+			// 默认名称为 COPROC，简单命令不需要大括号
+			script:   `coproc cat; echo 'a b' >&${COPROC[1]}; read -u ${COPROC[0]}; echo "$REPLY"`,
+			expected: "a b\n",
+		},
+		{
+			// 大括号内的多个命令，引号内的 } 不结束命令
+			script:   "coproc P { echo '}'; cat\n}\nread -u ${P[0]} a; echo x >&${P[1]}; read -u ${P[0]} b; echo $a $b",
+			expected: "} x\n",
+		},
+		{
+			// 多个协程同时运行
+			script:   `coproc A { cat; }; coproc B { cat; }; echo b >&${B[1]}; echo a >&${A[1]}; read -u ${A[0]} a; read -u ${B[0]} b; echo $a $b`,
+			expected: "a b\n",
+		},
+		{
+			// 外部命令的协程 NAME_PID 为命令的进程号
+			script:   `coproc sh -c 'echo $$'; read -u ${COPROC[0]} p; [[ $p -eq $COPROC_PID && $p -ne $$ ]] && echo pid`,
+			expected: "pid\n",
+		},
+		{
+			script:   `coproc x=1; [[ $COPROC_PID -eq $$ ]] && echo pid`,
+			expected: "pid\n",
+		},
+		{
+			script:   `type coproc`,
+			expected: "coproc is a shell keyword\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestParseCoproc(t *testing.T) {
+	var commands, err = ParseCommands("coproc N { echo a; echo b; } ; echo c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commands) != 2 || commands[0].Path != "coproc" || len(commands[0].Args) != 2 || unmark(commands[0].Args[0]) != "N" || commands[1].Path != "echo" {
+		t.Errorf("unexpected commands: %+v", commands)
+	}
+
+	if _, err = ParseCommands("coproc { cat"); err == nil || !strings.Contains(err.Error(), "matching `}'") {
+		t.Errorf("expected syntax error, got: %v", err)
+	}
+}
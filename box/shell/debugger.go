@@ -0,0 +1,380 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const debuggerHelp = `Debugger commands:
+  s, step            execute the next command, stepping into sourced scripts
+  n, next            execute the next command, stepping over sourced scripts
+  c, continue        continue until a breakpoint is reached
+  b, break [LOC]     set a breakpoint at LOC or list the breakpoints, LOC is
+                     LINE, FILE:LINE or the NAME of a command; gosh has no
+                     shell functions, so NAME only matches commands
+  d, delete [N ...]  delete the breakpoints N, or all breakpoints
+  p, print WORD ...  print the words after expansion, such as $x or ${a[@]}
+  e, eval COMMAND    execute COMMAND in the shell being debugged
+  bt, where          print the call stack, the frames are the scripts run by
+                     source and main, as gosh has no shell functions
+  q, quit [N]        exit the shell with status N
+  h, help            print this help
+
+An empty line repeats the previous command.  End of input continues the
+script without stopping.
+`
+
+// callFrame 调用栈中的一层，line 为调用方调用时所在的行号
+type callFrame struct {
+	name   string
+	source string
+	line   int
+}
+
+// pushCall 进入新的调用层并更新 FUNCNAME、BASH_SOURCE 和 BASH_LINENO，返回恢复调用栈和行号的函数
+func (sh *Gosh) pushCall(name, source string) (pop func()) {
+	var line = sh.line
+
+	sh.calls = append(sh.calls, callFrame{name: name, source: source, line: line})
+	sh.setCallVars()
+
+	return func() {
+		sh.calls, sh.line = sh.calls[:len(sh.calls)-1], line
+		sh.setCallVars()
+	}
+}
+
+// setCallVars 按调用栈设置 FUNCNAME、BASH_SOURCE 和 BASH_LINENO，第一个元素为当前层
+func (sh *Gosh) setCallVars() {
+	var names, sources, lines []string
+	for _, frame := range slices.Backward(sh.calls) {
+		names, sources = append(names, frame.name), append(sources, frame.source)
+		lines = append(lines, strconv.Itoa(frame.line))
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	for name, values := range map[string][]string{"FUNCNAME": names, "BASH_SOURCE": sources, "BASH_LINENO": lines} {
+		if len(values) == 0 {
+			delete(sh.vars, name)
+		} else {
+			sh.vars[name] = &Variable{Array: values}
+		}
+	}
+}
+
+// frame 当前的调用层，没有执行脚本时为 main
+func (sh *Gosh) frame() callFrame {
+	if len(sh.calls) == 0 {
+		return callFrame{name: "main", source: sh.name}
+	}
+
+	return sh.calls[len(sh.calls)-1]
+}
+
+// commandText 命令的文本，用于 BASH_COMMAND 和调试器的输出
+func commandText(c *Command) string {
+	var words []string
+	for _, word := range c.CmdArgs() {
+		words = append(words, unmark(word))
+	}
+
+	var text = strings.Join(words, " ")
+
+	switch {
+	case c.Pipe != nil:
+		text += " | " + commandText(c.Pipe)
+	case c.And != nil:
+		text += " && " + commandText(c.And)
+	case c.Or != nil:
+		text += " || " + commandText(c.Or)
+	}
+
+	if c.Negate {
+		text = "! " + text
+	}
+
+	return text
+}
+
+// debugTrap 执行命令前设置 BASH_COMMAND 并执行 DEBUG trap，启用调试器时由调试器决定是否停止
+func (sh *Gosh) debugTrap(command *Command) {
+	if sh.intrap {
+		return
+	}
+
+	_ = sh.setvar("BASH_COMMAND", commandText(command))
+
+	if sh.runTrap(trapDebug); sh.exited || sh.debugger == nil {
+		return
+	}
+
+	sh.debugger.check(sh, command)
+}
+
+// breakpoint 断点，name 不为空时在执行该命令前停止，否则在 source 的第 line 行停止
+type breakpoint struct {
+	id     int
+	name   string
+	source string
+	line   int
+}
+
+func (b breakpoint) String() string {
+	if b.name != "" {
+		return b.name
+	}
+
+	return fmt.Sprintf("%s:%d", b.source, b.line)
+}
+
+// 调试器的执行方式
+const (
+	debugStep     = iota // 在下一个命令前停止
+	debugNext            // 在当前层或上层的下一个命令前停止
+	debugContinue        // 在断点处停止
+	debugDetach          // 输入结束，不再停止
+)
+
+// debugger --debugger 启动的调试器，在 DEBUG trap 的位置检查是否停止，从 in 读取调试命令
+type debugger struct {
+	in     io.Reader
+	out    io.Writer
+	mode   int
+	depth  int // next 时的调用层数
+	breaks []breakpoint
+	nextID int
+	last   string // 上一个调试命令，空行时重复
+}
+
+func newDebugger(in io.Reader, out io.Writer) *debugger {
+	return &debugger{in: in, out: out, mode: debugStep, nextID: 1}
+}
+
+// match 断点是否匹配即将执行的命令
+func (d *debugger) match(sh *Gosh, command *Command) bool {
+	var frame = sh.frame()
+	for _, b := range d.breaks {
+		switch {
+		case b.name != "":
+			if b.name == unmark(command.Path) {
+				return true
+			}
+		case b.line == sh.line && (b.source == frame.source || b.source == filepath.Base(frame.source)):
+			return true
+		}
+	}
+
+	return false
+}
+
+// check 判断是否在命令前停止，停止时读取并执行调试命令，直到继续执行
+func (d *debugger) check(sh *Gosh, command *Command) {
+	switch d.mode {
+	case debugDetach:
+		return
+	case debugNext:
+		if len(sh.calls) > d.depth && !d.match(sh, command) {
+			return
+		}
+	case debugContinue:
+		if !d.match(sh, command) {
+			return
+		}
+	}
+
+	var frame = sh.frame()
+	_, _ = fmt.Fprintf(d.out, "(%s:%d): %s\n", frame.source, sh.line, commandText(command))
+
+	// 调试命令执行期间不触发 trap 和调试器，每个调试命令执行后恢复行号和 BASH_COMMAND
+	var (
+		line, status = sh.line, sh.status
+		current, _   = sh.lookup("BASH_COMMAND")
+	)
+
+	sh.intrap = true
+	defer func() { sh.intrap = false }()
+
+	for !sh.exited {
+		_, _ = fmt.Fprint(d.out, "debug> ")
+
+		var text, err = readLine(d.in)
+		if text = strings.TrimSpace(text); text == "" && err == nil {
+			text = d.last
+		}
+
+		if text == "" && err != nil {
+			if !errors.Is(err, io.EOF) {
+				writeError(types.Option{Stderr: d.out}, err)
+			}
+			_, _ = fmt.Fprintln(d.out)
+			d.mode = debugDetach
+			return
+		}
+
+		d.last = text
+
+		var resume = d.exec(sh, text, status)
+		if sh.line = line; !sh.exited {
+			_ = sh.setvar("BASH_COMMAND", current)
+		}
+
+		if resume {
+			return
+		}
+	}
+}
+
+// exec 执行一个调试命令，返回是否继续执行脚本
+func (d *debugger) exec(sh *Gosh, text string, status int) (resume bool) {
+	var cmd, rest, _ = strings.Cut(text, " ")
+	rest = strings.TrimSpace(rest)
+
+	var opt = sh.Option
+	opt.Stdout, opt.Stderr = d.out, d.out
+
+	switch cmd {
+	case "s", "step":
+		d.mode = debugStep
+		return true
+	case "n", "next":
+		d.mode, d.depth = debugNext, len(sh.calls)
+		return true
+	case "c", "cont", "continue":
+		d.mode = debugContinue
+		return true
+	case "b", "break":
+		d.setBreak(sh, rest)
+	case "d", "delete":
+		d.deleteBreaks(rest)
+	case "p", "print":
+		sh.status = status
+		d.print(sh, rest)
+	case "e", "eval":
+		sh.status = status
+		_, _ = sh.Run(strings.NewReader(rest), opt)
+		if !sh.exited {
+			sh.status = status
+		}
+	case "bt", "where", "backtrace":
+		d.backtrace(sh)
+	case "q", "quit":
+		sh.exited = true
+		if code, err := strconv.Atoi(rest); err == nil {
+			sh.status = code
+		}
+	case "h", "help":
+		_, _ = fmt.Fprint(d.out, debuggerHelp)
+	default:
+		_, _ = fmt.Fprintf(d.out, "%s: unknown debugger command, try `help'\n", cmd)
+	}
+
+	return false
+}
+
+// print 输出单词展开的结果，只接受单词，不执行命令
+func (d *debugger) print(sh *Gosh, text string) {
+	var commands, err = ParseCommands(text)
+	if err == nil && len(commands) > 0 && (len(commands) > 1 || !isWords(commands[0])) {
+		err = errors.New("only words can be printed")
+	}
+
+	var words []string
+	if err == nil && len(commands) == 1 {
+		words, err = sh.expandWords(commands[0].CmdArgs())
+	}
+
+	if err != nil {
+		writeError(types.Option{Stderr: d.out}, fmt.Errorf("print: %w", err))
+		return
+	}
+
+	_, _ = fmt.Fprintln(d.out, strings.Join(words, " "))
+}
+
+// isWords 命令是否只由单词组成，没有管道、重定向等
+func isWords(c Command) bool {
+	return c.Pipe == nil && c.And == nil && c.Or == nil && c.Input == "" && c.Output == "" && c.Append == "" &&
+		c.Heredoc == "" && len(c.Redirects) == 0 && !c.Background && !c.Negate && !c.Time
+}
+
+// setBreak 设置断点，没有参数时列出全部断点
+func (d *debugger) setBreak(sh *Gosh, location string) {
+	if location == "" {
+		if len(d.breaks) == 0 {
+			_, _ = fmt.Fprintln(d.out, "No breakpoints.")
+		}
+		for _, b := range d.breaks {
+			_, _ = fmt.Fprintf(d.out, "%d\tbreakpoint at %s\n", b.id, b)
+		}
+		return
+	}
+
+	var (
+		b                   = breakpoint{id: d.nextID, source: sh.frame().source}
+		source, line, found = strings.Cut(location, ":")
+		err                 error
+	)
+
+	switch {
+	case found:
+		b.source = source
+		b.line, err = strconv.Atoi(line)
+	case strings.Trim(location, "0123456789") == "":
+		b.line, err = strconv.Atoi(location)
+	default:
+		b.name = location
+	}
+
+	if err != nil || b.name == "" && b.line <= 0 {
+		_, _ = fmt.Fprintf(d.out, "%s: invalid breakpoint location\n", location)
+		return
+	}
+
+	d.breaks = append(d.breaks, b)
+	d.nextID++
+
+	_, _ = fmt.Fprintf(d.out, "Breakpoint %d at %s\n", b.id, b)
+}
+
+// deleteBreaks 删除指定编号的断点，没有参数时删除全部断点
+func (d *debugger) deleteBreaks(args string) {
+	if args == "" {
+		d.breaks = nil
+		return
+	}
+
+	for _, arg := range strings.Fields(args) {
+		var id, _ = strconv.Atoi(arg)
+
+		var i = slices.IndexFunc(d.breaks, func(b breakpoint) bool { return b.id == id })
+		if i < 0 {
+			_, _ = fmt.Fprintf(d.out, "No breakpoint number %s.\n", arg)
+			continue
+		}
+
+		d.breaks = slices.Delete(d.breaks, i, i+1)
+	}
+}
+
+// backtrace 输出调用栈，第一行为当前层
+func (d *debugger) backtrace(sh *Gosh) {
+	var frames = slices.Clone(sh.calls)
+	if len(frames) == 0 {
+		frames = append(frames, sh.frame())
+	}
+
+	var line = sh.line
+	for i, frame := range slices.Backward(frames) {
+		_, _ = fmt.Fprintf(d.out, "#%d %s at %s:%d\n", len(frames)-1-i, frame.name, frame.source, line)
+		line = frame.line
+	}
+}
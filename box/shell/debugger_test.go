@@ -0,0 +1,118 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zooyer/gobox/types"
+)
+
+func TestDebugger(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		lib    = filepath.Join(dir, "lib.sh")
+		script = filepath.Join(dir, "main.sh")
+	)
+
+	if err := os.WriteFile(lib, []byte("echo lib $1\necho ${FUNCNAME[@]} ${BASH_LINENO[@]}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("x=1\necho start\nsource "+lib+" a\nx=2\necho $x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		input    string
+		code     int
+		expected string
+	}{
+		{
+			// 输入结束后不再停止
+			name:     "detach",
+			input:    "",
+			expected: "(main.sh:1): x=1\ndebug> \nstart\nlib a\nsource main 3 0\n2\n",
+		},
+		{
+			// step 进入 source 的脚本，next 跳过
+			name:  "step",
+			input: "n\n\ns\nbt\nn\nn\nc\n",
+			expected: "(main.sh:1): x=1\ndebug> (main.sh:2): echo start\ndebug> start\n(main.sh:3): source lib.sh a\n" +
+				"debug> (lib.sh:1): echo lib $1\ndebug> #0 source at lib.sh:1\n#1 main at main.sh:3\n" +
+				"debug> lib a\n(lib.sh:2): echo ${FUNCNAME[@]} ${BASH_LINENO[@]}\ndebug> source main 3 0\n(main.sh:4): x=2\ndebug> 2\n",
+		},
+		{
+			name:  "next",
+			input: "n\nn\nn\nc\n",
+			expected: "(main.sh:1): x=1\ndebug> (main.sh:2): echo start\ndebug> start\n(main.sh:3): source lib.sh a\n" +
+				"debug> lib a\nsource main 3 0\n(main.sh:4): x=2\ndebug> 2\n",
+		},
+		{
+			// 按行号和命令名设置断点，print 和 eval 在调试的 shell 中执行
+			name:  "breakpoint",
+			input: "b 5\nb lib.sh:2\nb echo\nd 3\nb\nc\np $x $BASH_COMMAND\nc\ne x=3; echo $?\nc\n",
+			expected: "(main.sh:1): x=1\ndebug> Breakpoint 1 at main.sh:5\ndebug> Breakpoint 2 at lib.sh:2\ndebug> Breakpoint 3 at echo\n" +
+				"debug> debug> 1\tbreakpoint at main.sh:5\n2\tbreakpoint at lib.sh:2\ndebug> start\nlib a\n" +
+				"(lib.sh:2): echo ${FUNCNAME[@]} ${BASH_LINENO[@]}\ndebug> 1 echo ${FUNCNAME[@]} ${BASH_LINENO[@]}\ndebug> source main 3 0\n" +
+				"(main.sh:5): echo $x\ndebug> 0\ndebug> 3\n",
+		},
+		{
+			// print 只展开单词，调试命令不改变停止位置的行号和 BASH_COMMAND
+			name:  "print",
+			input: "s\np $x; echo rm\np \"$x  y\" > f\np \"$x  y\" '$x'\ne echo in\nbt\np $BASH_COMMAND\nc\n",
+			expected: "(main.sh:1): x=1\ndebug> (main.sh:2): echo start\ndebug> shell: print: only words can be printed\n" +
+				"debug> shell: print: only words can be printed\ndebug> 1  y $x\ndebug> in\ndebug> #0 main at main.sh:2\n" +
+				"debug> echo start\ndebug> start\nlib a\nsource main 3 0\n2\n",
+		},
+		{
+			name:     "quit",
+			input:    "b x\nfoo\nq 3\n",
+			code:     3,
+			expected: "(main.sh:1): x=1\ndebug> Breakpoint 1 at x\ndebug> foo: unknown debugger command, try `help'\ndebug> ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			var sh = NewGosh(types.Option{
+				Env:    os.Environ(),
+				Dir:    dir,
+				Stdin:  strings.NewReader(test.input),
+				Stdout: &stdout,
+				Stderr: &stdout,
+			})
+
+			if code := sh.Main([]string{"gosh", "--debugger", script}); code != test.code {
+				t.Fatalf("expected code: %d, got: %d, output: %s", test.code, code, stdout.String())
+			}
+
+			if output := strings.ReplaceAll(stdout.String(), dir+string(filepath.Separator), ""); output != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestCallStack(t *testing.T) {
+	var lib = filepath.Join(t.TempDir(), "lib.sh")
+	if err := os.WriteFile(lib, []byte("echo ${FUNCNAME[@]} ${BASH_SOURCE[@]} ${BASH_LINENO[@]}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// DEBUG trap 中 BASH_COMMAND 为即将执行的命令，source 结束后恢复调用栈
+	var sh, stdout = newTestGosh()
+	var script = "trap 'echo \"> $BASH_COMMAND\"' DEBUG\necho a | cat\n\nsource " + lib + "\necho ${#FUNCNAME[@]}"
+	if _, err := sh.Run(strings.NewReader(script), sh.Option); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = "> echo a | cat\na\n> source " + lib + "\n> echo ${FUNCNAME[@]} ${BASH_SOURCE[@]} ${BASH_LINENO[@]}\nsource " + lib + " 4\n> echo ${#FUNCNAME[@]}\n0\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %q, got: %q", expected, stdout.String())
+	}
+}
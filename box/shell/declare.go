@@ -0,0 +1,314 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const declareUsage = `declare: declare [-aAgpx] [name[=value] ...]
+    Set variable values and attributes.
+
+    Declare variables and give them attributes.  If no NAMEs are given,
+    display the attributes and values of all variables.
+
+    Options:
+      -p	display the attributes and value of each NAME
+
+    Options which set attributes:
+      -a	to make NAMEs indexed arrays (if supported)
+      -A	to make NAMEs associative arrays (if supported)
+      -g	accepted for compatibility, variables are always global
+      -x	to make NAMEs export
+
+    Using '+' instead of '-' turns off the given attribute.
+
+    Exit Status:
+    Returns success unless an invalid option is supplied or a variable
+    assignment error occurs.
+`
+
+const unsetUsage = `unset: unset [-v] [name ...]
+    Unset values and attributes of shell variables.
+
+    For each NAME, remove the corresponding variable.  An element of an
+    array is removed with NAME[SUBSCRIPT]; NAME[@] removes the whole array.
+
+    Options:
+      -v	treat each NAME as a shell variable
+
+    Exit Status:
+    Returns success unless an invalid option is given or a NAME is read-only.
+`
+
+// declarations 参数中的赋值由内置命令自己展开的声明命令
+var declarations = []string{"declare", "typeset"}
+
+// expandArgs 展开命令参数，声明命令中赋值形式的参数保留原单词，由声明命令按变量赋值展开，
+// 其余参数展开后转义，`[[ ]]` 的参数全部保留原单词
+func (sh *Gosh) expandArgs(words []string) (args []string, err error) {
+	if len(words) > 0 && words[0] == "[[" && sh.Builtin["[["] != nil {
+		return words, nil
+	}
+
+	if len(words) == 0 || !slices.Contains(declarations, words[0]) || sh.Builtin[words[0]] == nil {
+		return sh.expandWords(words)
+	}
+
+	args = []string{words[0]}
+	for _, word := range words[1:] {
+		if name, _, ok := strings.Cut(word, "="); ok && isAssignName(name) {
+			args = append(args, word)
+			continue
+		}
+
+		var list []string
+		if list, err = sh.expand(word); err != nil {
+			return
+		}
+
+		for _, value := range list {
+			args = append(args, escapeWord(value))
+		}
+	}
+
+	return
+}
+
+// doubleQuote 加双引号，转义双引号内的特殊字符
+func doubleQuote(value string) string {
+	var replacer = strings.NewReplacer(`"`, `\"`, `\`, `\\`, "$", `\$`, "`", "\\`")
+
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// declaration 输出 declare -p 格式的变量定义
+func declaration(name string, v *Variable) string {
+	var flags string
+	switch {
+	case v.Assoc != nil:
+		flags = "A"
+	case v.Array != nil:
+		flags = "a"
+	}
+
+	if v.Export {
+		flags += "x"
+	}
+
+	if flags == "" {
+		flags = "-"
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "declare -%s %s", flags, name)
+
+	switch {
+	case v.Assoc != nil:
+		sb.WriteString("=(")
+		for _, key := range v.keys() {
+			_, _ = fmt.Fprintf(&sb, "[%s]=%s ", key, doubleQuote(v.Assoc[key]))
+		}
+		sb.WriteString(")")
+	case v.Array != nil:
+		var elements = make([]string, len(v.Array))
+		for i, value := range v.Array {
+			elements[i] = fmt.Sprintf("[%d]=%s", v.indexAt(i), doubleQuote(value))
+		}
+		_, _ = fmt.Fprintf(&sb, "=(%s)", strings.Join(elements, " "))
+	default:
+		_, _ = fmt.Fprintf(&sb, "=%s", doubleQuote(v.Value))
+	}
+
+	return sb.String()
+}
+
+// declareAttrs 设置变量的数组和导出属性，export 为 nil 时不修改导出属性
+func (sh *Gosh) declareAttrs(name string, indexed, assoc bool, export *bool) (err error) {
+	if !indexed && !assoc && export == nil {
+		return
+	}
+
+	if err = sh.restrictVar(name); err != nil {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	var v = sh.vars[name]
+	switch {
+	case v == nil && !indexed && !assoc:
+		v = &Variable{}
+	case v == nil && assoc:
+		v = &Variable{Assoc: make(map[string]string)}
+	case v == nil:
+		v = &Variable{Array: []string{}}
+	case assoc && v.Array != nil:
+		return fmt.Errorf("%s: cannot convert indexed to associative array", name)
+	case indexed && v.Assoc != nil:
+		return fmt.Errorf("%s: cannot convert associative to indexed array", name)
+	case assoc && v.Assoc == nil:
+		v.Assoc, v.Value = map[string]string{"0": v.Value}, ""
+	case indexed && v.Array == nil:
+		v.Array, v.Value = []string{v.Value}, ""
+	}
+
+	if export != nil {
+		v.Export = *export
+	}
+
+	sh.vars[name] = v
+
+	return
+}
+
+// declare 声明变量并设置属性，或输出变量的定义
+func (sh *Gosh) declare(opt types.Option, args []string) (code int) {
+	var command = args[0]
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, declareUsage)
+		return
+	}
+
+	var (
+		indexed, assoc, print bool
+		export                *bool
+	)
+
+	args = args[1:]
+	for len(args) > 0 {
+		var arg = unmark(args[0])
+		if arg == "--" {
+			args = args[1:]
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' && arg[0] != '+' {
+			break
+		}
+
+		for _, c := range []byte(arg[1:]) {
+			switch c {
+			case 'a':
+				indexed = true
+			case 'A':
+				assoc = true
+			case 'x':
+				var on = arg[0] == '-'
+				export = &on
+			case 'p':
+				print = true
+			case 'g':
+			default:
+				writeError(opt, fmt.Errorf("%s: %c%c: invalid option", command, arg[0], c))
+				_, _ = fmt.Fprint(opt.Stderr, declareUsage)
+				return 2
+			}
+		}
+
+		args = args[1:]
+	}
+
+	// 没有变量名时输出全部变量
+	if len(args) == 0 {
+		sh.mutex.RLock()
+		defer sh.mutex.RUnlock()
+
+		for _, name := range slices.Sorted(maps.Keys(sh.vars)) {
+			var v = sh.vars[name]
+			if indexed && v.Array == nil || assoc && v.Assoc == nil || export != nil && v.Export != *export {
+				continue
+			}
+			_, _ = fmt.Fprintln(opt.Stdout, declaration(name, v))
+		}
+		return
+	}
+
+	for _, arg := range args {
+		var name, word, hasValue = strings.Cut(arg, "=")
+		if name = unmark(name); !isAssignName(name) {
+			writeError(opt, fmt.Errorf("%s: `%s': not a valid identifier", command, unmark(arg)))
+			code = 1
+			continue
+		}
+
+		if print {
+			sh.mutex.RLock()
+			var v = sh.vars[name]
+			if v != nil {
+				_, _ = fmt.Fprintln(opt.Stdout, declaration(name, v))
+			}
+			sh.mutex.RUnlock()
+
+			if v == nil {
+				writeError(opt, fmt.Errorf("%s: %s: not found", command, name))
+				code = 1
+			}
+			continue
+		}
+
+		var err = sh.declareAttrs(assignBase(name), indexed, assoc, export)
+		if err == nil && hasValue {
+			err = sh.assignVar(name, word)
+		}
+
+		if err != nil {
+			sh.writeError(opt, fmt.Errorf("%s: %w", command, err))
+			code = 1
+		}
+	}
+
+	return
+}
+
+// unset 删除变量或数组元素
+func (sh *Gosh) unset(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, unsetUsage)
+		return
+	}
+
+	args, err := parseOptions(args[1:], "v", func(option byte, value string) error {
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("unset: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, unsetUsage)
+		return 2
+	}
+
+	for _, name := range args {
+		var base, sub, element = parseSubscript(name)
+		if !element && !isName(name) {
+			writeError(opt, fmt.Errorf("unset: `%s': not a valid identifier", name))
+			code = 1
+			continue
+		}
+
+		if !element {
+			base = name
+		}
+
+		if err = sh.restrictVar(base); err != nil {
+			sh.writeError(opt, fmt.Errorf("unset: %w", err))
+			code = 1
+			continue
+		}
+
+		if !element {
+			sh.unsetvar(name)
+			continue
+		}
+
+		if err = sh.unsetElement(base, escapeWord(sub)); err != nil {
+			sh.writeError(opt, fmt.Errorf("unset: %w", err))
+			code = 1
+		}
+	}
+
+	return
+}
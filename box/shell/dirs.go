@@ -0,0 +1,575 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const cdUsage = `cd: cd [-L|-P] [dir]
+    Change the shell working directory.
+
+    Change the current directory to DIR.  The default DIR is the value of the
+    HOME shell variable.  If DIR is "-", it is converted to $OLDPWD.  If DIR
+    is +N or -N, it is the Nth entry of the directory stack shown by 'dirs',
+    counting from the left or the right.
+
+    The variable CDPATH defines the search path for the directory containing
+    DIR.  Alternative directory names in CDPATH are separated by a colon (:).
+    A null directory name is the same as the current directory.  If DIR begins
+    with a slash (/), then CDPATH is not used.
+
+    Options:
+      -L	force symbolic links to be followed: resolve symbolic
+    		links in DIR after processing instances of '..'
+      -P	use the physical directory structure without following
+    		symbolic links: resolve symbolic links in DIR before
+    		processing instances of '..'
+
+    The default is to follow symbolic links, as if '-L' were specified.
+`
+
+const pwdUsage = `pwd: pwd [-LP]
+    Print the name of the current working directory.
+
+    Options:
+      -L	print the value of $PWD if it names the current working
+    		directory
+      -P	print the physical directory, without any symbolic links
+
+    By default, 'pwd' behaves as if '-L' were specified.
+
+    Exit Status:
+    Returns 0 unless an invalid option is given or the current directory
+    cannot be read.
+`
+
+const dirsUsage = `dirs: dirs [-clpv] [+N] [-N]
+    Display directory stack.
+
+    Display the list of currently remembered directories.  Directories
+    find their way onto the list with the 'pushd' command; you can get
+    back up through the list with the 'popd' command.
+
+    Options:
+      -c	clear the directory stack by deleting all of the elements
+      -l	do not print tilde-prefixed versions of directories relative
+    		to your home directory
+      -p	print the directory stack with one entry per line
+      -v	print the directory stack with one entry per line prefixed
+    		with its position in the stack
+
+    Arguments:
+      +N	Displays the Nth entry counting from the left of the list shown by
+    		dirs when invoked without options, starting with zero.
+      -N	Displays the Nth entry counting from the right of the list shown by
+    		dirs when invoked without options, starting with zero.
+`
+
+const pushdUsage = `pushd: pushd [-n] [+N | -N | dir]
+    Add directories to stack.
+
+    Adds a directory to the top of the directory stack, or rotates
+    the stack, making the new top of the stack the current working
+    directory.  With no arguments, exchanges the top two directories.
+
+    Options:
+      -n	Suppresses the normal change of directory when adding
+    		directories to the stack, so only the stack is manipulated.
+
+    Arguments:
+      +N	Rotates the stack so that the Nth directory (counting
+    		from the left of the list shown by 'dirs', starting with
+    		zero) is at the top.
+      -N	Rotates the stack so that the Nth directory (counting
+    		from the right of the list shown by 'dirs', starting with
+    		zero) is at the top.
+      dir	Adds DIR to the directory stack at the top, making it the
+    		new current working directory.
+`
+
+const popdUsage = `popd: popd [-n] [+N | -N]
+    Remove directories from stack.
+
+    Removes entries from the directory stack.  With no arguments, removes
+    the top directory from the stack, and changes to the new top directory.
+
+    Options:
+      -n	Suppresses the normal change of directory when removing
+    		directories from the stack, so only the stack is manipulated.
+
+    Arguments:
+      +N	Removes the Nth entry counting from the left of the list
+    		shown by 'dirs', starting with zero.
+      -N	Removes the Nth entry counting from the right of the list
+    		shown by 'dirs', starting with zero.
+`
+
+// environ cd 读写 PWD、OLDPWD 等变量的方式：在 shell 中为 shell 变量，单独执行时为进程的环境变量
+type environ interface {
+	getenv(name string) string
+	setvar(name, value string) error
+}
+
+// processEnv 进程的环境变量
+type processEnv struct{}
+
+func (processEnv) getenv(name string) string {
+	return os.Getenv(name)
+}
+
+func (processEnv) setvar(name, value string) error {
+	return os.Setenv(name, value)
+}
+
+// workdir 当前工作目录，PWD 与实际的工作目录一致时使用 PWD 以保留符号链接
+func workdir(env environ) string {
+	var wd, err = os.Getwd()
+	if err != nil {
+		wd = "."
+	}
+
+	var pwd = env.getenv("PWD")
+	if !filepath.IsAbs(pwd) {
+		return wd
+	}
+
+	if a, err := os.Stat(pwd); err == nil {
+		if b, err := os.Stat(wd); err == nil && os.SameFile(a, b) {
+			return pwd
+		}
+	}
+
+	return wd
+}
+
+// chdir 切换工作目录并设置 PWD 和 OLDPWD，physical 为 false 时先按文本处理 `..` 再解析符号链接
+func chdir(env environ, dir string, physical bool) (pwd string, err error) {
+	var old = workdir(env)
+
+	if pwd = dir; !filepath.IsAbs(pwd) {
+		pwd = filepath.Join(old, pwd)
+	}
+	pwd = filepath.Clean(pwd)
+
+	if physical {
+		if pwd, err = filepath.EvalSymlinks(dir); err != nil {
+			return
+		}
+		if pwd, err = filepath.Abs(pwd); err != nil {
+			return
+		}
+	}
+
+	if err = os.Chdir(pwd); err != nil {
+		return
+	}
+
+	if err = env.setvar("OLDPWD", old); err != nil {
+		return
+	}
+
+	return pwd, env.setvar("PWD", pwd)
+}
+
+// stackIndex 解析目录栈的下标 +N 或 -N，n 为包括当前目录在内的栈的大小
+func stackIndex(arg string, n int) (index int, ok bool, err error) {
+	if len(arg) < 2 || (arg[0] != '+' && arg[0] != '-') {
+		return
+	}
+
+	if index, err = strconv.Atoi(arg[1:]); err != nil || index < 0 {
+		return 0, false, nil
+	}
+
+	if arg[0] == '-' {
+		index = n - 1 - index
+	}
+
+	if index < 0 || index >= n {
+		return 0, true, fmt.Errorf("%s: directory stack index out of range", arg)
+	}
+
+	return index, true, nil
+}
+
+// searchCDPath 在 CDPATH 中查找相对路径的目录，通过 CDPATH 中非空的目录找到时 print 为 true
+func searchCDPath(env environ, dir string) (path string, print bool) {
+	var cdpath = env.getenv("CDPATH")
+	if cdpath == "" || filepath.IsAbs(dir) || dir == "." || dir == ".." ||
+		strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		return dir, false
+	}
+
+	for _, prefix := range filepath.SplitList(cdpath) {
+		var candidate = filepath.Join(prefix, dir)
+		if prefix == "" {
+			candidate = dir
+		}
+
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, prefix != "" && prefix != "."
+		}
+	}
+
+	return dir, false
+}
+
+// cd 切换工作目录，stack 为目录栈中当前目录之后的目录，用于 +N 和 -N
+func cd(opt types.Option, args []string, env environ, stack []string) (code int) {
+	var (
+		physical bool
+		dir      string
+		set      bool
+		print    bool
+	)
+
+	for i := 1; i < len(args); i++ {
+		var arg = args[i]
+
+		switch {
+		case !set && (arg == "-h" || arg == "--help"):
+			_, _ = fmt.Fprint(opt.Stdout, cdUsage)
+			return
+		case !set && arg == "--":
+			if i+1 < len(args) {
+				dir, set = args[i+1], true
+				i++
+			}
+			if i+1 < len(args) {
+				writeError(opt, errors.New("cd: too many arguments"))
+				return 1
+			}
+		case !set && arg == "-P":
+			physical = true
+		case !set && arg == "-L":
+			physical = false
+		case set:
+			writeError(opt, errors.New("cd: too many arguments"))
+			return 1
+		default:
+			dir, set = arg, true
+		}
+	}
+
+	var entries = append([]string{workdir(env)}, stack...)
+
+	switch index, ok, err := stackIndex(dir, len(entries)); {
+	case err != nil:
+		writeError(opt, fmt.Errorf("cd: %w", err))
+		return 1
+	case ok:
+		dir = entries[index]
+	case !set:
+		if dir = env.getenv("HOME"); dir == "" {
+			writeError(opt, errors.New("cd: HOME not set"))
+			return 1
+		}
+	case dir == "-":
+		if dir = env.getenv("OLDPWD"); dir == "" {
+			writeError(opt, errors.New("cd: OLDPWD not set"))
+			return 1
+		}
+		print = true
+	case strings.HasPrefix(dir, "~"):
+		var home string
+		if home, err = expandHome(dir); err != nil {
+			writeError(opt, fmt.Errorf("cd: %s: %w", dir, err))
+			return 1
+		}
+		dir = home
+	default:
+		var found bool
+		if dir, found = searchCDPath(env, dir); found {
+			print = true
+		}
+	}
+
+	var pwd, err = chdir(env, dir, physical)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		writeError(opt, fmt.Errorf("cd: %s: %w", dir, err))
+		return 1
+	}
+
+	if print {
+		_, _ = fmt.Fprintln(opt.Stdout, pwd)
+	}
+
+	return
+}
+
+// Cd 切换进程的工作目录，PWD 和 OLDPWD 保存在进程的环境变量中
+func Cd(opt types.Option, args []string) (code int) {
+	return cd(opt, args, processEnv{}, nil)
+}
+
+// cd 切换 shell 的工作目录，PWD 和 OLDPWD 为 shell 变量
+func (sh *Gosh) cd(opt types.Option, args []string) (code int) {
+	return cd(opt, args, sh, sh.dirstack)
+}
+
+// pwd 输出 shell 的工作目录，-L 时使用 shell 变量 PWD，box 的 pwd 命令读取的是进程的环境变量
+func (sh *Gosh) pwd(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, pwdUsage)
+		return
+	}
+
+	var physical bool
+
+	args, err := parseOptions(args[1:], "LP", func(option byte, value string) error {
+		physical = option == 'P'
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("pwd: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, pwdUsage)
+		return 2
+	}
+
+	if len(args) > 0 {
+		writeError(opt, errors.New("pwd: too many arguments"))
+		return 1
+	}
+
+	var dir string
+	if physical {
+		if dir, err = os.Getwd(); err == nil {
+			dir, err = filepath.EvalSymlinks(dir)
+		}
+	} else if dir, err = os.Getwd(); err == nil {
+		dir = workdir(sh)
+	}
+
+	if err != nil {
+		writeError(opt, fmt.Errorf("pwd: error retrieving current directory: %w", err))
+		return 1
+	}
+
+	_, _ = fmt.Fprintln(opt.Stdout, dir)
+
+	return
+}
+
+// showDir 目录的显示形式，long 为 false 时将 HOME 开头的目录显示为 `~`
+func (sh *Gosh) showDir(dir string, long bool) string {
+	var home = sh.getenv("HOME")
+	if long || home == "" || home == "/" {
+		return dir
+	}
+
+	if dir == home {
+		return "~"
+	}
+
+	if rest, ok := strings.CutPrefix(dir, home+string(filepath.Separator)); ok {
+		return "~" + string(filepath.Separator) + rest
+	}
+
+	return dir
+}
+
+// printDirs 输出目录栈，第 0 个为当前目录
+func (sh *Gosh) printDirs(opt types.Option, long, lines, verbose bool) {
+	var entries = append([]string{workdir(sh)}, sh.dirstack...)
+
+	for i, dir := range entries {
+		dir = sh.showDir(dir, long)
+
+		switch {
+		case verbose:
+			_, _ = fmt.Fprintf(opt.Stdout, "%2d  %s\n", i, dir)
+		case lines:
+			_, _ = fmt.Fprintln(opt.Stdout, dir)
+		case i > 0:
+			_, _ = fmt.Fprint(opt.Stdout, " ", dir)
+		default:
+			_, _ = fmt.Fprint(opt.Stdout, dir)
+		}
+	}
+
+	if !verbose && !lines {
+		_, _ = fmt.Fprintln(opt.Stdout)
+	}
+}
+
+// dirs 显示或清空目录栈
+func (sh *Gosh) dirs(opt types.Option, args []string) (code int) {
+	var (
+		clear, long, lines, verbose bool
+		index                       = -1
+	)
+
+	for _, arg := range args[1:] {
+		if arg == "-h" || arg == "--help" {
+			_, _ = fmt.Fprint(opt.Stdout, dirsUsage)
+			return
+		}
+
+		var i, ok, err = stackIndex(arg, len(sh.dirstack)+1)
+		switch {
+		case err != nil:
+			writeError(opt, fmt.Errorf("dirs: %w", err))
+			return 1
+		case ok:
+			index = i
+			continue
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			writeError(opt, fmt.Errorf("dirs: %s: invalid argument", arg))
+			_, _ = fmt.Fprint(opt.Stderr, dirsUsage)
+			return 2
+		}
+
+		for _, c := range arg[1:] {
+			switch c {
+			case 'c':
+				clear = true
+			case 'l':
+				long = true
+			case 'p':
+				lines = true
+			case 'v':
+				verbose = true
+			default:
+				writeError(opt, fmt.Errorf("dirs: -%c: invalid option", c))
+				_, _ = fmt.Fprint(opt.Stderr, dirsUsage)
+				return 2
+			}
+		}
+	}
+
+	switch {
+	case clear:
+		sh.dirstack = nil
+	case index >= 0:
+		var entries = append([]string{workdir(sh)}, sh.dirstack...)
+		_, _ = fmt.Fprintln(opt.Stdout, sh.showDir(entries[index], long))
+	default:
+		sh.printDirs(opt, long, lines, verbose)
+	}
+
+	return
+}
+
+// stackArgs 解析 pushd 和 popd 的参数，返回 -n 和剩余的一个参数
+func stackArgs(opt types.Option, args []string, usage string) (noChange bool, arg string, code int) {
+	for _, a := range args[1:] {
+		switch {
+		case a == "-h" || a == "--help":
+			_, _ = fmt.Fprint(opt.Stdout, usage)
+			return false, "", -1
+		case a == "-n":
+			noChange = true
+		case arg != "":
+			writeError(opt, fmt.Errorf("%s: too many arguments", args[0]))
+			return false, "", 1
+		default:
+			arg = a
+		}
+	}
+
+	return
+}
+
+// pushd 将目录加入目录栈并切换到该目录，或旋转目录栈
+func (sh *Gosh) pushd(opt types.Option, args []string) (code int) {
+	var noChange, arg, c = stackArgs(opt, args, pushdUsage)
+	if c != 0 {
+		return max(c, 0)
+	}
+
+	var entries = append([]string{workdir(sh)}, sh.dirstack...)
+
+	var index, rotate, err = stackIndex(arg, len(entries))
+	switch {
+	case err != nil:
+		writeError(opt, fmt.Errorf("pushd: %w", err))
+		return 1
+	case arg == "" && len(entries) < 2:
+		writeError(opt, errors.New("pushd: no other directory"))
+		return 1
+	case arg == "":
+		// 交换栈顶的两个目录
+		entries[0], entries[1] = entries[1], entries[0]
+	case rotate:
+		entries = append(entries[index:], entries[:index]...)
+	case noChange:
+		// -n 只将目录加入目录栈，不切换目录
+		if !filepath.IsAbs(arg) {
+			arg = filepath.Join(entries[0], arg)
+		}
+		sh.dirstack = slices.Insert(sh.dirstack, 0, filepath.Clean(arg))
+		sh.printDirs(opt, false, false, false)
+		return
+	default:
+		if code = sh.cd(opt, []string{"cd", "--", arg}); code != 0 {
+			return
+		}
+		sh.dirstack = slices.Insert(sh.dirstack, 0, entries[0])
+		sh.printDirs(opt, false, false, false)
+		return
+	}
+
+	if code = sh.cd(opt, []string{"cd", "--", entries[0]}); code != 0 {
+		return
+	}
+
+	sh.dirstack = entries[1:]
+	sh.printDirs(opt, false, false, false)
+
+	return
+}
+
+// popd 从目录栈中删除目录，删除栈顶时切换到新的栈顶目录
+func (sh *Gosh) popd(opt types.Option, args []string) (code int) {
+	var noChange, arg, c = stackArgs(opt, args, popdUsage)
+	if c != 0 {
+		return max(c, 0)
+	}
+
+	if len(sh.dirstack) == 0 {
+		writeError(opt, errors.New("popd: directory stack empty"))
+		return 1
+	}
+
+	var index, ok, err = stackIndex(arg, len(sh.dirstack)+1)
+	switch {
+	case err != nil:
+		writeError(opt, fmt.Errorf("popd: %w", err))
+		return 1
+	case arg != "" && !ok:
+		writeError(opt, fmt.Errorf("popd: %s: invalid argument", arg))
+		_, _ = fmt.Fprint(opt.Stderr, popdUsage)
+		return 2
+	}
+
+	switch {
+	case index > 0:
+		sh.dirstack = slices.Delete(sh.dirstack, index-1, index)
+	case noChange:
+		// 不切换目录时删除当前目录之后的目录
+		sh.dirstack = sh.dirstack[1:]
+	default:
+		if code = sh.cd(opt, []string{"cd", "--", sh.dirstack[0]}); code != 0 {
+			return
+		}
+		sh.dirstack = sh.dirstack[1:]
+	}
+
+	sh.printDirs(opt, false, false, false)
+
+	return
+}
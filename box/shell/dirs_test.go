@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirStack(t *testing.T) {
+	var wd, err = os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	var home string
+	if home, err = filepath.EvalSymlinks(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"a/sub", "b", "c"} {
+		if err = os.MkdirAll(filepath.Join(home, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = os.Symlink(filepath.Join(home, "a", "sub"), filepath.Join(home, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			script:   "cd a; pushd ../b; dirs; popd; echo $PWD",
+			expected: "~/b ~/a\n~/b ~/a\n~/a\n" + home + "/a\n",
+		},
+		{
+			script:   "pushd a >/dev/null; pushd ../b >/dev/null; pushd ../c >/dev/null; dirs -v; dirs -l +1; dirs -0",
+			expected: " 0  ~/c\n 1  ~/b\n 2  ~/a\n 3  ~\n" + home + "/b\n~\n",
+		},
+		{
+			script:   "pushd a >/dev/null; pushd ../b >/dev/null; pushd ../c >/dev/null; pushd +2; pushd -0; echo $PWD",
+			expected: "~/a ~ ~/c ~/b\n~/b ~/a ~ ~/c\n" + home + "/b\n",
+		},
+		{
+			script:   "pushd a >/dev/null; pushd ../b >/dev/null; pushd; popd +1; popd -n; dirs -p",
+			expected: "~/a ~/b ~\n~/a ~\n~/a\n~/a\n",
+		},
+		{
+			script:   "pushd a >/dev/null; pushd ../b >/dev/null; cd -0; echo $PWD; cd +1; echo $PWD",
+			expected: home + "\n" + home + "/a\n",
+		},
+		{
+			script:   "pushd -n b; echo $PWD",
+			expected: "~ ~/b\n" + home + "\n",
+		},
+		{
+			script:   "pushd a >/dev/null; dirs -c; dirs; popd; pushd; dirs +1",
+			expected: "~/a\nshell: popd: directory stack empty\nshell: pushd: no other directory\nshell: dirs: +1: directory stack index out of range\n",
+		},
+		{
+			script:   "cd a; cd -; echo $OLDPWD",
+			expected: home + "\n" + home + "/a\n",
+		},
+		{
+			script:   "CDPATH=:$HOME; cd /; cd b; echo $PWD; cd sub; echo $?",
+			expected: home + "/b\n" + home + "/b\nshell: cd: sub: no such file or directory\n1\n",
+		},
+		{
+			script:   "cd link; echo $PWD; cd ..; echo $PWD; cd -P link/..; echo $PWD; cd -L $HOME/link; cd -P .; echo $PWD",
+			expected: home + "/link\n" + home + "\n" + home + "/a\n" + home + "/a/sub\n",
+		},
+		{
+			// pwd 读取 shell 的 PWD，-P 时解析符号链接
+			script:   "cd link; pwd; pwd -P; pwd -LP; pwd -L; pwd x; pwd -x",
+			expected: home + "/link\n" + home + "/a/sub\n" + home + "/a/sub\n" + home + "/link\nshell: pwd: too many arguments\nshell: pwd: -x: invalid option\n" + pwdUsage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			if err := os.Chdir(home); err != nil {
+				t.Fatal(err)
+			}
+
+			var sh, stdout = newTestGosh()
+			_ = sh.setvar("HOME", home)
+			_ = sh.setvar("PWD", home)
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,723 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultIFS IFS 未设置时的字段分隔符
+const defaultIFS = " \t\n"
+
+// isIFSSpace 是否为 IFS 空白字符
+func isIFSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// fields 展开结果的字段
+type fields struct {
+	ifs    string
+	list   []string
+	field  strings.Builder
+	exists bool // 当前字段是否存在（包括空字段）
+}
+
+// literal 追加不参与分割的文本
+func (f *fields) literal(s string) {
+	f.field.WriteString(s)
+	f.exists = true
+}
+
+// next 结束当前字段
+func (f *fields) next() {
+	f.list = append(f.list, f.field.String())
+	f.field.Reset()
+	f.exists = false
+}
+
+// split 追加按 IFS 分割的文本
+func (f *fields) split(s string) {
+	for i := 0; i < len(s); {
+		var c = s[i]
+		if strings.IndexByte(f.ifs, c) < 0 {
+			f.field.WriteByte(c)
+			f.exists = true
+			i++
+			continue
+		}
+
+		// 空白分隔符合并，紧随的一个非空白分隔符视为同一个分隔
+		if isIFSSpace(c) {
+			if f.exists {
+				f.next()
+			}
+			for i < len(s) && isIFSSpace(s[i]) && strings.IndexByte(f.ifs, s[i]) >= 0 {
+				i++
+			}
+			if i >= len(s) || strings.IndexByte(f.ifs, s[i]) < 0 {
+				continue
+			}
+		}
+
+		f.next()
+		for i++; i < len(s) && isIFSSpace(s[i]) && strings.IndexByte(f.ifs, s[i]) >= 0; i++ {
+		}
+	}
+}
+
+// result 展开得到的全部字段
+func (f *fields) result() []string {
+	if f.exists {
+		f.next()
+	}
+
+	return f.list
+}
+
+// ifs 字段分隔符
+func (sh *Gosh) ifs() string {
+	if value, set := sh.lookup("IFS"); set {
+		return value
+	}
+
+	return defaultIFS
+}
+
+// matchBrace 查找 `${` 对应的 `}` 的位置
+func matchBrace(word string, start int) int {
+	var depth int
+	for i := start; i < len(word); i++ {
+		switch word[i] {
+		case markEscape:
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// readParam 读取 `$` 之后的参数，返回参数内容（`${}` 内部）和结束位置
+func readParam(word string, start int) (param string, end int, braced bool) {
+	if start >= len(word) {
+		return "", start, false
+	}
+
+	switch c := word[start]; {
+	case c == '{':
+		if end = matchBrace(word, start); end < 0 {
+			return "", start, false
+		}
+		return word[start+1 : end], end + 1, true
+	case isParam(c):
+		return word[start : start+1], start + 1, false
+	}
+
+	end = start
+	for end < len(word) && isName(word[start:end+1]) {
+		end++
+	}
+
+	return word[start:end], end, false
+}
+
+// writeList 展开多个值：双引号内的 @ 每个值为独立字段，* 以 IFS 的第一个字符连接，
+// 引号外每个值分别参与字段分割
+func (sh *Gosh) writeList(f *fields, values []string, at, quoted bool) {
+	switch {
+	case quoted && at:
+		for i, value := range values {
+			if i > 0 {
+				f.next()
+			}
+			f.literal(value)
+		}
+	case quoted:
+		var sep = sh.ifs()
+		if len(sep) > 1 {
+			sep = sep[:1]
+		}
+		f.literal(strings.Join(values, sep))
+	default:
+		for i, value := range values {
+			if i > 0 && f.exists {
+				f.next()
+			}
+			f.split(value)
+		}
+	}
+}
+
+// listParam 展开为多个值的参数：位置参数 @、* 和数组的全部元素 name[@]、name[*]，
+// keys 为每个值的下标，位置参数从 1 开始
+func (sh *Gosh) listParam(name string) (values, keys []string, at, ok bool) {
+	if name == "@" || name == "*" {
+		for i := range sh.args {
+			keys = append(keys, strconv.Itoa(i+1))
+		}
+		return sh.args, keys, name == "@", true
+	}
+
+	if base, sub, found := parseSubscript(name); found && (sub == "@" || sub == "*") {
+		values, _ = sh.arrayValues(base)
+		return values, sh.arrayKeys(base), sub == "@", true
+	}
+
+	return
+}
+
+// parseRange 拆分 `${name:offset:length}` 的偏移和长度
+func (sh *Gosh) parseRange(word string) (offset, length int, hasLength bool, err error) {
+	var end = len(word)
+	for i := 0; i < len(word); i++ {
+		if word[i] == markEscape {
+			i++
+		} else if word[i] == ':' {
+			end, hasLength = i, true
+			break
+		}
+	}
+
+	if offset, err = sh.evalInt(word[:end]); err != nil || !hasLength {
+		return
+	}
+
+	length, err = sh.evalInt(word[end+1:])
+
+	return
+}
+
+// sliceRange 计算 n 个元素中偏移为 offset、长度为 length 的范围，负数从末尾计算
+func sliceRange(n, offset, length int, hasLength bool) (start, end int, err error) {
+	if start = offset; start < 0 {
+		start += n
+	}
+
+	if start < 0 || start > n {
+		return 0, 0, nil
+	}
+
+	switch end = n; {
+	case !hasLength:
+	case length < 0:
+		if end = n + length; end < start {
+			return 0, 0, fmt.Errorf("%d: substring expression < 0", length)
+		}
+	case start+length < n:
+		end = start + length
+	}
+
+	return
+}
+
+// expandList 展开多个值的参数及其操作符，`:` 按下标截取，其余的转换作用于每个值
+func (sh *Gosh) expandList(f *fields, name string, values, keys []string, at, quoted bool, op, word string) (err error) {
+	var set = len(values) > 0
+	if strings.HasPrefix(op, ":") && (len(values) == 0 || len(values) == 1 && values[0] == "") {
+		set = false
+	}
+
+	switch op {
+	case "":
+	case ":":
+		var offset, length, hasLength, e = sh.parseRange(word)
+		if e != nil {
+			return e
+		}
+
+		// 位置参数的偏移 0 为 $0，数组的偏移为下标
+		var first int
+		if name == "@" || name == "*" {
+			if values, keys = append([]string{sh.name}, values...), append([]string{"0"}, keys...); offset < 0 {
+				offset += len(values)
+			}
+		} else if offset < 0 && len(keys) > 0 {
+			var last, _ = strconv.Atoi(keys[len(keys)-1])
+			offset += last + 1
+		}
+
+		if offset < 0 {
+			return
+		}
+
+		for first < len(keys) {
+			if index, e := strconv.Atoi(keys[first]); e != nil || index >= offset {
+				break
+			}
+			first++
+		}
+
+		var end = len(values)
+		if hasLength {
+			if length < 0 {
+				return fmt.Errorf("%d: substring expression < 0", length)
+			}
+			end = min(first+length, end)
+		}
+
+		values = values[first:end]
+	case "-", ":-":
+		if !set {
+			return sh.expandInto(f, word, quoted, true)
+		}
+	case "+", ":+":
+		if set {
+			return sh.expandInto(f, word, quoted, true)
+		}
+		return
+	default:
+		var transform func(string) string
+		if transform, err = sh.transform(op, word); err != nil {
+			return
+		}
+
+		if transform == nil {
+			return fmt.Errorf("%s: bad substitution", unmark(name))
+		}
+
+		var transformed = make([]string, len(values))
+		for i, value := range values {
+			transformed[i] = transform(value)
+		}
+		values = transformed
+	}
+
+	sh.writeList(f, values, at, quoted)
+
+	return
+}
+
+// expandParam 展开参数，quoted 表示位于双引号内
+func (sh *Gosh) expandParam(f *fields, param string, braced, quoted bool) (err error) {
+	var write = f.split
+	if quoted {
+		write = f.literal
+	}
+
+	// $@ 和 $* 的每个位置参数分别展开
+	if param == "@" || param == "*" {
+		sh.writeList(f, sh.args, param == "@", quoted)
+		return
+	}
+
+	if !braced {
+		var value, _ = sh.lookup(param)
+		write(value)
+		return
+	}
+
+	if len(param) > 1 && param[0] == '#' {
+		// ${#name[@]} 数组的元素个数，${#@} 位置参数的个数
+		if values, _, _, ok := sh.listParam(param[1:]); ok {
+			write(strconv.Itoa(len(values)))
+			return
+		}
+
+		// ${#name} 字符串长度，按字符计算
+		var value, _ = sh.lookup(param[1:])
+		write(strconv.Itoa(utf8.RuneCountInString(value)))
+		return
+	}
+
+	var indirect bool
+	if len(param) > 1 && param[0] == '!' {
+		// ${!name[@]} 数组的全部下标
+		if name, sub, ok := parseSubscript(param[1:]); ok && (sub == "@" || sub == "*") {
+			sh.writeList(f, sh.arrayKeys(name), sub == "@", quoted)
+			return
+		}
+
+		// ${!prefix*} 以 prefix 开头的变量名
+		if prefix := param[1 : len(param)-1]; isName(prefix) && (strings.HasSuffix(param, "*") || strings.HasSuffix(param, "@")) {
+			sh.writeList(f, sh.varNames(prefix), strings.HasSuffix(param, "@"), quoted)
+			return
+		}
+
+		param, indirect = param[1:], true
+	}
+
+	var name, op, word = parseParam(param)
+	if name == "" {
+		return fmt.Errorf("${%s}: bad substitution", unmark(param))
+	}
+
+	// ${!name} 以 name 的值作为参数名
+	if indirect {
+		if name, _ = sh.lookup(name); !isName(name) && !isParamName(name) {
+			if _, _, ok := parseSubscript(name); !ok {
+				return fmt.Errorf("%s: invalid indirect expansion", unmark(param))
+			}
+		}
+	}
+
+	if values, keys, at, ok := sh.listParam(name); ok {
+		return sh.expandList(f, name, values, keys, at, quoted, op, word)
+	}
+
+	var value, set = sh.lookup(name)
+
+	var transform func(string) string
+	if transform, err = sh.transform(op, word); err != nil || transform != nil {
+		if err == nil {
+			write(transform(value))
+		}
+		return
+	}
+
+	// 带 `:` 的操作符将空值视为未设置
+	if strings.HasPrefix(op, ":") && value == "" {
+		set = false
+	}
+
+	switch strings.TrimPrefix(op, ":") {
+	case "":
+		// ${name:offset:length} 按字符截取
+		if op == ":" {
+			var offset, length, hasLength, e = sh.parseRange(word)
+			if e != nil {
+				return e
+			}
+
+			var runes = []rune(value)
+			var start, end, e2 = sliceRange(len(runes), offset, length, hasLength)
+			if e2 != nil {
+				return e2
+			}
+			value = string(runes[start:end])
+		}
+		write(value)
+	case "-":
+		if set {
+			write(value)
+			return
+		}
+		return sh.expandInto(f, word, quoted, true)
+	case "=":
+		if !set {
+			if value, err = sh.expandString(word); err != nil {
+				return
+			}
+			if err = sh.assignVar(name, escapeWord(value)); err != nil {
+				return
+			}
+		}
+		write(value)
+	case "+":
+		if set {
+			return sh.expandInto(f, word, quoted, true)
+		}
+	case "?":
+		if set {
+			write(value)
+			return
+		}
+
+		var message = "parameter null or not set"
+		if word != "" {
+			if message, err = sh.expandString(word); err != nil {
+				return
+			}
+		}
+		return fmt.Errorf("%s: %s", name, message)
+	default:
+		return fmt.Errorf("${%s}: bad substitution", unmark(param))
+	}
+
+	return
+}
+
+// parseParam 拆分 `${}` 内部的参数名、操作符和单词，参数名可以带数组下标
+func parseParam(param string) (name, op, word string) {
+	var end int
+
+	switch {
+	case param == "":
+		return
+	case param[0] >= '0' && param[0] <= '9':
+		for end < len(param) && param[end] >= '0' && param[end] <= '9' {
+			end++
+		}
+	case isParam(param[0]):
+		end = 1
+	default:
+		for end < len(param) && isName(param[:end+1]) {
+			end++
+		}
+
+		// 数组下标 name[subscript]
+		if end > 0 && end < len(param) && param[end] == '[' {
+			if i := strings.IndexByte(param[end:], ']'); i > 0 {
+				end += i + 1
+			}
+		}
+	}
+
+	if end == 0 {
+		return
+	}
+
+	if name, param = param[:end], param[end:]; param == "" {
+		return
+	}
+
+	for _, o := range []string{":-", ":=", ":+", ":?", ":", "-", "=", "+", "?", "##", "#", "%%", "%", "//", "/#", "/%", "/", "^^", "^", ",,", ",", "@"} {
+		if strings.HasPrefix(param, o) {
+			return name, o, param[len(o):]
+		}
+	}
+
+	return "", "", ""
+}
+
+// isParamName 是否为特殊参数或位置参数的参数名
+func isParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	if len(name) == 1 {
+		return isParam(name[0])
+	}
+
+	return strings.Trim(name, "0123456789") == ""
+}
+
+// splitReplace 拆分 `${name/pattern/replacement}` 的模式和替换，转义和嵌套展开中的 `/` 不作为分隔
+func splitReplace(word string) (pattern, replacement string) {
+	for i := 0; i < len(word); i++ {
+		switch word[i] {
+		case markEscape:
+			i++
+		case '$':
+			if i+1 < len(word) && word[i+1] == '{' {
+				if end := matchBrace(word, i+1); end > 0 {
+					i = end
+				}
+			}
+		case '/':
+			return word[:i], word[i+1:]
+		}
+	}
+
+	return word, ""
+}
+
+// transform 返回作用于每个值的操作符的转换函数，不是此类操作符时返回 nil
+func (sh *Gosh) transform(op, word string) (transform func(string) string, err error) {
+	var pattern string
+
+	switch op {
+	case "#", "##", "%", "%%":
+		if pattern, err = sh.expandPattern(word); err != nil {
+			return
+		}
+		return func(value string) string { return trimPattern(value, pattern, op) }, nil
+	case "/", "//", "/#", "/%":
+		var replacement string
+		pattern, replacement = splitReplace(word)
+		if pattern, err = sh.expandPattern(pattern); err != nil {
+			return
+		}
+		if replacement, err = sh.expandString(replacement); err != nil {
+			return
+		}
+		return func(value string) string { return replacePattern(value, pattern, replacement, op) }, nil
+	case "^", "^^", ",", ",,":
+		if pattern, err = sh.expandPattern(word); err != nil {
+			return
+		}
+		return func(value string) string { return convertCase(value, pattern, op) }, nil
+	case "@":
+		switch word {
+		case "Q":
+			return func(value string) string { return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'" }, nil
+		case "U":
+			return strings.ToUpper, nil
+		case "L":
+			return strings.ToLower, nil
+		case "u":
+			return func(value string) string { return convertCase(value, "", "^") }, nil
+		}
+		return nil, fmt.Errorf("@%s: bad substitution", unmark(word))
+	}
+
+	return
+}
+
+// expandInto 展开单词并追加到字段，quoted 表示整个单词位于双引号内，
+// nested 表示单词为 `${}` 的操作数，其中未加引号的文本同样参与字段分割
+func (sh *Gosh) expandInto(f *fields, word string, quoted, nested bool) (err error) {
+	for i := 0; i < len(word); i++ {
+		var (
+			c = word[i]
+			q = quoted
+		)
+
+		switch c {
+		case markEscape:
+			if i++; i < len(word) {
+				f.literal(word[i : i+1])
+			}
+			continue
+		case markEmpty:
+			f.literal("")
+			continue
+		case markArray:
+			if f.exists {
+				f.next()
+			}
+			continue
+		case markQuoted:
+			if i+1 >= len(word) || word[i+1] != '$' {
+				continue
+			}
+			i, c, q = i+1, '$', true
+		}
+
+		if c != '$' {
+			if nested && !q {
+				f.split(word[i : i+1])
+			} else {
+				f.literal(word[i : i+1])
+			}
+			continue
+		}
+
+		var (
+			param  string
+			end    int
+			braced bool
+		)
+
+		if param, end, braced = readParam(word, i+1); param == "" {
+			if end < len(word) && word[end] == '{' {
+				return errors.New("unexpected EOF while looking for matching `}'")
+			}
+			f.literal("$")
+			continue
+		}
+
+		if err = sh.expandParam(f, param, braced, q); err != nil {
+			return
+		}
+
+		i = end - 1
+	}
+
+	return
+}
+
+// expandPattern 展开模式，引号内和转义的字符按字面量匹配，未加引号的展开结果仍作为模式
+func (sh *Gosh) expandPattern(word string) (pattern string, err error) {
+	return sh.expandQuoting(word, escapePattern)
+}
+
+// expandQuoting 展开单词，引号内和转义的部分由 escape 转义，其余部分保留特殊字符
+func (sh *Gosh) expandQuoting(word string, escape func(string) string) (pattern string, err error) {
+	var sb strings.Builder
+	for i := 0; i < len(word); i++ {
+		var (
+			c      = word[i]
+			quoted bool
+		)
+
+		switch c {
+		case markEscape:
+			if i++; i < len(word) {
+				sb.WriteString(escape(word[i : i+1]))
+			}
+			continue
+		case markEmpty, markArray:
+			continue
+		case markQuoted:
+			if i+1 >= len(word) || word[i+1] != '$' {
+				continue
+			}
+			i, c, quoted = i+1, '$', true
+		}
+
+		if c != '$' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		var param, end, braced = readParam(word, i+1)
+		if param == "" {
+			sb.WriteByte('$')
+			continue
+		}
+
+		var f fields
+		if err = sh.expandParam(&f, param, braced, true); err != nil {
+			return
+		}
+
+		var value = strings.Join(f.result(), " ")
+		if quoted {
+			value = escape(value)
+		}
+		sb.WriteString(value)
+
+		i = end - 1
+	}
+
+	return sb.String(), nil
+}
+
+// expand 展开单词为字段
+func (sh *Gosh) expand(word string) (list []string, err error) {
+	var f = fields{ifs: sh.ifs()}
+
+	if err = sh.expandInto(&f, word, false, false); err != nil {
+		return
+	}
+
+	list = f.result()
+	sh.onExpand(word, list)
+
+	return
+}
+
+// expandString 展开单词为字符串，不进行字段分割
+func (sh *Gosh) expandString(word string) (value string, err error) {
+	var f = fields{ifs: ""}
+
+	if err = sh.expandInto(&f, word, false, false); err != nil {
+		return
+	}
+
+	var list = f.result()
+	sh.onExpand(word, list)
+
+	return strings.Join(list, " "), nil
+}
+
+// escapeWord 转义展开结果，作为单词再次展开时得到原值
+func escapeWord(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		writeLiteral(&sb, value[i])
+	}
+
+	return sb.String()
+}
+
+// expandWords 展开多个单词
+func (sh *Gosh) expandWords(words []string) (args []string, err error) {
+	for _, word := range words {
+		var list []string
+		if list, err = sh.expand(word); err != nil {
+			return
+		}
+		args = append(args, list...)
+	}
+
+	return
+}
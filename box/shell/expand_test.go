@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			// 变量赋值与展开
+			script:   `x=1; echo $x`,
+			expected: "1\n",
+		},
+		{
+			// 默认值
+			script:   `echo "${y:-def}" ${y-unset}`,
+			expected: "def unset\n",
+		},
+		{
+			// 字段分割与双引号
+			script:   `x="a  b"; echo $x; echo "$x"`,
+			expected: "a b\na  b\n",
+		},
+		{
+			// 单引号与转义不展开
+			script:   `x=1; echo '$x' \$x`,
+			expected: "$x $x\n",
+		},
+		{
+			// 引号外反斜杠使之后的字符为字面量
+			script:   `echo \n \a\ b`,
+			expected: "n a b\n",
+		},
+		{
+			// 双引号内只转义 $ ` " \，其余的反斜杠保留
+			script:   `echo "a\nb" "\$x" "\\" "\"" "${y:-a\"b}"`,
+			expected: "a\\nb $x \\ \" a\"b\n",
+		},
+		{
+			// ANSI C 转义
+			script:   `echo $'a\tb\x41\101\u00e9\U0001F600\cA\e\'\\\q' x$''y`,
+			expected: "a\tbAAé\U0001F600\x01\x1b'\\\\q xy\n",
+		},
+		{
+			// ANSI C 转义的结果不再展开
+			script:   `x=1; echo $'$x\x24x' $"$x"`,
+			expected: "$x$x 1\n",
+		},
+		{
+			// 字符串长度
+			script:   `x=hello; echo ${#x}`,
+			expected: "5\n",
+		},
+		{
+			// 操作数中双引号内的参数展开
+			script:   `p=1; echo ${x:-"$p"} ${x:-"a${p}b"}`,
+			expected: "1 a1b\n",
+		},
+		{
+			// 多字节字符的长度按字符计算
+			script:   `x=你好ab; echo ${#x}`,
+			expected: "4\n",
+		},
+		{
+			// 删除匹配的前缀和后缀
+			script:   `x=a.b.c; echo ${x#*.} ${x##*.} ${x%.*} ${x%%.*} ${x#x}`,
+			expected: "b.c c a.b a a.b.c\n",
+		},
+		{
+			// 引号内的模式字符按字面量匹配，? 匹配一个多字节字符
+			script:   `x='*你好*'; p='*'; echo ${x#"*"} ${x#$p} ${x%?*} ${x#"$p"?}`,
+			expected: "你好* *你好* *你好 好*\n",
+		},
+		{
+			// 替换第一个、全部、前缀和后缀的最长匹配
+			script:   `x="hello world"; echo "${x/o/0}" "${x//o/0}" "${x/#h/H}" "${x/%d/D}" "${x//[lo]}" "${x/#/>}"`,
+			expected: "hell0 world hell0 w0rld Hello world hello worlD he wrd >hello world\n",
+		},
+		{
+			// 转义和引号内的 / 不作为分隔
+			script:   `p=/usr/bin; r=:; echo ${p//\//_} "${p//"/"/$r}" ${p/u*\//x}`,
+			expected: "_usr_bin :usr:bin /xbin\n",
+		},
+		{
+			// 大小写转换，模式限定转换的字符
+			script:   `x="hello world"; y=ABC; echo ${x^} ${x^^} ${x^^[lo]} ${y,} ${y,,}`,
+			expected: "Hello world HELLO WORLD heLLO wOrLd aBC abc\n",
+		},
+		{
+			// 间接展开和变量名前缀
+			script:   `x=1; ref=x; echo ${!ref} ${!ref:+set}; set -- a b; n=2; echo ${!n}; host_a=1 host_b=2; echo ${!host_*}`,
+			expected: "1 set\nb\nhost_a host_b\n",
+		},
+		{
+			// 变换操作符
+			script:   `q="it's"; x=ab; echo ${q@Q} ${x@U} ${x@u}; a=(x y); echo "${a[@]@Q}" ${a[@]^}`,
+			expected: "'it'\\''s' AB Ab\n'x' 'y' X Y\n",
+		},
+		{
+			// 临时变量不影响 shell 变量
+			script:   `x=1; x=2 true; echo $x`,
+			expected: "1\n",
+		},
+		{
+			// 上个命令的退出码
+			script:   `false; echo $?`,
+			expected: "1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal("run failed:", err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const getoptsUsage = `getopts: getopts optstring name [arg ...]
+    Parse option arguments.
+
+    Getopts is used by shell procedures to parse positional parameters
+    as options.
+
+    OPTSTRING contains the option letters to be recognized; if a letter
+    is followed by a colon, the option is expected to have an argument,
+    which should be separated from it by white space.
+
+    Each time it is invoked, getopts will place the next option in the
+    shell variable $name, initializing name if it does not exist, and
+    the index of the next argument to be processed into the shell
+    variable OPTIND.  OPTIND is initialized to 1 each time the shell or
+    a shell script is invoked.  When an option requires an argument,
+    getopts places that argument into the shell variable OPTARG.
+
+    If the first character of OPTSTRING is a colon, getopts uses silent
+    error reporting: no error messages are printed, an invalid option
+    sets name to '?' and OPTARG to the option character, and a missing
+    option argument sets name to ':' and OPTARG to the option character.
+    If the shell variable OPTERR has the value 0, getopts disables the
+    printing of error messages.
+
+    Getopts normally parses the positional parameters, but if arguments
+    are supplied as ARG values, they are parsed instead.
+
+    Exit Status:
+    Returns success if an option is found; fails if the end of options is
+    encountered or an error occurs.
+`
+
+// getopts 解析位置参数中的选项
+func (sh *Gosh) getopts(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, getoptsUsage)
+		return
+	}
+
+	if len(args) < 3 {
+		writeError(opt, fmt.Errorf("getopts: usage: getopts optstring name [arg ...]"))
+		return 2
+	}
+
+	var (
+		spec   = args[1]
+		name   = args[2]
+		params = sh.args
+		silent = strings.HasPrefix(spec, ":")
+	)
+
+	if !isName(name) {
+		writeError(opt, fmt.Errorf("getopts: `%s': not a valid identifier", name))
+		return 1
+	}
+
+	if len(args) > 3 {
+		params = args[3:]
+	}
+
+	if silent {
+		spec = spec[1:]
+	}
+
+	if value, _ := sh.lookup("OPTERR"); value == "0" {
+		silent = true
+	}
+
+	var optind, err = strconv.Atoi(sh.getenv("OPTIND"))
+	if err != nil || optind < 1 {
+		optind, err = 1, nil
+	}
+
+	var c byte
+	if c, code = sh.nextOpt(params, &optind); code != 0 {
+		sh.unsetvar("OPTARG")
+		if err = sh.setOptvar(name, "?", optind); err != nil {
+			writeError(opt, fmt.Errorf("getopts: %w", err))
+			return 2
+		}
+		return
+	}
+
+	var (
+		index = strings.IndexByte(spec, c)
+		value = "?"
+		arg   string
+		set   bool
+	)
+
+	switch {
+	case c == ':' || index < 0:
+		// 非法选项
+		if silent {
+			arg, set = string(c), true
+		} else {
+			_, _ = fmt.Fprintf(opt.Stderr, "%s: illegal option -- %c\n", sh.name, c)
+		}
+	case index+1 < len(spec) && spec[index+1] == ':':
+		// 选项参数可以紧跟选项或作为下一个参数
+		switch {
+		case sh.optpos > 0:
+			arg, set, value = params[optind-1][sh.optpos:], true, string(c)
+			optind, sh.optpos = optind+1, 0
+		case optind <= len(params):
+			arg, set, value = params[optind-1], true, string(c)
+			optind++
+		case silent:
+			arg, set, value = string(c), true, ":"
+		default:
+			_, _ = fmt.Fprintf(opt.Stderr, "%s: option requires an argument -- %c\n", sh.name, c)
+		}
+	default:
+		value = string(c)
+	}
+
+	if set {
+		err = sh.setvar("OPTARG", arg)
+	} else {
+		sh.unsetvar("OPTARG")
+	}
+
+	if err == nil {
+		err = sh.setOptvar(name, value, optind)
+	}
+
+	if err != nil {
+		writeError(opt, fmt.Errorf("getopts: %w", err))
+		return 2
+	}
+
+	return
+}
+
+// nextOpt 读取下一个选项字符，没有更多选项时返回 1
+func (sh *Gosh) nextOpt(params []string, optind *int) (c byte, code int) {
+	if *optind > len(params) {
+		sh.optpos = 0
+		return 0, 1
+	}
+
+	var arg = params[*optind-1]
+
+	// 参数列表变化后上次的位置可能不在当前参数中
+	if sh.optpos >= len(arg) || arg[0] != '-' {
+		sh.optpos = 0
+	}
+
+	if sh.optpos == 0 {
+		if arg == "--" {
+			*optind++
+			return 0, 1
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			return 0, 1
+		}
+
+		sh.optpos = 1
+	}
+
+	c = arg[sh.optpos]
+
+	// 当前参数的选项已读完时指向下一个参数
+	if sh.optpos++; sh.optpos >= len(arg) {
+		*optind, sh.optpos = *optind+1, 0
+	}
+
+	return c, 0
+}
+
+// setOptvar 设置选项变量和 OPTIND，保留当前参数中的解析位置
+func (sh *Gosh) setOptvar(name, value string, optind int) (err error) {
+	if err = sh.setvar(name, value); err != nil {
+		return
+	}
+
+	var optpos = sh.optpos
+	defer func() { sh.optpos = optpos }()
+
+	return sh.setvar("OPTIND", strconv.Itoa(optind))
+}
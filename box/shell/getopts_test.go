@@ -0,0 +1,84 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetopts(t *testing.T) {
+	var tests = []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{
+			name: "options",
+			script: `set -- -a -b x -cbY rest
+getopts ab:c opt; echo "$opt ${OPTARG-}"
+getopts ab:c opt; echo "$opt ${OPTARG-}"
+getopts ab:c opt; echo "$opt ${OPTARG-}"
+getopts ab:c opt; echo "$opt ${OPTARG-}"
+getopts ab:c opt; echo "$? $opt $OPTIND"`,
+			expected: "a \nb x\nc \nb Y\n1 ? 5\n",
+		},
+		{
+			name:     "positional",
+			script:   `set -- -x -- -y; getopts x opt; echo $opt; getopts x opt; echo "$? $OPTIND"`,
+			expected: "x\n1 3\n",
+		},
+		{
+			name:     "invalid",
+			script:   `getopts a opt -z; echo "$? $opt ${OPTARG-unset}"`,
+			expected: "gosh: illegal option -- z\n0 ? unset\n",
+		},
+		{
+			name:     "silent",
+			script:   `getopts :a: opt -z; echo "$opt $OPTARG"; OPTIND=1; getopts :a: opt -a; echo "$opt $OPTARG"`,
+			expected: "? z\n: a\n",
+		},
+		{
+			name:     "missing",
+			script:   `getopts a: opt -a; echo "$? $opt"`,
+			expected: "gosh: option requires an argument -- a\n0 ?\n",
+		},
+		{
+			name:     "reset",
+			script:   `getopts ab opt -ab; echo $opt; OPTIND=1; getopts ab opt -ab; echo $opt`,
+			expected: "a\na\n",
+		},
+		{
+			name:     "args",
+			script:   `getopts ab opt -ab; getopts ab opt -x; echo "$? $opt $OPTIND"`,
+			expected: "gosh: illegal option -- x\n0 ? 2\n",
+		},
+		{
+			name:     "set",
+			script:   `set -- -ab; getopts ab opt; set -- -x; getopts xy opt; echo "$? $opt $OPTIND"`,
+			expected: "0 x 2\n",
+		},
+		{
+			name:     "shift",
+			script:   `set -- -ab -c; getopts abc opt; shift; getopts abc opt; echo "$opt $OPTIND"`,
+			expected: "c 2\n",
+		},
+		{
+			name:     "restricted",
+			script:   `set -r; getopts a PATH -a; echo $?`,
+			expected: "shell: getopts: PATH: restricted: cannot modify\n2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal("run failed:", err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
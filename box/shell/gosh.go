@@ -6,10 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
 
@@ -22,6 +26,46 @@ type Gosh struct {
 	box.Process
 	Builtin map[string]types.MainFunc // 内置命令
 	Command map[string]types.NewFunc  // 系统命令
+	Allow   []string                  // 受限模式下允许执行的系统命令，nil 时不限制
+	Hook    Hook                      // 命令执行的钩子，nil 时不调用
+	Context context.Context           // 传递给钩子的上下文，nil 时为 context.Background()
+
+	mutex  sync.RWMutex
+	vars   map[string]*Variable // shell 变量
+	name   string               // $0
+	args   []string             // 位置参数
+	optpos int                  // getopts 在当前参数中的位置
+	files  map[int]*os.File     // exec 打开的文件描述符（3 及以上）
+	opened []*os.File           // exec 打开的文件，不再引用时关闭
+	frames []types.Option       // 嵌套执行时保存的标准输入输出
+	status int                  // 上个命令的退出码
+	exited bool                 // 是否已执行 exit
+
+	traps       map[string]string       // trap 设置的命令
+	pending     []os.Signal             // 等待执行 trap 的信号
+	signaled    chan struct{}           // 收到信号时通知执行 trap
+	jobs        map[int]func(os.Signal) // 前台运行的命令，用于转发信号
+	jobID       int                     // 前台命令的编号
+	intrap      bool                    // 是否正在执行 trap 命令
+	interactive bool                    // 是否为交互式 shell
+	posix       bool                    // 是否为 POSIX 模式
+	restricted  bool                    // 是否为受限模式
+	script      string                  // 正在执行的启动文件，用于报告错误位置
+	line        int                     // 正在执行的命令所在的行号
+	timers      []*timer                // 正在计时的管道
+	dirstack    []string                // pushd 保存的目录栈，不包括当前目录
+	hashes      map[string]*hashEntry   // hash 记住的外部命令路径
+	hashPath    string                  // hashes 对应的 PATH
+	calls       []callFrame             // 执行脚本和 source 的调用栈
+	debugger    *debugger               // --debugger 启动的调试器
+
+	aliases map[string]string // 别名
+	shopts  map[string]bool   // shopt 设置的选项
+	options map[string]bool   // set -o 设置的选项
+
+	late map[io.Reader]*lateReader // read 超时后仍在进行的读取
+
+	onStart func(pid int) // 协程的命令开始执行时调用一次，pid 为外部命令的进程号，shell 内执行的命令为 0
 }
 
 func (sh *Gosh) ps1(option types.Option) {
@@ -59,191 +103,418 @@ func (sh *Gosh) ps1(option types.Option) {
 	_, _ = fmt.Fprintf(option.Stdout, "%s@%s:%s$ ", host, usr.Username, dir)
 }
 
-func (sh *Gosh) Run(stdin io.Reader, option types.Option) (code int, err error) {
+// run 解析并依次执行输入中的命令，直到输入结束或 shell 退出
+func (sh *Gosh) run(stdin io.Reader) (code int, err error) {
 	var (
-		wg      sync.WaitGroup
-		ctx     = context.Background()
-		lexer   = NewLexer(stdin)
-		parser  = NewParser(lexer.Token())
-		command = parser.Command()
+		ctx, cancel = context.WithCancel(context.Background())
+		errs        = make(chan error, 2)
+		lexer       = NewLexer(stdin).Alias(sh.lookupAlias)
+		parser      = NewParser(lexer.Token())
+		commands    = parser.Command()
 	)
 
-	wg.Add(2)
+	// 脚本来自命令的标准输入且无法回退时逐行读取，命令可以读取脚本之后的行
+	if sh.sharedInput(stdin) {
+		lexer.Lines()
+	}
 
-	go func() {
-		defer wg.Done()
+	defer func() {
+		cancel()
+		go func() {
+			for range commands {
+			}
+		}()
+	}()
 
-		if err = lexer.Run(ctx); err != nil {
-			return
+	go func() { errs <- lexer.Run(ctx) }()
+	go func() { errs <- parser.Run(ctx) }()
+
+	for {
+		var (
+			command Command
+			ok      bool
+		)
+
+		// 等待命令时收到信号也需要执行 trap
+		select {
+		case command, ok = <-commands:
+		case <-sh.signaled:
+			if sh.trapped(); sh.exited {
+				return sh.status, nil
+			}
+			continue
+		}
+
+		if !ok {
+			break
 		}
-	}()
 
-	go func() {
-		defer wg.Done()
+		// 之前的命令已执行完成，词法分析可以继续展开别名
+		if command.sync {
+			lexer.Resume()
+			continue
+		}
+
+		// 语法错误时停止执行
+		if command.err != nil {
+			sh.writeError(sh.Option, lexer.snippet(command.err))
+			sh.status = 2
+			return 2, nil
+		}
+
+		sh.line = command.Pos.Line
+
+		if sh.debugTrap(&command); sh.exited {
+			return sh.status, nil
+		}
+
+		if code, err = sh.Exec(&command, sh.Option); err != nil {
+			return 2, err
+		}
 
-		if err = parser.Run(ctx); err != nil {
+		if sh.status = code; sh.exited {
 			return
 		}
-	}()
 
-	for command := range command {
-		if code, err = sh.Exec(&command, option); err != nil {
-			writeError(option, err)
+		if code != 0 {
+			sh.runTrap(trapErr)
+		}
+
+		if sh.trapped(); sh.exited {
+			return sh.status, nil
+		}
+	}
+
+	for range 2 {
+		var syntaxErr *SyntaxError
+		if err = <-errs; errors.As(err, &syntaxErr) {
 			return 2, err
+		} else if err != nil {
+			return 3, err
 		}
 	}
 
 	return
 }
 
+// sharedInput 输入是否为命令的标准输入且无法回退
+func (sh *Gosh) sharedInput(r io.Reader) bool {
+	if r == nil || !reflect.TypeOf(r).Comparable() || r != sh.Option.Stdin {
+		return false
+	}
+
+	if seeker, ok := r.(io.Seeker); ok {
+		var _, err = seeker.Seek(0, io.SeekCurrent)
+		return err != nil
+	}
+
+	return true
+}
+
+// Run 在当前 shell 中执行脚本，执行期间使用 option 的标准输入输出
+func (sh *Gosh) Run(stdin io.Reader, option types.Option) (code int, err error) {
+	sh.frames = append(sh.frames, sh.Option)
+	sh.Option.Stdin, sh.Option.Stdout, sh.Option.Stderr = option.Stdin, option.Stdout, option.Stderr
+
+	defer func() {
+		var frame = sh.frames[len(sh.frames)-1]
+		sh.frames = sh.frames[:len(sh.frames)-1]
+		sh.Option.Stdin, sh.Option.Stdout, sh.Option.Stderr = frame.Stdin, frame.Stdout, frame.Stderr
+	}()
+
+	if code, err = sh.run(stdin); err != nil {
+		writeError(option, err)
+	}
+
+	return
+}
+
 func (sh *Gosh) Exec(command *Command, option types.Option) (code int, err error) {
 	if command == nil {
 		return 0, errors.New("nil command")
 	}
 
-	var (
-		wg         sync.WaitGroup
-		stdin      *os.File
-		stdout     *os.File
-		thisOption = option
-	)
+	// 执行 管道，等待管道中的全部命令结束
+	var last *Command
+	if code, last, err = sh.execPipeline(command, option); err != nil {
+		return
+	}
 
-	// 执行 管道后命令（并行执行）
-	if command.Pipe != nil {
-		var pipeOption = option
+	// 已退出 shell 时不再执行后续命令
+	if sh.exited {
+		return
+	}
 
-		// 普通管道
-		//io.Pipe()
+	// 执行 与
+	if last.And != nil && code == 0 {
+		if code, err = sh.Exec(last.And, option); err != nil {
+			return
+		}
+	}
 
-		// 进程间管道
-		if stdin, stdout, err = os.Pipe(); err != nil {
+	// 执行 或
+	if last.Or != nil && code != 0 {
+		if code, err = sh.Exec(last.Or, option); err != nil {
 			return
 		}
+	}
+
+	return
+}
+
+// execPipeline 并行执行管道中的命令，最后一个命令在当前协程中执行，
+// 全部结束后将每个命令的退出码记录到 PIPESTATUS，返回管道的退出码和最后一个命令
+func (sh *Gosh) execPipeline(command *Command, option types.Option) (code int, last *Command, err error) {
+	var elements []*Command
+	for last = command; ; last = last.Pipe {
+		if elements = append(elements, last); last.Pipe == nil {
+			break
+		}
+	}
 
-		// 管道重定向
-		pipeOption.Stdin, thisOption.Stdout = stdin, stdout
+	// time 保留字统计整个管道的时间
+	if command.Time {
+		var t = sh.startTimer()
+		defer sh.stopTimer(t, command.TimePosix, option)
+	}
 
+	var (
+		wg    sync.WaitGroup
+		codes = make([]int, len(elements))
+		errs  = make([]error, len(elements))
+		stdin io.ReadCloser // 上个命令输出的管道的读端
+	)
+
+	for i, element := range elements {
 		var (
-			pipeErr  error
-			pipeCode int
+			elementOption = option
+			reader        = stdin
+			writer        io.WriteCloser
 		)
 
-		// 使用管道的退出状态码
-		defer func() {
-			if code = pipeCode; err == nil && pipeErr != nil {
-				err = pipeErr
+		if reader != nil {
+			elementOption.Stdin = reader
+		}
+
+		// 两端都在进程内执行时使用进程内管道，否则使用系统管道
+		if element.Pipe != nil {
+			if stdin, writer, err = sh.pipe(element, element.Pipe); err != nil {
+				if reader != nil {
+					_ = reader.Close()
+				}
+				break
 			}
-		}()
+			elementOption.Stdout = writer
+		}
+
+		var run = func() {
+			codes[i], errs[i] = sh.execCommand(element, elementOption)
+
+			// 关闭写端，之后的命令读到 EOF；关闭读端，之前的命令再写入时得到 EPIPE
+			if writer != nil {
+				deferClose(&errs[i], writer.Close)
+			}
+			if reader != nil {
+				deferClose(&errs[i], reader.Close)
+			}
+		}
+
+		if element.Pipe == nil {
+			run()
+			break
+		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			defer deferClose(&err, stdin.Close)
-			if pipeCode, pipeErr = sh.Exec(command.Pipe, pipeOption); err != nil {
-				return
-			}
+			run()
 		}()
 	}
 
-	// 重定向 文件输入
-	if command.Input != "" {
-		var input *os.File
-		if input, err = os.Open(command.Input); err != nil {
-			return
-		}
-		thisOption.Stdin = input
-		defer deferClose(&err, input.Close)
+	wg.Wait()
+
+	if err = errors.Join(append(errs, err)...); err != nil {
+		return
 	}
 
-	// 重定向 文档输入
-	if command.Heredoc != "" {
-		thisOption.Stdin = strings.NewReader(command.Heredoc)
+	return sh.pipeStatus(command, codes), last, nil
+}
+
+// execCommand 执行单个命令
+func (sh *Gosh) execCommand(command *Command, option types.Option) (code int, err error) {
+	var (
+		thisOption = option
+		files      = make(map[int]*os.File, len(sh.files))
+		opened     []*os.File
+		substs     []*substitution
+	)
+
+	maps.Copy(files, sh.files)
+
+	// 进程替换，命令结束后关闭管道
+	defer func() { closeSubstitutions(substs) }()
+
+	if command, err = sh.substituteCommand(command, option, files, &substs); err != nil {
+		return
 	}
 
-	// 重定向 文件输出
-	if command.Output != "" {
-		var output *os.File
-		if output, err = os.OpenFile(command.Output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644); err != nil {
-			return
-		}
-		thisOption.Stdout = output
-		defer deferClose(&err, output.Close)
+	// 展开 命令参数
+	var assigns, argv = splitAssign(command.CmdArgs())
+	if argv, err = sh.expandArgs(argv); err != nil {
+		return
 	}
 
-	// 重定向 文件追加
-	if command.Append != "" {
-		var output *os.File
-		if output, err = os.OpenFile(command.Append, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err != nil {
-			return
-		}
-		thisOption.Stdout = output
-		defer deferClose(&err, output.Close)
+	// 展开 重定向目标
+	var redirects []Redirect
+	if redirects, err = sh.expandRedirects(command); err != nil {
+		return
 	}
 
-	// 执行 当前命令
-	switch {
-	case sh.Builtin != nil && sh.Builtin[command.Path] != nil:
-		var cmd = sh.Builtin[command.Path]
+	// 命令开始和结束时调用钩子
+	if len(argv) > 0 {
+		var end = sh.commandStart(argv, redirects)
+		defer func() { end(code, err) }()
+	}
+
+	// 受限模式下禁止的命令不执行
+	if err = sh.restrict(command, assigns, argv); err != nil {
+		sh.writeError(option, err)
+		return 1, nil
+	}
+
+	// 重定向
+	if opened, err = sh.redirect(redirects, &thisOption, files); err != nil {
+		return
+	}
+
+	// exec 不带命令时，重定向永久作用于当前 shell
+	if len(argv) == 1 && argv[0] == "exec" && sh.Builtin["exec"] != nil {
+		sh.persist(thisOption, files, opened)
+		substs = nil
+	} else {
+		defer func() {
+			for _, file := range opened {
+				deferClose(&err, file.Close)
+			}
+		}()
+	}
+
+	// 变量赋值，带命令时仅作用于该命令
+	if len(argv) == 0 {
+		_, err = sh.assign(assigns, false)
+	} else if len(assigns) > 0 {
+		var restore func()
+		restore, err = sh.assign(assigns, true)
+		defer restore()
+	}
+	if err != nil {
+		return
+	}
+
+	thisOption.Env = sh.environ()
+
+	if len(argv) == 0 {
+		return
+	}
+
+	return sh.invoke(command, thisOption, argv, files)
+}
+
+// invoke 执行展开后的命令，依次查找内置命令、box 命令和外部命令
+func (sh *Gosh) invoke(command *Command, thisOption types.Option, argv []string, files map[int]*os.File) (code int, err error) {
+	var piped pipeline
+
+	switch applet := sh.applet(argv[0]); {
+	case sh.Builtin != nil && sh.Builtin[argv[0]] != nil:
+		var cmd = sh.Builtin[argv[0]]
+		sh.started(0)
 
 		if command.Background {
-			go cmd(thisOption, command.CmdArgs())
+			go cmd(thisOption, argv)
 		}
 
-		code = cmd(thisOption, command.CmdArgs())
-	case sh.Command != nil && sh.Command[command.Path] != nil:
-		var cmd = sh.Command[command.Path](thisOption)
+		code = piped.code(cmd(piped.option(thisOption, command), argv))
+	case applet != nil:
+		var cmd = applet(piped.option(thisOption, command))
+		sh.started(0)
 
 		if command.Background {
-			go cmd.Main(command.CmdArgs())
+			go cmd.Main(argv)
 		}
 
-		code = cmd.Main(command.CmdArgs())
+		var untrack = sh.track(cmd.Signal)
+		code = piped.code(cmd.Main(argv))
+		untrack()
 	default:
-		var cmd = exec.Command(command.Path, command.Args...)
+		var path, lookErr = sh.lookPath(argv[0])
+		var cmd = exec.Command(path, argv[1:]...)
+		cmd.Args[0], cmd.Err = argv[0], lookErr
 		cmd.Dir = thisOption.Dir
 		cmd.Env = thisOption.Env
 		cmd.Stdin = thisOption.Stdin
 		cmd.Stdout = thisOption.Stdout
 		cmd.Stderr = thisOption.Stderr
+		cmd.ExtraFiles = extraFiles(files)
 
-		if err = cmd.Start(); err != nil {
-			return
+		// 交互式 shell 中外部命令使用独立的进程组
+		var tty = terminalFd(cmd.Stdin)
+		if sh.interactive && !command.Background {
+			setpgid(cmd, tty >= 0)
 		}
 
-		if command.Background {
+		// 命令不存在或无法执行时不中止 shell
+		if err = cmd.Start(); err != nil {
+			sh.started(0)
+			switch {
+			case errors.Is(err, exec.ErrNotFound):
+				sh.writeError(thisOption, fmt.Errorf("%s: command not found", argv[0]))
+				code, err = 127, nil
+			case errors.Is(err, fs.ErrPermission):
+				sh.writeError(thisOption, err)
+				code, err = 126, nil
+			default:
+				return
+			}
+		} else if sh.started(cmd.Process.Pid); command.Background {
 			go func() { _ = cmd.Wait() }()
 		} else {
-			if err = cmd.Wait(); err != nil {
-				return
+			// 非交互式 shell 的外部命令与 shell 在同一进程组，直接收到终端的信号，不需要转发
+			var untrack = func() {}
+			if sh.interactive {
+				untrack = sh.track(func(signal os.Signal) { _ = killpg(cmd.Process, signal) })
 			}
 
-			code = cmd.ProcessState.ExitCode()
-		}
-	}
+			err = cmd.Wait()
+			untrack()
+			sh.childTime(cmd.ProcessState)
 
-	if stdout != nil {
-		deferClose(&err, stdout.Close)
-	}
+			// 命令结束后 shell 重新成为终端的前台进程组
+			if sh.interactive && tty >= 0 {
+				if fgErr := setForeground(tty); fgErr != nil {
+					sh.writeError(thisOption, fmt.Errorf("cannot set terminal process group: %w", fgErr))
+				}
+			}
 
-	// 执行 与
-	if command.And != nil && code == 0 {
-		if code, err = sh.Exec(command.And, option); err != nil {
-			return
-		}
-	}
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return
+			}
 
-	// 执行 或
-	if command.Or != nil && code != 0 {
-		if code, err = sh.Exec(command.Or, option); err != nil {
-			return
+			code, err = exitCode(cmd.ProcessState), nil
 		}
 	}
 
-	wg.Wait()
-
 	return
 }
 
+// started 通知命令开始执行，只通知第一个命令
+func (sh *Gosh) started(pid int) {
+	if sh.onStart != nil {
+		sh.onStart(pid)
+		sh.onStart = nil
+	}
+}
+
 func (sh *Gosh) Main(args []string) (code int) {
 	var (
 		err    error
@@ -258,86 +529,196 @@ func (sh *Gosh) Main(args []string) (code int) {
 		return 1
 	}
 
+	if len(args) > 0 {
+		sh.name, args = args[0], args[1:]
+	}
+
 	// 解析命令行参数
 	if err = set.Parse(args); err != nil {
 		writeError(option, err)
 		return 2
 	}
 
-	if opt.ShellFile != "" {
-		if filepath.Base(opt.ShellFile) == opt.ShellFile {
-			option.Stdin = strings.NewReader(opt.ShellFile)
-		} else {
-			// TODO 判断文件是否有执行权限
+	var (
+		operands   = set.Args()
+		command    bool
+		login      = isLogin(sh.name, opt)
+		restricted = isRestricted(sh.name, opt)
+	)
+
+	set.Visit(func(f *flag.Flag) { command = command || f.Name == "c" })
+
+	switch {
+	case command:
+		// -c 执行命令字符串，之后的参数依次为 $0 和位置参数
+		option.Stdin = strings.NewReader(opt.ShellFile)
+		if len(operands) > 0 {
+			sh.name, operands = operands[0], operands[1:]
+		}
+	case len(operands) > 0:
+		// 执行脚本文件，之后的参数为位置参数
+		// TODO 判断文件是否有执行权限
 
-			var file *os.File
+		var file *os.File
 
-			// 打开文件
-			if file, err = os.Open(opt.ShellFile); err != nil {
+		// 打开文件
+		if file, err = os.Open(operands[0]); err != nil {
+			writeError(option, err)
+			return 127
+		}
+
+		// 关闭文件
+		defer func() {
+			if err = file.Close(); err != nil {
 				writeError(option, err)
-				return 3
+				code = 4
 			}
+		}()
 
-			// 关闭文件
-			defer func() {
-				if err = file.Close(); err != nil {
-					writeError(option, err)
-					code = 4
-				}
-			}()
+		option.Stdin = file
+		sh.name, operands = operands[0], operands[1:]
+		defer sh.pushCall("main", sh.name)()
+	}
 
-			option.Stdin = file
-		}
+	sh.args = operands
+
+	// -n 和 --dump-ast 只解析不执行
+	if opt.NoExec || opt.DumpAST {
+		return sh.check(option.Stdin, sh.name, opt.DumpAST)
 	}
 
-	var (
-		ctx     = context.Background()
-		errs    = make(chan error, 2)
-		lexer   = NewLexer(option.Stdin)
-		parser  = NewParser(lexer.Token())
-		command = parser.Command()
-	)
+	// 从终端读取命令时为交互式 shell
+	sh.posix = opt.Posix
+	sh.interactive = opt.Interactive || !command && len(set.Args()) == 0 && terminalFd(option.Stdin) >= 0
+	defer sh.handleSignals()()
 
-	go func() { errs <- lexer.Run(ctx) }()
-	go func() { errs <- parser.Run(ctx) }()
+	// 执行启动文件，其中执行 exit 时退出
+	if sh.startup(login, opt); sh.exited {
+		return sh.exitTrap(sh.status)
+	}
 
-	sh.ps1(option)
-	for command := range command {
-		if code, err = sh.Exec(&command, option); err != nil {
-			writeError(option, err)
-			return 2
-		}
-		sh.ps1(option)
+	// 启动文件执行完成后进入受限模式
+	if restricted {
+		sh.restricted = true
 	}
 
-	for err = range errs {
-		if err != nil {
-			writeError(option, err)
-			return 3
+	// 调试器从标准输入读取调试命令，输出到标准错误
+	if opt.Debugger {
+		sh.debugger = newDebugger(sh.Option.Stdin, sh.Option.Stderr)
+	}
+
+	var run = sh.run
+	if sh.interactive {
+		run = sh.repl
+	}
+
+	if code, err = run(option.Stdin); err != nil {
+		writeError(option, err)
+	}
+
+	// 退出前处理等待中的信号，最后一个命令执行后收到的信号也执行 trap
+	if !sh.exited {
+		sh.status = code
+		if sh.trapped(); sh.exited {
+			code = sh.status
 		}
 	}
 
+	code = sh.exitTrap(code)
+
 	return
 }
 
 func NewGosh(opt types.Option) *Gosh {
-	var (
-		command = cmd.Cmd()
-		builtin = map[string]types.MainFunc{
-			"cd":   Cd,
-			"exit": Exit,
-		}
-	)
-
-	command["gosh"] = func(option types.Option) types.Process {
-		return NewGosh(option)
-	}
-
-	return &Gosh{
+	var sh = &Gosh{
 		Process: box.Process{
 			Option: opt,
 		},
-		Builtin: builtin,
-		Command: command,
+		Command:  cmd.Cmd(),
+		name:     "gosh",
+		traps:    make(map[string]string),
+		signaled: make(chan struct{}, 1),
+		jobs:     make(map[int]func(os.Signal)),
+		aliases:  make(map[string]string),
+		shopts:   make(map[string]bool),
+		options:  make(map[string]bool),
 	}
+
+	// 子 shell 继承受限模式
+	sh.Command["gosh"] = func(option types.Option) types.Process {
+		var child = NewGosh(option)
+		child.restricted, child.Allow = sh.restricted, sh.Allow
+		return child
+	}
+
+	sh.initVars(opt.Env)
+	sh.vars["OPTIND"] = &Variable{Value: "1"}
+
+	sh.Builtin = map[string]types.MainFunc{
+		"cd":      sh.cd,
+		"pushd":   sh.pushd,
+		"popd":    sh.popd,
+		"dirs":    sh.dirs,
+		"pwd":     sh.pwd,
+		"exit":    sh.exit,
+		"source":  sh.source,
+		".":       sh.source,
+		"eval":    sh.eval,
+		"exec":    sh.exec,
+		"read":    sh.read,
+		"shift":   sh.shift,
+		"set":     sh.set,
+		"getopts": sh.getopts,
+		"trap":    sh.trap,
+		"alias":   sh.alias,
+		"unalias": sh.unalias,
+		"shopt":   sh.shopt,
+		"type":    sh.typeof,
+		"command": sh.command,
+		"hash":    sh.hash,
+		"which":   sh.which,
+		"declare": sh.declare,
+		"typeset": sh.declare,
+		"unset":   sh.unset,
+		"[[":      sh.conditional,
+		"test":    sh.test,
+		"[":       sh.test,
+		"coproc":  sh.coproc,
+	}
+
+	return sh
+}
+
+// subshell 创建子 shell，复制变量、位置参数、别名和选项，子 shell 中的修改不影响当前 shell
+func (sh *Gosh) subshell(option types.Option) *Gosh {
+	var child = NewGosh(option)
+
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	child.vars = make(map[string]*Variable, len(sh.vars))
+	for name, v := range sh.vars {
+		child.vars[name] = v.clone()
+	}
+
+	child.name, child.args, child.status = sh.name, slices.Clone(sh.args), sh.status
+	child.aliases, child.shopts, child.options = maps.Clone(sh.aliases), maps.Clone(sh.shopts), maps.Clone(sh.options)
+	child.restricted, child.Allow, child.posix = sh.restricted, sh.Allow, sh.posix
+	child.Hook, child.Context = sh.Hook, sh.Context
+	child.files, child.dirstack = maps.Clone(sh.files), slices.Clone(sh.dirstack)
+
+	// 保留额外注册的内置命令和 box 命令，子 shell 的内置命令作用于子 shell
+	for name, builtin := range sh.Builtin {
+		if child.Builtin[name] == nil {
+			child.Builtin[name] = builtin
+		}
+	}
+
+	for name, applet := range sh.Command {
+		if child.Command[name] == nil {
+			child.Command[name] = applet
+		}
+	}
+
+	return child
 }
@@ -1,9 +1,15 @@
 package shell
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/zooyer/gobox/types"
 )
@@ -77,9 +83,276 @@ func TestGosh(t *testing.T) {
 		t.Log("code:", code)
 	}
 
-	for err = range errors {
-		if err != nil {
+	for range 2 {
+		if err = <-errors; err != nil {
 			t.Error(err)
 		}
 	}
 }
+
+func TestGoshMain(t *testing.T) {
+	var script = filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(script, []byte("echo $0 $#\necho \"$@\"\nshift\necho $1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		args     []string
+		code     int
+		expected string
+	}{
+		{
+			name:     "command",
+			args:     []string{"gosh", "-c", `echo $0 $#; echo "$@"`, "name", "a", "b c"},
+			expected: "name 2\na b c\n",
+		},
+		{
+			name:     "command without name",
+			args:     []string{"gosh", "-c", "echo $0 $#"},
+			expected: "gosh 0\n",
+		},
+		{
+			name:     "script",
+			args:     []string{"gosh", script, "a", "-b", "c"},
+			expected: script + " 3\na -b c\n-b\n",
+		},
+		{
+			name: "not found",
+			args: []string{"gosh", filepath.Join(filepath.Dir(script), "none.sh")},
+			code: 127,
+		},
+		{
+			// 语法错误时停止执行
+			name:     "syntax error",
+			args:     []string{"gosh", "-c", "echo a\nls && ;\necho b"},
+			code:     2,
+			expected: "a\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			var sh = NewGosh(types.Option{
+				Env:    os.Environ(),
+				Stdin:  strings.NewReader(""),
+				Stdout: &stdout,
+				Stderr: &stderr,
+			})
+
+			if code := sh.Main(test.args); code != test.code {
+				t.Fatalf("expected code: %d, got: %d, stderr: %s", test.code, code, stderr.String())
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestGoshCheck(t *testing.T) {
+	var tests = []struct {
+		name     string
+		args     []string
+		code     int
+		expected string
+	}{
+		{
+			// 只解析不执行
+			name: "noexec",
+			args: []string{"gosh", "-n", "-c", "echo hi; exit 3"},
+		},
+		{
+			name:     "syntax error",
+			args:     []string{"gosh", "-n", "-c", "echo 'a"},
+			code:     2,
+			expected: "gosh: syntax error at line 1, column 6: unexpected EOF while looking for matching `''\necho 'a\n     ^\n",
+		},
+		{
+			// 报告全部语法错误
+			name:     "syntax errors",
+			args:     []string{"gosh", "-n", "-c", "ls |\n\tcat >; echo ok\n&& echo\n"},
+			code:     2,
+			expected: "gosh: syntax error at line 2, column 7: unexpected token `;'\n\tcat >; echo ok\n\t     ^\ngosh: syntax error at line 3, column 1: unexpected token `&&'\n&& echo\n^\n",
+		},
+		{
+			name: "dump",
+			args: []string{"gosh", "--dump-ast", "-c", `ls "$d" | wc -l`},
+			expected: `[
+  {
+    "path": "ls",
+    "args": [
+      "$d"
+    ],
+    "pipe": {
+      "path": "wc",
+      "args": [
+        "-l"
+      ],
+      "pos": {
+        "offset": 10,
+        "line": 1,
+        "col": 11
+      }
+    },
+    "pos": {
+      "offset": 0,
+      "line": 1,
+      "col": 1
+    }
+  }
+]
+`,
+		},
+		{
+			// 数组赋值输出变量名和元素，HTML 字符不转义
+			name: "dump array",
+			args: []string{"gosh", "--dump-ast", "-c", `a=(x "y z") b+=() echo '<&>'`},
+			expected: `[
+  {
+    "path": {
+      "array": "a",
+      "elements": [
+        "x",
+        "y z"
+      ]
+    },
+    "args": [
+      {
+        "array": "b",
+        "append": true,
+        "elements": []
+      },
+      "echo",
+      "<&>"
+    ],
+    "pos": {
+      "offset": 0,
+      "line": 1,
+      "col": 1
+    }
+  }
+]
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if code := sh.Main(test.args); code != test.code {
+				t.Fatalf("expected code: %d, got: %d", test.code, code)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestGoshContinuation(t *testing.T) {
+	var input = strings.Join([]string{
+		`echo "a`,
+		`b"`,
+		`echo x |`,
+		`cat`,
+		`echo 1 \`,
+		`2`,
+		`cat <<EOF`,
+		`doc`,
+		`EOF`,
+		`echo ok &&`,
+		`echo done`,
+	}, "\n") + "\n"
+
+	var stdout bytes.Buffer
+
+	var sh = NewGosh(types.Option{
+		Env:    os.Environ(),
+		Stdin:  strings.NewReader(input),
+		Stdout: &stdout,
+		Stderr: &stdout,
+	})
+
+	_ = sh.setvar("PS2", "+ ")
+
+	if code := sh.Main([]string{"gosh", "-i"}); code != 0 {
+		t.Fatalf("unexpected code: %d, output: %q", code, stdout.String())
+	}
+
+	for _, expected := range []string{"+ a\nb\n", "+ x\n", "+ 1 2\n", "+ + doc", "+ ok\ndone\n"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("expected: %q, got: %q", expected, stdout.String())
+		}
+	}
+}
+
+func TestGoshInterrupt(t *testing.T) {
+	var (
+		stdout bytes.Buffer
+		output = &syncWriter{w: &stdout}
+		in, w  = io.Pipe()
+	)
+
+	var sh = NewGosh(types.Option{
+		Env:    os.Environ(),
+		Stdin:  in,
+		Stdout: output,
+		Stderr: output,
+	})
+
+	_ = sh.setvar("PS2", "+ ")
+
+	var done = make(chan int, 1)
+	go func() { done <- sh.Main([]string{"gosh", "-i"}) }()
+
+	// 等待输出提示符
+	var wait = func(prompt string) {
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			output.mutex.Lock()
+			var found = strings.Contains(stdout.String(), prompt)
+			output.mutex.Unlock()
+
+			if found {
+				return
+			}
+
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("prompt %q not shown", prompt)
+			}
+		}
+	}
+
+	// 续行提示符处收到 SIGINT 时丢弃未完成的命令，引号内的保留字不作为保留字
+	_, _ = io.WriteString(w, "echo 'a\n")
+	wait("+ ")
+	sh.Signal(syscall.SIGINT)
+	wait("+ \n")
+	_, _ = io.WriteString(w, "echo b\n'time' echo c\n")
+	_ = w.Close()
+
+	select {
+	case code := <-done:
+		if code != 127 {
+			t.Fatalf("unexpected code: %d, output: %q", code, stdout.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shell not exited")
+	}
+
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if strings.Contains(stdout.String(), "a\n") || !strings.Contains(stdout.String(), "b\n") {
+		t.Errorf("partial command not discarded: %q", stdout.String())
+	}
+
+	if !strings.Contains(stdout.String(), "time: command not found") {
+		t.Errorf("quoted time used as reserved word: %q", stdout.String())
+	}
+}
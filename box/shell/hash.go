@@ -0,0 +1,250 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const hashUsage = `hash: hash [-lr] [-p pathname] [-dt] [name ...]
+    Remember or display program locations.
+
+    Determine and remember the full pathname of each command NAME.  If
+    no arguments are given, information about remembered commands is displayed.
+
+    Options:
+      -d	forget the remembered location of each NAME
+      -l	display in a format that may be reused as input
+      -p pathname	use PATHNAME as the full pathname of NAME
+      -r	forget all remembered locations
+      -t	print the remembered location of each NAME, preceding
+    		each location with the corresponding NAME if multiple
+    		NAMEs are given
+
+    Arguments:
+      NAME	Each NAME is searched for in $PATH and added to the list
+    		of remembered commands.
+
+    Exit Status:
+    Returns success unless NAME is not found or an invalid option is given.
+`
+
+// hashEntry hash 表中记住的命令路径
+type hashEntry struct {
+	path   string
+	hits   int
+	pinned bool // 由 hash -p 指定，优先于同名的 box 命令
+}
+
+// isExecutable 文件是否为可执行的普通文件
+func isExecutable(path string) bool {
+	var info, err = os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
+
+// searchPath 在 PATH 中查找可执行文件，all 为 false 时只返回第一个，空目录表示当前目录
+func searchPath(path, name string, all bool) (paths []string) {
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+
+		// 保留 `./` 前缀，避免 exec 再次在 PATH 中查找
+		var file = filepath.Join(dir, name)
+		if !strings.ContainsRune(file, filepath.Separator) {
+			file = "." + string(filepath.Separator) + file
+		}
+
+		if isExecutable(file) && !slices.Contains(paths, file) {
+			if paths = append(paths, file); !all {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// hashTable 当前 PATH 对应的 hash 表，PATH 改变后清空，调用方需持有 sh.mutex
+func (sh *Gosh) hashTable(path string) map[string]*hashEntry {
+	if sh.hashes == nil || sh.hashPath != path {
+		sh.hashes, sh.hashPath = make(map[string]*hashEntry), path
+	}
+
+	return sh.hashes
+}
+
+// hashed 查找 hash 表中记住的命令
+func (sh *Gosh) hashed(name string) (entry hashEntry, ok bool) {
+	var path = sh.getenv("PATH")
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if e := sh.hashTable(path)[name]; e != nil {
+		return *e, true
+	}
+
+	return
+}
+
+// lookPath 查找外部命令的路径并记入 hash 表，命令名含 `/` 时不查找，记住的文件不存在时重新查找
+func (sh *Gosh) lookPath(name string) (path string, err error) {
+	if strings.ContainsRune(name, '/') {
+		return name, nil
+	}
+
+	var env = sh.getenv("PATH")
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	var table = sh.hashTable(env)
+	if e := table[name]; e != nil && isExecutable(e.path) {
+		e.hits++
+		return e.path, nil
+	}
+
+	var paths = searchPath(env, name, false)
+	if len(paths) == 0 {
+		delete(table, name)
+		return name, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+
+	table[name] = &hashEntry{path: paths[0], hits: 1}
+
+	return paths[0], nil
+}
+
+// applet 将要执行的 box 命令：被 hash -p 指定的路径遮蔽，或设置 prefer_external 且 PATH 中有同名命令时返回 nil
+func (sh *Gosh) applet(name string) types.NewFunc {
+	var newFunc = sh.Command[name]
+	if newFunc == nil {
+		return nil
+	}
+
+	if entry, ok := sh.hashed(name); ok && entry.pinned {
+		return nil
+	}
+
+	sh.mutex.RLock()
+	var external = sh.shopts["prefer_external"]
+	sh.mutex.RUnlock()
+
+	if external && len(searchPath(sh.getenv("PATH"), name, false)) > 0 {
+		return nil
+	}
+
+	return newFunc
+}
+
+// hash 记住或输出命令的路径
+func (sh *Gosh) hash(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, hashUsage)
+		return
+	}
+
+	var (
+		reset, remove, reusable, display bool
+		pathname                         string
+		pinned                           bool
+	)
+
+	args, err := parseOptions(args[1:], "dlp:rt", func(option byte, value string) error {
+		switch option {
+		case 'd':
+			remove = true
+		case 'l':
+			reusable = true
+		case 'p':
+			pathname, pinned = value, true
+		case 'r':
+			reset = true
+		case 't':
+			display = true
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("hash: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, hashUsage)
+		return 2
+	}
+
+	var env = sh.getenv("PATH")
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	var table = sh.hashTable(env)
+	if reset {
+		clear(table)
+	}
+
+	if len(args) == 0 {
+		switch {
+		case reset:
+		case reusable:
+			for _, name := range slices.Sorted(maps.Keys(table)) {
+				_, _ = fmt.Fprintf(opt.Stdout, "hash -p %s %s\n", table[name].path, name)
+			}
+		case len(table) == 0:
+			_, _ = fmt.Fprintln(opt.Stdout, "hash: hash table empty")
+		default:
+			_, _ = fmt.Fprintln(opt.Stdout, "hits\tcommand")
+			for _, name := range slices.Sorted(maps.Keys(table)) {
+				_, _ = fmt.Fprintf(opt.Stdout, "%4d\t%s\n", table[name].hits, table[name].path)
+			}
+		}
+		return
+	}
+
+	for _, name := range args {
+		var entry = table[name]
+
+		switch {
+		case remove:
+			if entry == nil {
+				writeError(opt, fmt.Errorf("hash: %s: not found", name))
+				code = 1
+			}
+			delete(table, name)
+		case display:
+			if entry == nil {
+				writeError(opt, fmt.Errorf("hash: %s: not found", name))
+				code = 1
+				continue
+			}
+			if len(args) > 1 {
+				_, _ = fmt.Fprintf(opt.Stdout, "%s\t", name)
+			}
+			_, _ = fmt.Fprintln(opt.Stdout, entry.path)
+		case pinned:
+			table[name] = &hashEntry{path: pathname, pinned: true}
+		case strings.ContainsRune(name, '/'), sh.Builtin[name] != nil:
+			// 含 `/` 的命令和内置命令不需要记住
+		default:
+			var paths = searchPath(env, name, false)
+			if len(paths) == 0 {
+				writeError(opt, fmt.Errorf("hash: %s: not found", name))
+				code = 1
+				continue
+			}
+			table[name] = &hashEntry{path: paths[0]}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,86 @@
+package shell
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Hook 命令执行的钩子，嵌入 Gosh 时用于输出审计日志和追踪，
+// 管道中的命令和子 shell 并行执行，方法可能被同时调用
+type Hook interface {
+	// OnCommandStart 在命令展开后执行前调用，包括内置命令、box 命令和外部命令，返回的上下文传递给 OnCommandEnd，
+	// 文档输入 `<<` 的 Target 为空，不传递文档的内容
+	OnCommandStart(ctx context.Context, argv []string, redirects []Redirect) context.Context
+
+	// OnCommandEnd 在命令结束后调用，err 为中止执行的错误
+	OnCommandEnd(ctx context.Context, argv []string, code int, duration time.Duration, err error)
+
+	// OnExpand 在单词展开后调用，word 为展开前的单词
+	OnExpand(ctx context.Context, word string, fields []string)
+}
+
+// NopHook 不做任何处理的钩子，嵌入后只需实现关心的方法
+type NopHook struct{}
+
+func (NopHook) OnCommandStart(ctx context.Context, argv []string, redirects []Redirect) context.Context {
+	return ctx
+}
+
+func (NopHook) OnCommandEnd(ctx context.Context, argv []string, code int, duration time.Duration, err error) {
+}
+
+func (NopHook) OnExpand(ctx context.Context, word string, fields []string) {
+}
+
+// context 传递给钩子的上下文
+func (sh *Gosh) context() context.Context {
+	if sh.Context != nil {
+		return sh.Context
+	}
+
+	return context.Background()
+}
+
+// commandStart 调用 OnCommandStart，返回在命令结束时调用 OnCommandEnd 的函数
+func (sh *Gosh) commandStart(argv []string, redirects []Redirect) (end func(code int, err error)) {
+	if sh.Hook == nil {
+		return func(int, error) {}
+	}
+
+	var args = make([]string, 0, len(argv))
+	for _, arg := range argv {
+		args = append(args, unmark(arg))
+	}
+
+	// 文档的内容可能包含敏感数据，不传递给钩子
+	var targets = make([]Redirect, 0, len(redirects))
+	for _, r := range redirects {
+		if r.Op == "<<" {
+			r.Target = ""
+		}
+		targets = append(targets, r)
+	}
+
+	var (
+		start = time.Now()
+		ctx   = sh.Hook.OnCommandStart(sh.context(), slices.Clone(args), targets)
+	)
+
+	if ctx == nil {
+		ctx = sh.context()
+	}
+
+	return func(code int, err error) {
+		sh.Hook.OnCommandEnd(ctx, args, code, time.Since(start), err)
+	}
+}
+
+// onExpand 调用 OnExpand
+func (sh *Gosh) onExpand(word string, fields []string) {
+	if sh.Hook == nil {
+		return
+	}
+
+	sh.Hook.OnExpand(sh.context(), unmark(word), slices.Clone(fields))
+}
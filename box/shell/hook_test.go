@@ -0,0 +1,156 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// traceKey 测试钩子传递给 OnCommandEnd 的上下文键
+type traceKey struct{}
+
+// recordHook 记录钩子调用的测试钩子
+type recordHook struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (h *recordHook) record(format string, args ...any) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.events = append(h.events, fmt.Sprintf(format, args...))
+}
+
+func (h *recordHook) OnCommandStart(ctx context.Context, argv []string, redirects []Redirect) context.Context {
+	h.record("start %q %v", argv, redirects)
+	return context.WithValue(ctx, traceKey{}, argv[0])
+}
+
+func (h *recordHook) OnCommandEnd(ctx context.Context, argv []string, code int, duration time.Duration, err error) {
+	h.record("end %s %q %d %v", ctx.Value(traceKey{}), argv, code, duration >= 0 && err == nil)
+}
+
+func (h *recordHook) OnExpand(ctx context.Context, word string, fields []string) {
+	if strings.Contains(word, "$") {
+		h.record("expand %q %q", word, fields)
+	}
+}
+
+func TestHook(t *testing.T) {
+	var out = filepath.Join(t.TempDir(), "out")
+
+	var tests = []struct {
+		script   string
+		expected []string
+	}{
+		{
+			// 内置命令、box 命令和外部命令，不带命令的赋值不调用
+			script: `x="a b"; echo $x; cd /; false; ls -d / >/dev/null`,
+			expected: []string{
+				`expand "$x" ["a" "b"]`,
+				`start ["echo" "a" "b"] []`,
+				`end echo ["echo" "a" "b"] 0 true`,
+				`start ["cd" "/"] []`,
+				`end cd ["cd" "/"] 0 true`,
+				`start ["false"] []`,
+				`end false ["false"] 1 true`,
+				`start ["ls" "-d" "/"] [{1 > /dev/null}]`,
+				`end ls ["ls" "-d" "/"] 0 true`,
+			},
+		},
+		{
+			// 重定向目标展开后传递，文档输入不传递内容
+			script: "f=" + out + "; echo hi >$f 2>&1; cat <$f <<EOF\ndoc\nEOF\n",
+			expected: []string{
+				`expand "$f" [` + fmt.Sprintf("%q", out) + `]`,
+				`start ["echo" "hi"] [{1 > ` + out + `} {2 >& 1}]`,
+				`end echo ["echo" "hi"] 0 true`,
+				`expand "$f" [` + fmt.Sprintf("%q", out) + `]`,
+				`start ["cat"] [{0 < ` + out + `} {0 << }]`,
+				`end cat ["cat"] 0 true`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var (
+				hook       = new(recordHook)
+				sh, stdout = newTestGosh()
+			)
+
+			sh.Hook = hook
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err, stdout.String())
+			}
+
+			if !reflect.DeepEqual(hook.events, test.expected) {
+				t.Errorf("expected: %q\ngot: %q", test.expected, hook.events)
+			}
+		})
+	}
+}
+
+func TestHookSubshell(t *testing.T) {
+	var (
+		hook       = new(recordHook)
+		sh, stdout = newTestGosh()
+	)
+
+	// 管道和进程替换中的命令同样调用钩子
+	sh.Hook = hook
+
+	if _, err := sh.Run(strings.NewReader(`cat <(echo sub) | cat`), sh.Option); err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout.String() != "sub\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	var starts []string
+	for _, event := range hook.events {
+		if strings.HasPrefix(event, "start") {
+			starts = append(starts, event)
+		}
+	}
+
+	if len(starts) != 3 || !slices.Contains(starts, `start ["echo" "sub"] []`) || !slices.Contains(starts, `start ["cat"] []`) {
+		t.Errorf("unexpected events: %q", hook.events)
+	}
+}
+
+// codeHook 只记录退出码的钩子
+type codeHook struct {
+	NopHook
+	codes []int
+}
+
+func (h *codeHook) OnCommandEnd(ctx context.Context, argv []string, code int, duration time.Duration, err error) {
+	h.codes = append(h.codes, code)
+}
+
+func TestNopHook(t *testing.T) {
+	var (
+		hook  = new(codeHook)
+		sh, _ = newTestGosh()
+	)
+
+	sh.Hook = hook
+
+	if _, err := sh.Run(strings.NewReader(`true; false; [[ a == b ]]`), sh.Option); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(hook.codes, []int{0, 1, 1}) {
+		t.Errorf("unexpected codes: %v", hook.codes)
+	}
+}
@@ -1,7 +1,6 @@
 package shell
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -25,6 +24,10 @@ const (
 	TokenSemicolon                       // 分号 `;`
 	TokenVar                             // 变量 `$XX`
 	TokenCmd                             // 命令替换 `$(...)`
+	TokenIONumber                        // 重定向文件描述符 `2>`
+	TokenRedirectDupIn                   // 复制输入描述符 `<&`
+	TokenRedirectDupOut                  // 复制输出描述符 `>&`
+	TokenSync                            // 等待之前的命令执行完成（别名展开前）
 )
 
 var tokenSymbols = map[TokenType]string{
@@ -37,6 +40,8 @@ var tokenSymbols = map[TokenType]string{
 	TokenHeredoc:        "<<",
 	TokenBackground:     "&",
 	TokenSemicolon:      ";",
+	TokenRedirectDupIn:  "<&",
+	TokenRedirectDupOut: ">&",
 }
 
 var (
@@ -44,13 +49,84 @@ var (
 	symbolMaxLength int
 )
 
-var escapeSequences = map[byte]byte{
+// 单词中的展开标记，由词法分析写入，展开时移除
+const (
+	markEscape byte = '\x01' // 下一个字符为字面量，不参与展开和字段分割
+	markQuoted byte = '\x02' // 紧随的 `$` 展开位于双引号内，不参与字段分割
+	markEmpty  byte = '\x03' // 空引号 "" ''，展开后保留为空字段
+	markArray  byte = '\x04' // 数组赋值 `name=(...)` 的开始和元素之间的分隔
+)
+
+// isSpecial 字面量中需要转义的字符
+func isSpecial(c byte) bool {
+	switch c {
+	case '$', markEscape, markQuoted, markEmpty, markArray:
+		return true
+	}
+
+	return false
+}
+
+// writeLiteral 写入引号内或转义的字面量字符
+func writeLiteral(word *strings.Builder, c byte) {
+	if isSpecial(c) {
+		word.WriteByte(markEscape)
+	}
+
+	word.WriteByte(c)
+}
+
+// writeLiteral 写入引号内或转义的字面量字符，`[[ ]]` 内的字面量均转义，
+// 以区分运算符和引号内的同名字符串，并使引号内的模式和正则字符按字面量匹配
+func (l *Lexer) writeLiteral(word *strings.Builder, c byte) {
+	if l.cond {
+		word.WriteByte(markEscape)
+		word.WriteByte(c)
+		return
+	}
+
+	writeLiteral(word, c)
+}
+
+// unmark 移除展开标记，得到字面量
+func unmark(word string) string {
+	if strings.IndexFunc(word, func(r rune) bool { return r <= rune(markArray) }) < 0 {
+		return word
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(word); i++ {
+		switch c := word[i]; c {
+		case markEscape:
+			if i++; i < len(word) {
+				sb.WriteByte(word[i])
+			}
+		case markQuoted, markEmpty:
+		case markArray:
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// ansiEscapes `$'...'` 中的单字符转义
+var ansiEscapes = map[byte]byte{
+	'a':  '\a',
+	'b':  '\b',
+	'e':  '\x1b',
+	'E':  '\x1b',
+	'f':  '\f',
 	'n':  '\n',
-	't':  '\t',
 	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
 	'\\': '\\',
 	'\'': '\'',
 	'"':  '"',
+	'?':  '?',
 }
 
 func initSymbolTokens() {
@@ -65,48 +141,183 @@ func init() {
 	initSymbolTokens()
 }
 
+// isRedirect 是否为可带文件描述符的重定向
+func isRedirect(tokenType TokenType) bool {
+	switch tokenType {
+	case TokenRedirectIn, TokenRedirectOut, TokenRedirectAppend, TokenRedirectDupIn, TokenRedirectDupOut:
+		return true
+	}
+
+	return false
+}
+
+// isNumber 是否为非负整数
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
 type Token struct {
-	Type  TokenType
-	Value string
+	Type   TokenType
+	Value  string
+	Pos    Pos  // 起始位置
+	Quoted bool // 单词包含引号或转义，不作为保留字
 }
 
-func readByte(r *bufio.Reader, flag string) (c byte, err error) {
+func readByte(r *source, flag string) (c byte, err error) {
 	if c, err = r.ReadByte(); err != nil {
-		return 0, fmt.Errorf("unexpected end of input after %s, err: %w", flag, err)
+		if errors.Is(err, io.EOF) {
+			return 0, unexpectedEOF(r.pos, "unexpected end of input after %s", flag)
+		}
+		return
 	}
 
 	return
 }
 
-func readBytes(r *bufio.Reader, delim byte, flag string) (bytes []byte, err error) {
+func readBytes(r *source, delim byte, flag string) (bytes []byte, err error) {
 	if bytes, err = r.ReadBytes(delim); err != nil {
-		return nil, fmt.Errorf("unexpected end of input after %s, err: %w", flag, err)
+		if errors.Is(err, io.EOF) {
+			return nil, unexpectedEOF(r.pos, "unexpected end of input after %s", flag)
+		}
+		return
 	}
 
 	return
 }
 
-func readEscaped(r *bufio.Reader, flag string) (c byte, err error) {
-	if c, err = readByte(r, flag); err != nil {
-		return
-	}
-
-	// TODO 解析多字符转义
+// isQuotedEscape 双引号内反斜杠可以转义的字符，其余字符之前的反斜杠保留
+func isQuotedEscape(c byte) bool {
 	switch c {
-	case 'u':
-	case 'U':
-	case 'x':
-	case '0':
+	case '$', '`', '"', '\\':
+		return true
 	}
 
-	if escaped, exists := escapeSequences[c]; exists {
-		return escaped, nil
+	return false
+}
+
+// readDigits 读取最多 max 个指定进制的数字，返回其值和数字的个数
+func readDigits(r *source, base, max int) (value rune, n int) {
+	for ; n < max; n++ {
+		var peek, err = r.Peek(1)
+		if err != nil {
+			return
+		}
+
+		var digit int
+		switch c := peek[0]; {
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			digit = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			digit = int(c-'A') + 10
+		default:
+			return
+		}
+
+		if digit >= base {
+			return
+		}
+
+		_, _ = r.Discard(1)
+		value = value*rune(base) + rune(digit)
 	}
 
-	return c, nil
+	return
+}
+
+// readANSI 读取 `$'` 之后直到未转义的 `'`，按 ANSI C 的规则处理转义
+func readANSI(r *source, word *strings.Builder) (err error) {
+	var c byte
+	for {
+		if c, err = readByte(r, "$'"); err != nil {
+			return
+		}
+
+		if c == '\'' {
+			return
+		}
+
+		if c != '\\' {
+			writeLiteral(word, c)
+			continue
+		}
+
+		if c, err = readByte(r, "$'"); err != nil {
+			return
+		}
+
+		if escaped, exists := ansiEscapes[c]; exists {
+			writeLiteral(word, escaped)
+			continue
+		}
+
+		var (
+			value rune
+			n     int
+		)
+
+		switch c {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// \nnn 八进制，最多三位
+			value, n = readDigits(r, 8, 2)
+			value += rune(c-'0') << (3 * n)
+			writeLiteral(word, byte(value))
+		case 'x':
+			// \xHH 十六进制字节，最多两位
+			if value, n = readDigits(r, 16, 2); n == 0 {
+				word.WriteString("\\x")
+				continue
+			}
+			writeLiteral(word, byte(value))
+		case 'u', 'U':
+			// \uHHHH 和 \UHHHHHHHH 为 Unicode 字符，以 UTF-8 写入
+			var max = 4
+			if c == 'U' {
+				max = 8
+			}
+
+			if value, n = readDigits(r, 16, max); n == 0 {
+				word.WriteByte('\\')
+				word.WriteByte(c)
+				continue
+			}
+
+			for _, b := range []byte(string(value)) {
+				writeLiteral(word, b)
+			}
+		case 'c':
+			// \cX 控制字符
+			if c, err = readByte(r, "$'"); err != nil {
+				return
+			}
+
+			if c == '\\' {
+				if peek, e := r.Peek(1); e == nil && peek[0] == '\\' {
+					_, _ = r.Discard(1)
+				}
+			}
+
+			writeLiteral(word, c&0x1f)
+		default:
+			// 未知的转义保留反斜杠
+			word.WriteByte('\\')
+			writeLiteral(word, c)
+		}
+	}
 }
 
-func readDelimiter(r *bufio.Reader, delim string, ident bool, flag string) (_ string, err error) {
+func readDelimiter(r *source, delim string, ident bool, flag string) (_ string, err error) {
 	var (
 		sb     strings.Builder
 		data   []byte
@@ -147,8 +358,26 @@ func readDelimiter(r *bufio.Reader, delim string, ident bool, flag string) (_ st
 
 type Lexer struct {
 	err    error
-	reader *bufio.Reader
+	quoted bool // 当前单词包含引号或转义
+	reader *source
 	tokens chan Token
+	at     Pos       // 当前字符的位置
+	start  Pos       // 当前单词的起始位置
+	last   TokenType // 上一个 token 的类型
+
+	alias   func(name string) (value string, ok bool) // 查找别名
+	resume  chan struct{}                             // 之前的命令执行完成
+	command bool                                      // 下一个单词位于命令位置
+	target  bool                                      // 下一个单词为重定向目标
+	active  map[string]bool                           // 正在展开的别名，防止递归
+
+	cond  bool // 位于 `[[ ]]` 内
+	regex bool // 下一个单词为 `=~` 右侧的正则表达式
+	depth int  // 正则表达式中未闭合的括号数
+
+	coproc bool // 下一个单词开始 coproc 的命令
+
+	lines *lineReader // 逐行读取的输入，nil 时按块读取
 }
 
 func (l *Lexer) getValue(sb *strings.Builder) string {
@@ -158,18 +387,600 @@ func (l *Lexer) getValue(sb *strings.Builder) string {
 
 	defer sb.Reset()
 
+	l.quoted = false
+
 	return sb.String()
 }
 
 func (l *Lexer) inputToken(tokenType TokenType, tokenValue string) {
-	l.tokens <- Token{Type: tokenType, Value: tokenValue}
+	switch {
+	case tokenType == TokenSemicolon, tokenType == TokenPipe, tokenType == TokenOr,
+		tokenType == TokenAnd, tokenType == TokenBackground:
+		l.command, l.cond = true, false
+	case isRedirect(tokenType):
+		l.target = true
+	}
+
+	l.inputTokenAt(tokenType, tokenValue, l.at)
+}
+
+func (l *Lexer) inputTokenAt(tokenType TokenType, tokenValue string, pos Pos) {
+	if tokenType != TokenSync {
+		l.last = tokenType
+	}
+
+	l.tokens <- Token{Type: tokenType, Value: tokenValue, Pos: pos}
+}
+
+// snippet 为语法错误加上出错的行
+func (l *Lexer) snippet(err error) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) && syntaxErr.Source == "" {
+		syntaxErr.Source = l.reader.line(syntaxErr.Pos.Line)
+	}
+
+	return err
 }
 
 func (l *Lexer) inputWordToken(sb *strings.Builder) {
+	// 空引号作为空字段保留
+	if sb != nil && sb.Len() == 0 && l.quoted {
+		sb.WriteByte(markEmpty)
+	}
+
 	if sb != nil && sb.Len() > 0 {
-		l.inputToken(TokenWord, sb.String())
+		var value = sb.String()
+
+		// 命令位置的 `[[` 开始条件表达式，`]]` 结束，`=~` 之后为正则表达式
+		switch {
+		case l.command && !l.target && !l.quoted && value == "coproc":
+			l.coproc = true
+		case l.command && !l.target && !l.quoted && value == "[[":
+			l.cond = true
+		case l.cond && !l.quoted && value == "]]":
+			l.cond = false
+		}
+		l.regex, l.depth = l.cond && !l.quoted && value == "=~", 0
+
+		// 变量赋值之后仍为命令位置
+		if l.target {
+			l.target = false
+		} else if name, _, ok := strings.Cut(value, "="); !ok || !isAssignName(name) {
+			l.command = false
+		}
+
+		l.last = TokenWord
+		l.tokens <- Token{Type: TokenWord, Value: value, Pos: l.start, Quoted: l.quoted}
 		sb.Reset()
 	}
+
+	l.quoted = false
+}
+
+// Alias 启用别名展开，lookup 在之前的命令执行完成后查找别名，
+// 命令的接收方收到同步命令后需要调用 Resume
+func (l *Lexer) Alias(lookup func(name string) (value string, ok bool)) *Lexer {
+	l.alias = lookup
+	l.resume = make(chan struct{}, 1)
+	l.active = make(map[string]bool)
+
+	return l
+}
+
+// Lines 逐行读取输入，每行之前等待之前的命令执行完成，执行的命令可以从输入读取之后的行，
+// 命令的接收方收到同步命令后需要调用 Resume
+func (l *Lexer) Lines() *Lexer {
+	if l.resume == nil {
+		l.resume = make(chan struct{}, 1)
+	}
+
+	l.lines = l.reader.unbuffered()
+
+	return l
+}
+
+// sync 发送同步命令，等待之前的命令执行完成
+func (l *Lexer) sync(ctx context.Context) error {
+	l.inputToken(TokenSync, "")
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.resume:
+		return nil
+	}
+}
+
+// Resume 通知词法分析之前的命令已执行完成
+func (l *Lexer) Resume() {
+	select {
+	case l.resume <- struct{}{}:
+	default:
+	}
+}
+
+// expandAlias 命令位置的单词为别名时，将别名的值放回输入重新分析，rest 为单词之后已读取的内容
+func (l *Lexer) expandAlias(ctx context.Context, word *strings.Builder, rest string) (expanded bool, err error) {
+	if l.alias == nil || !l.command || l.target || l.quoted || word.Len() == 0 {
+		return
+	}
+
+	var name = word.String()
+	if l.active[name] {
+		return
+	}
+
+	// 等待之前的命令执行完成，使其中定义的别名生效
+	if err = l.sync(ctx); err != nil {
+		return
+	}
+
+	var value, ok = l.alias(name)
+	if !ok {
+		return
+	}
+
+	word.Reset()
+	l.active[name] = true
+
+	// 别名以空白结尾时，下一个单词同样检查别名；
+	// 否则补充空白，使最后一个单词在别名读完之前结束
+	var chain = strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t")
+	if !chain {
+		value += " "
+	}
+
+	// 单词之后已读取的内容在别名的值之后读取
+	l.reader.push(rest, nil)
+	l.reader.push(value, func() {
+		delete(l.active, name)
+		if chain {
+			l.command = true
+		}
+	})
+
+	return true, nil
+}
+
+// continuation 反斜杠之后为换行时跳过换行
+func (l *Lexer) continuation() bool {
+	if peek, err := l.reader.Peek(1); err == nil && peek[0] == '\n' {
+		_, _ = l.reader.Discard(1)
+		return true
+	}
+
+	return false
+}
+
+// isParam 是否为特殊参数或位置参数的名称
+func isParam(c byte) bool {
+	switch c {
+	case '?', '$', '#', '@', '*', '!', '-':
+		return true
+	}
+
+	return c >= '0' && c <= '9'
+}
+
+// readDollar 读取 `$` 开头的参数展开，`${...}` 整体作为单词的一部分
+func (l *Lexer) readDollar(word *strings.Builder, quoted bool) (err error) {
+	var peek []byte
+	if peek, err = l.reader.Peek(1); err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+
+	// 引号外的 `$'...'` 按 ANSI C 转义，`$"..."` 作为普通的双引号字符串
+	if !quoted && len(peek) > 0 {
+		switch peek[0] {
+		case '\'':
+			_, _ = l.reader.Discard(1)
+			l.quoted = true
+			return readANSI(l.reader, word)
+		case '"':
+			return nil
+		}
+	}
+
+	if quoted {
+		word.WriteByte(markQuoted)
+	}
+	word.WriteByte('$')
+
+	if len(peek) == 0 {
+		return nil
+	}
+
+	switch {
+	case peek[0] == '{':
+		_, _ = l.reader.Discard(1)
+		word.WriteByte('{')
+		return l.readBrace(word)
+	case isParam(peek[0]):
+		_, _ = l.reader.Discard(1)
+		word.WriteByte(peek[0])
+	default:
+		// 变量名整体读取，避免引号内的字符被转义
+		for name := string(peek[0]); isName(name); name += string(peek[0]) {
+			_, _ = l.reader.Discard(1)
+			word.WriteByte(peek[0])
+
+			if peek, err = l.reader.Peek(1); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// readBrace 读取 `${` 之后直到匹配的 `}`，其中引号内的字符均转义
+func (l *Lexer) readBrace(word *strings.Builder) (err error) {
+	var c, nc byte
+	for {
+		if c, err = readByte(l.reader, "${"); err != nil {
+			return
+		}
+
+		switch c {
+		case '}':
+			word.WriteByte(c)
+			return
+		case '$':
+			if err = l.readDollar(word, false); err != nil {
+				return
+			}
+		case '\\':
+			if nc, err = readByte(l.reader, "\\"); err != nil {
+				return
+			}
+			word.WriteByte(markEscape)
+			word.WriteByte(nc)
+		case '\'', '"':
+			for {
+				if nc, err = readByte(l.reader, string(c)); err != nil {
+					return
+				}
+
+				if nc == c {
+					break
+				}
+
+				switch {
+				case c == '"' && nc == '$':
+					err = l.readDollar(word, true)
+				case c == '"' && nc == '\\':
+					var peek []byte
+					if peek, err = l.reader.Peek(1); err == nil && !isQuotedEscape(peek[0]) {
+						word.WriteByte(markEscape)
+						word.WriteByte(nc)
+					} else if nc, err = readByte(l.reader, "\\"); err == nil {
+						word.WriteByte(markEscape)
+						word.WriteByte(nc)
+					}
+				default:
+					word.WriteByte(markEscape)
+					word.WriteByte(nc)
+				}
+
+				if err != nil {
+					return
+				}
+			}
+		default:
+			word.WriteByte(c)
+		}
+	}
+}
+
+// readSubstitution 读取进程替换 `<(` 或 `>(` 之后直到匹配的 `)`，写入 `$<(` 或 `$>(` 开头、
+// 全部字符转义的命令，由执行命令前的进程替换处理
+func readSubstitution(r *source, word *strings.Builder, c byte) (err error) {
+	var (
+		start = r.pos
+		depth = 1
+		quote byte
+		nc    byte
+	)
+
+	word.WriteByte('$')
+	word.WriteByte(c)
+	word.WriteByte('(')
+
+	for {
+		if nc, err = r.ReadByte(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = unexpectedEOF(start, "unexpected EOF while looking for matching `)'")
+			}
+			return
+		}
+
+		switch {
+		case quote != 0 && nc == quote:
+			quote = 0
+		case quote == '\'':
+		case nc == '\\':
+			// 转义的字符原样保留，不参与括号和引号的匹配
+			word.WriteByte(markEscape)
+			word.WriteByte(nc)
+			if nc, err = readByte(r, "\\"); err != nil {
+				return
+			}
+		case quote != 0:
+		case nc == '\'' || nc == '"':
+			quote = nc
+		case nc == '(':
+			depth++
+		case nc == ')':
+			if depth--; depth == 0 {
+				word.WriteByte(')')
+				return
+			}
+		}
+
+		word.WriteByte(markEscape)
+		word.WriteByte(nc)
+	}
+}
+
+// readArray 读取数组赋值 `name=(` 之后直到 `)` 的元素，以 markArray 开始并分隔元素，
+// 元素中的引号、转义和参数展开与单词相同，`#` 开始的注释忽略
+func (l *Lexer) readArray(word *strings.Builder) (err error) {
+	var (
+		start = l.at
+		begin = true // 是否位于元素开头
+		c, nc byte
+	)
+
+	word.WriteByte(markArray)
+
+	for {
+		if c, err = l.reader.ReadByte(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = unexpectedEOF(start, "unexpected EOF while looking for matching `)'")
+			}
+			return
+		}
+
+		switch c {
+		case ')':
+			return
+		case ' ', '\t', '\r', '\n':
+			if !begin {
+				word.WriteByte(markArray)
+				begin = true
+			}
+			continue
+		case '#':
+			if !begin {
+				word.WriteByte(c)
+				break
+			}
+			if _, err = l.reader.ReadBytes('\n'); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = unexpectedEOF(start, "unexpected EOF while looking for matching `)'")
+				}
+				return
+			}
+			continue
+		case '\\':
+			if l.continuation() {
+				continue
+			}
+			if nc, err = readByte(l.reader, "\\"); err != nil {
+				return
+			}
+			writeLiteral(word, nc)
+		case '$':
+			if err = l.readDollar(word, false); err != nil {
+				return
+			}
+		case '\'', '"':
+			word.WriteByte(markEmpty)
+			for {
+				if nc, err = readByte(l.reader, string(c)); err != nil {
+					return
+				}
+
+				if nc == c {
+					break
+				}
+
+				switch {
+				case c == '"' && nc == '$':
+					err = l.readDollar(word, true)
+				case c == '"' && nc == '\\':
+					if l.continuation() {
+						continue
+					}
+					var peek []byte
+					if peek, err = l.reader.Peek(1); err == nil && !isQuotedEscape(peek[0]) {
+						writeLiteral(word, nc)
+					} else if nc, err = readByte(l.reader, "\\"); err == nil {
+						writeLiteral(word, nc)
+					}
+				default:
+					writeLiteral(word, nc)
+				}
+
+				if err != nil {
+					return
+				}
+			}
+		default:
+			word.WriteByte(c)
+		}
+
+		begin = false
+	}
+}
+
+// readCond 读取 `[[ ]]` 内引号外的字符，`&&`、`||`、`(`、`)`、`<` 和 `>` 作为单独的单词而不是控制符和重定向，
+// 换行作为空白，正则表达式中的括号和 `|` 为普通字符
+func (l *Lexer) readCond(word *strings.Builder, c byte) (handled bool, err error) {
+	if l.regex {
+		switch c {
+		case '(':
+			l.depth++
+			word.WriteByte(c)
+			return true, nil
+		case ')':
+			if l.depth > 0 {
+				l.depth--
+				word.WriteByte(c)
+				return true, nil
+			}
+		case '|', '<', '>', '&':
+			word.WriteByte(c)
+			return true, nil
+		}
+	}
+
+	var op = string(c)
+	switch c {
+	case '\n':
+		l.inputWordToken(word)
+		return true, nil
+	case '&', '|':
+		if peek, e := l.reader.Peek(1); e != nil || peek[0] != c {
+			return false, &SyntaxError{Pos: l.at, Msg: fmt.Sprintf("syntax error in conditional expression: unexpected token `%c'", c)}
+		}
+		_, _ = l.reader.Discard(1)
+		op += op
+	case '(', ')', '<', '>':
+	default:
+		return
+	}
+
+	l.inputWordToken(word)
+	l.inputTokenAt(TokenWord, op, l.at)
+
+	return true, nil
+}
+
+// readRaw 原样读取命令文本直到 end 返回 true 的引号外字符，引号和反斜杠转义的字符不作为结束，
+// 结束的字符不写入文本
+func readRaw(r *source, text *strings.Builder, end func(c byte) bool) (last byte, err error) {
+	var quote byte
+	for {
+		if last, err = r.ReadByte(); err != nil {
+			return
+		}
+
+		switch {
+		case quote != 0:
+			if last == quote {
+				quote = 0
+			} else if last == '\\' && quote == '"' {
+				text.WriteByte(last)
+				if last, err = r.ReadByte(); err != nil {
+					return
+				}
+			}
+		case last == '\\':
+			text.WriteByte(last)
+			if last, err = r.ReadByte(); err != nil {
+				return
+			}
+		case last == '\'' || last == '"':
+			quote = last
+		case end(last):
+			return
+		}
+
+		text.WriteByte(last)
+	}
+}
+
+// readCoproc 读取 `coproc [NAME] { command; }` 或 `coproc command` 中 coproc 之后的部分，
+// c 为第一个字符，输出协程名和全部转义的命令两个单词
+func (l *Lexer) readCoproc(word *strings.Builder, c byte) (err error) {
+	var (
+		start = l.at
+		name  = "COPROC"
+		head  strings.Builder
+		body  strings.Builder
+	)
+
+	var readGroup = func() (err error) {
+		var depth = 1
+		if _, err = readRaw(l.reader, &body, func(c byte) bool {
+			switch c {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			return depth == 0
+		}); errors.Is(err, io.EOF) {
+			err = unexpectedEOF(start, "unexpected EOF while looking for matching `}'")
+		}
+		return
+	}
+
+	var isEnd = func(c byte) bool {
+		return c == ';' || c == '\n' || c == '&' || c == '|'
+	}
+
+	var isBlank = func(c byte) bool {
+		return c == ' ' || c == '\t'
+	}
+
+	if c == '{' {
+		if err = readGroup(); err != nil {
+			return
+		}
+	} else {
+		// 第一个单词之后为 `{` 时为协程名
+		l.reader.push(string(c), nil)
+		var last byte
+		if last, err = readRaw(l.reader, &head, func(c byte) bool { return isBlank(c) || isEnd(c) }); err != nil && !errors.Is(err, io.EOF) {
+			return
+		}
+
+		var peek []byte
+		if err == nil && isBlank(last) {
+			for peek, _ = l.reader.Peek(1); len(peek) > 0 && isBlank(peek[0]); peek, _ = l.reader.Peek(1) {
+				_, _ = l.reader.Discard(1)
+			}
+		}
+
+		if len(peek) > 0 && peek[0] == '{' && isName(head.String()) {
+			_, _ = l.reader.Discard(1)
+			name = head.String()
+			if err = readGroup(); err != nil {
+				return
+			}
+		} else {
+			// 简单命令直到命令结束，结束的字符放回
+			body.WriteString(head.String())
+			if err == nil && !isEnd(last) {
+				body.WriteByte(' ')
+				last, err = readRaw(l.reader, &body, isEnd)
+			}
+			switch {
+			case err == nil:
+				l.reader.push(string(last), nil)
+			case !errors.Is(err, io.EOF):
+				return
+			}
+			err = nil
+		}
+	}
+
+	word.WriteString(name)
+	l.inputWordToken(word)
+
+	word.WriteByte(markEmpty)
+	for _, c := range []byte(body.String()) {
+		word.WriteByte(markEscape)
+		word.WriteByte(c)
+	}
+	l.inputWordToken(word)
+
+	return
 }
 
 func (l *Lexer) isRun(ctx context.Context) bool {
@@ -187,11 +998,13 @@ func (l *Lexer) Token() <-chan Token {
 
 func (l *Lexer) Run(ctx context.Context) (err error) {
 	var (
-		word    = new(strings.Builder)
-		quotes  []byte
-		ident   bool
-		delim   string
-		heredoc bool
+		word      = new(strings.Builder)
+		quotes    []byte
+		quotesAt  []Pos
+		ident     bool
+		delim     string
+		heredoc   bool
+		heredocAt Pos
 	)
 
 	if l.err != nil {
@@ -199,23 +1012,55 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 	}
 
 	defer func() {
-		close(l.tokens)
 		if err != nil {
-			l.err = err
+			l.err = l.snippet(err)
 		}
+		close(l.tokens)
 	}()
 
+	if l.lines != nil {
+		l.lines.wait = func() error { return l.sync(ctx) }
+	}
+
 	var c, nc byte
 	for {
 		if !l.isRun(ctx) {
 			return
 		}
 
+		// 单词开始前记录起始位置
+		if l.at = l.reader.pos; word.Len() == 0 && !l.quoted {
+			l.start = l.at
+		}
+
 		if c, err = l.reader.ReadByte(); err != nil {
-			if errors.Is(err, io.EOF) {
-				break
+			if !errors.Is(err, io.EOF) {
+				return
 			}
-			return
+
+			var expanded bool
+			if expanded, err = l.expandAlias(ctx, word, ""); err != nil {
+				return
+			}
+
+			if expanded {
+				continue
+			}
+
+			break
+		}
+
+		// coproc 之后的命令原样读取，由 coproc 在子 shell 中执行
+		if l.coproc && len(quotes) == 0 && word.Len() == 0 {
+			if c == ' ' || c == '\t' {
+				continue
+			}
+
+			l.coproc = false
+			if err = l.readCoproc(word, c); err != nil {
+				return
+			}
+			continue
 		}
 
 		// 在引号内
@@ -226,52 +1071,125 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 			// 关闭引号
 			if c == top {
 				// 弹出栈顶引号
-				quotes = quotes[:len(quotes)-1]
+				quotes, quotesAt = quotes[:len(quotes)-1], quotesAt[:len(quotesAt)-1]
 				continue
 			}
 
-			// 双引号内转义
+			// 双引号内转义，反斜杠换行为续行，其余字符之前的反斜杠保留
 			if c == '\\' && top == '"' {
-				if nc, err = readEscaped(l.reader, "\\"); err != nil {
+				if l.continuation() {
+					continue
+				}
+
+				if peek, e := l.reader.Peek(1); e != nil || !isQuotedEscape(peek[0]) {
+					l.writeLiteral(word, c)
+					continue
+				}
+
+				if nc, err = readByte(l.reader, "\\"); err != nil {
+					return
+				}
+				l.writeLiteral(word, nc)
+				continue
+			}
+
+			// 双引号内参数展开
+			if c == '$' && top == '"' {
+				if err = l.readDollar(word, true); err != nil {
 					return
 				}
-				word.WriteByte(nc)
 				continue
 			}
 
 			// 引号内正常字符
-			word.WriteByte(c)
+			l.writeLiteral(word, c)
 			continue
 		}
 
-		// 引号外转义符
+		// 引号外转义符，反斜杠换行为续行
 		if c == '\\' {
-			if nc, err = readEscaped(l.reader, "\\"); err != nil {
+			if l.continuation() {
+				continue
+			}
+
+			if nc, err = readByte(l.reader, "\\"); err != nil {
+				return
+			}
+			l.writeLiteral(word, nc)
+			l.quoted = true
+			continue
+		}
+
+		// 引号外参数展开
+		if c == '$' {
+			if err = l.readDollar(word, false); err != nil {
+				return
+			}
+			continue
+		}
+
+		// `[[ ]]` 内的运算符
+		if l.cond {
+			var handled bool
+			if handled, err = l.readCond(word, c); err != nil {
+				return
+			}
+			if handled {
+				continue
+			}
+		}
+
+		// 引号外的 `<(...)` 和 `>(...)` 为进程替换
+		if c == '<' || c == '>' {
+			if peek, e := l.reader.Peek(1); e == nil && peek[0] == '(' {
+				_, _ = l.reader.Discard(1)
+				if err = readSubstitution(l.reader, word, c); err != nil {
+					return
+				}
+				continue
+			}
+		}
+
+		// 引号外 `name=(` 开始数组赋值
+		if c == '(' && !l.quoted && isArrayAssign(word.String()) {
+			if err = l.readArray(word); err != nil {
 				return
 			}
-			word.WriteByte(nc)
 			continue
 		}
 
 		// 在引号外
 		switch c {
 		case '\'', '"':
-			quotes = append(quotes, c)
+			quotes, quotesAt = append(quotes, c), append(quotesAt, l.at)
+			l.quoted = true
 		case ' ', '\t', '\r', '\n':
 			if heredoc && delim == "" {
-				delim = l.getValue(word)
+				delim = unmark(l.getValue(word))
 			} else {
+				var expanded bool
+				if expanded, err = l.expandAlias(ctx, word, string(c)); err != nil {
+					return
+				}
+
+				if expanded {
+					continue
+				}
+
 				l.inputWordToken(word)
 			}
 
 			if c == '\r' || c == '\n' {
 				if heredoc {
 					if delim == "" {
-						return fmt.Errorf("syntax error: heredoc delim is null, position %d", word.Len())
+						return &SyntaxError{Pos: l.at, Msg: "missing here-document delimiter"}
 					}
 
 					var value string
 					if value, err = readDelimiter(l.reader, delim, ident, "<<"); err != nil {
+						if errors.Is(err, io.ErrUnexpectedEOF) {
+							err = unexpectedEOF(heredocAt, "here-document delimited by end-of-file (wanted `%s')", delim)
+						}
 						return
 					}
 
@@ -280,11 +1198,17 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 					ident = false
 
 					// TODO 考虑合并成一个还是多个
-					l.inputToken(TokenHeredoc, value)
+					l.inputTokenAt(TokenHeredoc, value, heredocAt)
 					//l.inputToken(TokenHeredoc, "<<") // << or <<-
 					//l.inputWordToken(word)
 				}
-				l.inputToken(TokenSemicolon, tokenSymbols[TokenSemicolon])
+
+				// 管道和逻辑运算符之后的换行不结束命令，连续的换行和分号之后的换行只有一个分隔符
+				switch l.last {
+				case TokenPipe, TokenAnd, TokenOr, TokenSemicolon:
+				default:
+					l.inputToken(TokenSemicolon, tokenSymbols[TokenSemicolon])
+				}
 			}
 		case '-':
 			if heredoc && word.Len() == 0 && delim == "" {
@@ -293,7 +1217,7 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 			}
 			fallthrough
 		default:
-			var hasSymbol bool
+			var hasSymbol, expanded bool
 			for sl := symbolMaxLength - 1; sl >= 0; sl-- {
 				var (
 					peek   []byte
@@ -319,10 +1243,20 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 					}
 
 					hasSymbol = true
-					l.inputWordToken(word)
+
+					// 紧邻重定向符的数字为文件描述符
+					if isRedirect(tokenType) && !l.quoted && isNumber(word.String()) {
+						l.inputToken(TokenIONumber, l.getValue(word))
+					} else if expanded, err = l.expandAlias(ctx, word, symbol); err != nil {
+						return
+					} else if expanded {
+						break
+					} else {
+						l.inputWordToken(word)
+					}
 
 					if tokenType == TokenHeredoc {
-						heredoc = true
+						heredoc, heredocAt = true, l.at
 					} else {
 						l.inputToken(tokenType, symbol)
 					}
@@ -334,20 +1268,24 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 			if !hasSymbol {
 				word.WriteByte(c)
 			}
+
+			if expanded {
+				continue
+			}
 		}
 	}
 
 	// 检查引号是否闭合
-	if word.Len() > 0 && len(quotes) > 0 {
+	if len(quotes) > 0 {
 		var quote = quotes[len(quotes)-1]
-		return fmt.Errorf("syntax error: unclosed quote '%c' at position %d", quote, word.Len())
+		return unexpectedEOF(quotesAt[len(quotesAt)-1], "unexpected EOF while looking for matching `%c'", quote)
 	}
 
 	l.inputWordToken(word)
 
 	// 检查heredoc完整结束
 	if heredoc {
-		return fmt.Errorf("unexpected end of input after heredoc delim")
+		return unexpectedEOF(heredocAt, "unexpected end of input after heredoc delim")
 	}
 
 	return nil
@@ -355,8 +1293,10 @@ func (l *Lexer) Run(ctx context.Context) (err error) {
 
 func NewLexer(reader io.Reader) *Lexer {
 	return &Lexer{
-		reader: bufio.NewReader(reader),
-		tokens: make(chan Token, 1024),
+		reader:  newSource(reader),
+		tokens:  make(chan Token, 1024),
+		last:    TokenSemicolon,
+		command: true,
 	}
 }
 
@@ -367,15 +1307,14 @@ func ParseTokens(input string) (tokens []Token, err error) {
 		token  = lexer.Token()
 	)
 
+	var errs = make(chan error, 1)
 	go func() {
-		if err = lexer.Run(context.Background()); err != nil {
-			return
-		}
+		errs <- lexer.Run(context.Background())
 	}()
 
 	for tk := range token {
 		tokens = append(tokens, tk)
 	}
 
-	return
+	return tokens, <-errs
 }
@@ -1,6 +1,7 @@
 package shell
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -25,7 +26,7 @@ func TestParseTokens(t *testing.T) {
 			input: "echo \"hello world\"",
 			expected: []Token{
 				{Type: TokenWord, Value: "echo"},
-				{Type: TokenWord, Value: "hello world"},
+				{Type: TokenWord, Value: "hello world", Quoted: true},
 			},
 			err: false,
 		},
@@ -34,7 +35,7 @@ func TestParseTokens(t *testing.T) {
 			input: "echo 'hello world'",
 			expected: []Token{
 				{Type: TokenWord, Value: "echo"},
-				{Type: TokenWord, Value: "hello world"},
+				{Type: TokenWord, Value: "hello world", Quoted: true},
 			},
 			err: false,
 		},
@@ -43,7 +44,7 @@ func TestParseTokens(t *testing.T) {
 			input: "echo \"hello\\\"world\"",
 			expected: []Token{
 				{Type: TokenWord, Value: "echo"},
-				{Type: TokenWord, Value: "hello\"world"},
+				{Type: TokenWord, Value: "hello\"world", Quoted: true},
 			},
 			err: false,
 		},
@@ -105,6 +106,41 @@ func TestParseTokens(t *testing.T) {
 			},
 			err: false,
 		},
+		{
+			// 文件描述符重定向
+			input: "ls 2> err.txt",
+			expected: []Token{
+				{Type: TokenWord, Value: "ls"},
+				{Type: TokenIONumber, Value: "2"},
+				{Type: TokenRedirectOut, Value: ">"},
+				{Type: TokenWord, Value: "err.txt"},
+			},
+			err: false,
+		},
+		{
+			// 复制文件描述符
+			input: "ls 2>&1 >&2",
+			expected: []Token{
+				{Type: TokenWord, Value: "ls"},
+				{Type: TokenIONumber, Value: "2"},
+				{Type: TokenRedirectDupOut, Value: ">&"},
+				{Type: TokenWord, Value: "1"},
+				{Type: TokenRedirectDupOut, Value: ">&"},
+				{Type: TokenWord, Value: "2"},
+			},
+			err: false,
+		},
+		{
+			// 带引号的数字不是文件描述符
+			input: `echo "2"> out.txt`,
+			expected: []Token{
+				{Type: TokenWord, Value: "echo"},
+				{Type: TokenWord, Value: "2", Quoted: true},
+				{Type: TokenRedirectOut, Value: ">"},
+				{Type: TokenWord, Value: "out.txt"},
+			},
+			err: false,
+		},
 		{
 			// 输入重定向
 			input: "cat < input.txt",
@@ -176,7 +212,7 @@ func TestParseTokens(t *testing.T) {
 				{Type: TokenHeredoc, Value: "Background task"},
 				{Type: TokenSemicolon, Value: ";"},
 				{Type: TokenWord, Value: "echo"},
-				{Type: TokenWord, Value: "HereDoc submitted"},
+				{Type: TokenWord, Value: "HereDoc submitted", Quoted: true},
 			},
 			err: false,
 		},
@@ -188,9 +224,79 @@ func TestParseTokens(t *testing.T) {
 			if (err != nil) != test.err {
 				t.Errorf("expected error: %v, got: %v", test.err, err)
 			}
+
+			// 位置由 TestTokenPos 检查
+			for i := range tokens {
+				tokens[i].Pos = Pos{}
+			}
+
 			if !reflect.DeepEqual(tokens, test.expected) {
 				t.Errorf("expected: %v, got: %v", test.expected, tokens)
 			}
 		})
 	}
 }
+
+func TestTokenPos(t *testing.T) {
+	var tokens, err = ParseTokens("echo '你好' >out\n  cat <<EOF\nx\nEOF\nls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []Pos{
+		{Offset: 0, Line: 1, Col: 1},   // echo
+		{Offset: 5, Line: 1, Col: 6},   // '你好'
+		{Offset: 14, Line: 1, Col: 11}, // >
+		{Offset: 15, Line: 1, Col: 12}, // out
+		{Offset: 18, Line: 1, Col: 15}, // ;
+		{Offset: 21, Line: 2, Col: 3},  // cat
+		{Offset: 25, Line: 2, Col: 7},  // <<EOF
+		{Offset: 30, Line: 2, Col: 12}, // ;
+		{Offset: 37, Line: 5, Col: 1},  // ls
+	}
+
+	var positions []Pos
+	for _, token := range tokens {
+		positions = append(positions, token.Pos)
+	}
+
+	if !reflect.DeepEqual(positions, expected) {
+		t.Errorf("expected: %v, got: %v", expected, positions)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{input: "echo \"abc", expected: "syntax error at line 1, column 6: unexpected EOF while looking for matching `\"'\necho \"abc\n     ^"},
+		{input: "echo ok\ncat <<EOF\nx\n", expected: "syntax error at line 2, column 5: here-document delimited by end-of-file (wanted `EOF')\ncat <<EOF\n    ^"},
+		{input: "echo 你好 \"x", expected: "syntax error at line 1, column 9: unexpected EOF while looking for matching `\"'\necho 你好 \"x\n          ^"},
+		{input: "echo a\xffb", expected: "syntax error at line 1, column 7: invalid UTF-8 encoding\necho a\n      ^"},
+		{input: "echo 'a\xe4\xbd'", expected: "syntax error at line 1, column 8: invalid UTF-8 encoding\necho 'a\n       ^"},
+		{input: "echo ''", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var _, err = ParseTokens(test.input)
+
+			if test.expected == "" {
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+				return
+			}
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected syntax error, got: %v", err)
+			}
+
+			if err.Error() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, err.Error())
+			}
+		})
+	}
+}
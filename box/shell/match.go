@@ -0,0 +1,385 @@
+package shell
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// patternClasses `[[:name:]]` 字符类
+var patternClasses = map[string]func(r rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  func(r rune) bool { return r >= '0' && r <= '9' },
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+// escapePattern 转义模式中的特殊字符，使其按字面量匹配
+func escapePattern(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("*?[]\\", s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+
+	return sb.String()
+}
+
+// matchPattern 按 shell 的模式匹配整个字符串，`?` 和 `[...]` 按字符而不是字节匹配
+func matchPattern(pattern, s string) bool {
+	var (
+		px, sx         int
+		starPx, starSx = -1, 0 // 最近的 `*` 的位置，匹配失败时回溯
+	)
+
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			if pattern[px] == '*' {
+				starPx, starSx = px, sx
+				px++
+				continue
+			}
+
+			if sx < len(s) {
+				var r, n = utf8.DecodeRuneInString(s[sx:])
+				if ok, width := matchRune(pattern[px:], r); ok {
+					px, sx = px+width, sx+n
+					continue
+				}
+			}
+		}
+
+		// `*` 多匹配一个字符后重试
+		if starPx >= 0 && starSx < len(s) {
+			var _, n = utf8.DecodeRuneInString(s[starSx:])
+			starSx += n
+			px, sx = starPx+1, starSx
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// matchRune 用模式开头的一项匹配一个字符，返回是否匹配和该项的长度
+func matchRune(pattern string, r rune) (ok bool, width int) {
+	switch pattern[0] {
+	case '?':
+		return true, 1
+	case '[':
+		if ok, width, valid := matchClass(pattern, r); valid {
+			return ok, width
+		}
+	case '\\':
+		if len(pattern) > 1 {
+			var c, n = utf8.DecodeRuneInString(pattern[1:])
+			return c == r, n + 1
+		}
+	}
+
+	var c, n = utf8.DecodeRuneInString(pattern)
+	return c == r, n
+}
+
+// classRune 读取 `[...]` 中的一个字符，反斜杠转义之后的字符
+func classRune(pattern string) (r rune, width int) {
+	if pattern[0] == '\\' && len(pattern) > 1 {
+		r, width = utf8.DecodeRuneInString(pattern[1:])
+		return r, width + 1
+	}
+
+	return utf8.DecodeRuneInString(pattern)
+}
+
+// matchClass 匹配 `[...]`，未闭合时 valid 为 false，此时 `[` 按字面量匹配
+func matchClass(pattern string, r rune) (ok bool, width int, valid bool) {
+	var (
+		i      = 1
+		negate bool
+	)
+
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	for first := true; ; first = false {
+		if i >= len(pattern) {
+			return false, 0, false
+		}
+
+		// 开头的 `]` 为普通字符
+		if pattern[i] == ']' && !first {
+			return ok != negate, i + 1, true
+		}
+
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				if class, exists := patternClasses[pattern[i+2:i+2+end]]; exists && class(r) {
+					ok = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		var lo, n = classRune(pattern[i:])
+		var hi = lo
+		if i += n; i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, n = classRune(pattern[i+1:])
+			i += n + 1
+		}
+
+		if lo <= r && r <= hi {
+			ok = true
+		}
+	}
+}
+
+// trimPattern 删除匹配模式的前缀或后缀：`#` 最短前缀，`##` 最长前缀，`%` 最短后缀，`%%` 最长后缀
+func trimPattern(value, pattern, op string) string {
+	// 按字符划分的位置
+	var offsets []int
+	for i := range value {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(value))
+
+	var n = len(offsets)
+	for k := 0; k < n; k++ {
+		switch op {
+		case "#":
+			if i := offsets[k]; matchPattern(pattern, value[:i]) {
+				return value[i:]
+			}
+		case "##":
+			if i := offsets[n-1-k]; matchPattern(pattern, value[:i]) {
+				return value[i:]
+			}
+		case "%":
+			if i := offsets[n-1-k]; matchPattern(pattern, value[i:]) {
+				return value[:i]
+			}
+		case "%%":
+			if i := offsets[k]; matchPattern(pattern, value[i:]) {
+				return value[:i]
+			}
+		}
+	}
+
+	return value
+}
+
+// literalPattern 没有通配符的模式对应的字符串，移除转义的反斜杠
+func literalPattern(pattern string) (literal string, ok bool) {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*', '?', '[':
+			return "", false
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			sb.WriteByte(pattern[i])
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String(), true
+}
+
+// matchStates 模式匹配中模式的各个位置，值为到达该位置的最早的匹配起点，-1 表示未到达
+type matchStates []int
+
+// add 从 start 开始的匹配到达模式的位置 px，`*` 可以匹配空串，同时到达其后的位置
+func (m matchStates) add(pattern string, px, start int) {
+	if m[px] >= 0 && m[px] <= start {
+		return
+	}
+
+	if m[px] = start; px < len(pattern) && pattern[px] == '*' {
+		m.add(pattern, px+1, start)
+	}
+}
+
+// step 用字符 r 推进全部位置，起点大于 limit 的匹配不再推进，返回是否还有位置
+func (m matchStates) step(next matchStates, pattern string, r rune, limit int) (alive bool) {
+	for px := range next {
+		next[px] = -1
+	}
+
+	for px := 0; px < len(pattern); px++ {
+		if m[px] < 0 || limit >= 0 && m[px] > limit {
+			continue
+		}
+
+		if pattern[px] == '*' {
+			next.add(pattern, px, m[px])
+		} else if ok, width := matchRune(pattern[px:], r); ok {
+			next.add(pattern, px+width, m[px])
+		} else {
+			continue
+		}
+
+		alive = true
+	}
+
+	return
+}
+
+// matchPrefix 扫描一次 s，返回匹配模式的最长前缀的长度，没有匹配时返回 -1
+func matchPrefix(pattern, s string) (end int) {
+	var cur, next = make(matchStates, len(pattern)+1), make(matchStates, len(pattern)+1)
+	for px := range cur {
+		cur[px] = -1
+	}
+
+	if cur.add(pattern, 0, 0); cur[len(pattern)] < 0 {
+		end = -1
+	}
+
+	for sx := 0; sx < len(s); {
+		var r, n = utf8.DecodeRuneInString(s[sx:])
+		if sx += n; !cur.step(next, pattern, r, -1) {
+			break
+		}
+
+		if cur, next = next, cur; cur[len(pattern)] >= 0 {
+			end = sx
+		}
+	}
+
+	return
+}
+
+// findPattern 扫描一次 s，返回最左边的非空匹配中最长的一个，没有匹配时 start 为 -1，
+// 同时推进从各个位置开始的匹配，同一模式位置只保留最早的起点
+func findPattern(pattern, s string) (start, end int) {
+	var cur, next = make(matchStates, len(pattern)+1), make(matchStates, len(pattern)+1)
+	for px := range cur {
+		cur[px] = -1
+	}
+
+	start = -1
+	for sx := 0; ; {
+		// 找到匹配之前每个位置都可以开始新的匹配
+		if start < 0 {
+			cur.add(pattern, 0, sx)
+		}
+
+		if from := cur[len(pattern)]; from >= 0 && from < sx && (start < 0 || from <= start) {
+			start, end = from, sx
+		}
+
+		if sx >= len(s) {
+			return
+		}
+
+		var r, n = utf8.DecodeRuneInString(s[sx:])
+		sx += n
+
+		// 找到匹配之后只推进起点不晚于它的匹配，全部结束时得到最长的匹配
+		if !cur.step(next, pattern, r, start) && start >= 0 {
+			return
+		}
+		cur, next = next, cur
+	}
+}
+
+// replacePattern 替换匹配模式的部分：`/` 第一个最长匹配，`//` 全部最长匹配，
+// `/#` 匹配前缀，`/%` 匹配后缀
+func replacePattern(value, pattern, replacement, op string) string {
+	// 没有通配符时按字符串替换，空模式只匹配前缀和后缀
+	if literal, ok := literalPattern(pattern); ok {
+		switch {
+		case op == "/#" && strings.HasPrefix(value, literal):
+			return replacement + value[len(literal):]
+		case op == "/%" && strings.HasSuffix(value, literal):
+			return value[:len(value)-len(literal)] + replacement
+		case op == "/" && literal != "":
+			return strings.Replace(value, literal, replacement, 1)
+		case op == "//" && literal != "":
+			return strings.ReplaceAll(value, literal, replacement)
+		}
+		return value
+	}
+
+	switch op {
+	case "/#":
+		if end := matchPrefix(pattern, value); end >= 0 {
+			return replacement + value[end:]
+		}
+		return value
+	case "/%":
+		for i := range value {
+			if matchPattern(pattern, value[i:]) {
+				return value[:i] + replacement
+			}
+		}
+		if matchPattern(pattern, "") {
+			return value + replacement
+		}
+		return value
+	}
+
+	// 每次从上一个匹配之后查找最左边的最长匹配，不匹配空串
+	var sb strings.Builder
+	for len(value) > 0 {
+		var start, end = findPattern(pattern, value)
+		if start < 0 {
+			break
+		}
+
+		sb.WriteString(value[:start])
+		sb.WriteString(replacement)
+
+		if value = value[end:]; op == "/" {
+			break
+		}
+	}
+	sb.WriteString(value)
+
+	return sb.String()
+}
+
+// convertCase 转换匹配模式的字符的大小写：`^` 第一个字符转大写，`^^` 全部转大写，
+// `,` 第一个字符转小写，`,,` 全部转小写，空模式匹配任意字符
+func convertCase(value, pattern, op string) string {
+	if pattern == "" {
+		pattern = "?"
+	}
+
+	var convert = unicode.ToUpper
+	if op[0] == ',' {
+		convert = unicode.ToLower
+	}
+
+	var sb strings.Builder
+	for i, r := range value {
+		if len(op) == 1 && i > 0 {
+			sb.WriteString(value[i:])
+			break
+		}
+
+		if matchPattern(pattern, string(r)) {
+			r = convert(r)
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	var tests = []struct {
+		pattern  string
+		s        string
+		expected bool
+	}{
+		{pattern: "", s: "", expected: true},
+		{pattern: "*", s: "", expected: true},
+		{pattern: "a*c", s: "abbbc", expected: true},
+		{pattern: "a*c", s: "abbb", expected: false},
+		{pattern: "?", s: "你", expected: true},
+		{pattern: "??", s: "你", expected: false},
+		{pattern: "你?", s: "你好", expected: true},
+		{pattern: "[a-c]x", s: "bx", expected: true},
+		{pattern: "[!a-c]x", s: "dx", expected: true},
+		{pattern: "[^a-c]x", s: "ax", expected: false},
+		{pattern: "[]]", s: "]", expected: true},
+		{pattern: "[你好]", s: "好", expected: true},
+		{pattern: "[一-龥]", s: "中", expected: true},
+		{pattern: "[[:digit:]][[:alpha:]]", s: "1é", expected: true},
+		{pattern: `\*`, s: "*", expected: true},
+		{pattern: `\*`, s: "a", expected: false},
+		{pattern: "[", s: "[", expected: true},
+		{pattern: "a[b", s: "a[b", expected: true},
+	}
+
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.s); got != test.expected {
+			t.Errorf("match(%q, %q) expected: %v, got: %v", test.pattern, test.s, test.expected, got)
+		}
+	}
+}
+
+func TestReplacePattern(t *testing.T) {
+	var tests = []struct {
+		value, pattern, replacement, op string
+		expected                        string
+	}{
+		{value: "aaa", pattern: "a", replacement: "b", op: "/", expected: "baa"},
+		{value: "aaa", pattern: "a", replacement: "b", op: "//", expected: "bbb"},
+		{value: "abcabc", pattern: "b*", replacement: "x", op: "/", expected: "ax"},
+		{value: "abc", pattern: "", replacement: "x", op: "//", expected: "abc"},
+		{value: "abc", pattern: "", replacement: "x", op: "/#", expected: "xabc"},
+		{value: "abc", pattern: "b", replacement: "x", op: "/#", expected: "abc"},
+		{value: "你好你", pattern: "你", replacement: "x", op: "/%", expected: "你好x"},
+		{value: "a*b*c", pattern: `\*`, replacement: "x", op: "//", expected: "axbxc"},
+		{value: "abcabc", pattern: "?c", replacement: "x", op: "//", expected: "axax"},
+		{value: "aXbYc", pattern: "[A-Z]", replacement: "-", op: "//", expected: "a-b-c"},
+		{value: "a[b", pattern: "[b", replacement: "x", op: "/", expected: "ax"},
+		{value: "abab", pattern: "a*", replacement: "x", op: "/#", expected: "x"},
+		{value: "abab", pattern: "b*", replacement: "x", op: "/#", expected: "abab"},
+		{value: "abab", pattern: "*b", replacement: "x", op: "/%", expected: "x"},
+		{value: "abc", pattern: "*", replacement: "x", op: "/%", expected: "x"},
+		{value: "abc", pattern: "", replacement: "x", op: "/%", expected: "abcx"},
+		{value: "xaybz", pattern: "[ab]?", replacement: "", op: "//", expected: "x"},
+	}
+
+	for _, test := range tests {
+		if got := replacePattern(test.value, test.pattern, test.replacement, test.op); got != test.expected {
+			t.Errorf("replace(%q, %q, %q, %q) expected: %q, got: %q", test.value, test.pattern, test.replacement, test.op, test.expected, got)
+		}
+	}
+}
+
+func TestReplacePatternLong(t *testing.T) {
+	// 每个位置只扫描一次，长字符串没有匹配时也能很快完成
+	var value = strings.Repeat("a", 20000)
+	if got := replacePattern(value, "*b", "x", "//"); got != value {
+		t.Errorf("unexpected replacement: %q", got[:16])
+	}
+
+	if got := replacePattern(value, "a*", "x", "/"); got != "x" {
+		t.Errorf("expected: %q, got: %q", "x", got)
+	}
+}
+
+func TestConvertCase(t *testing.T) {
+	var tests = []struct {
+		value, pattern, op string
+		expected           string
+	}{
+		{value: "abc", op: "^", expected: "Abc"},
+		{value: "abc", op: "^^", expected: "ABC"},
+		{value: "ABC", op: ",", expected: "aBC"},
+		{value: "ABC", pattern: "[AC]", op: ",,", expected: "aBc"},
+		{value: "abc", pattern: "b", op: "^", expected: "abc"},
+		{value: "éa", op: "^", expected: "Éa"},
+	}
+
+	for _, test := range tests {
+		if got := convertCase(test.value, test.pattern, test.op); got != test.expected {
+			t.Errorf("convert(%q, %q, %q) expected: %q, got: %q", test.value, test.pattern, test.op, test.expected, got)
+		}
+	}
+}
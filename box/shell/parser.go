@@ -2,20 +2,39 @@ package shell
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
 	"strings"
 )
 
+// Redirect 带文件描述符的重定向，如 `2>err.log`、`2>&1`、`3<&-`
+type Redirect struct {
+	Fd     int    `json:"fd"`     // 被重定向的文件描述符
+	Op     string `json:"op"`     // 重定向符号 < > >> <& >&
+	Target string `json:"target"` // 目标文件或文件描述符
+}
+
 type Command struct {
-	Path       string
-	Args       []string
-	Pipe       *Command // |
-	Or         *Command // ||
-	And        *Command // &&
-	Input      string   // <
-	Output     string   // >
-	Append     string   // >>
-	Heredoc    string   // <<
-	Background bool     // &
+	Path       string     `json:"path"`
+	Args       []string   `json:"args,omitempty"`
+	Pipe       *Command   `json:"pipe,omitempty"`       // |
+	Or         *Command   `json:"or,omitempty"`         // ||
+	And        *Command   `json:"and,omitempty"`        // &&
+	Input      string     `json:"input,omitempty"`      // <
+	Output     string     `json:"output,omitempty"`     // >
+	Append     string     `json:"append,omitempty"`     // >>
+	Heredoc    string     `json:"heredoc,omitempty"`    // <<
+	Background bool       `json:"background,omitempty"` // &
+	Negate     bool       `json:"negate,omitempty"`     // ! 管道的退出码取反
+	Time       bool       `json:"time,omitempty"`       // time 统计管道的执行时间
+	TimePosix  bool       `json:"time-posix,omitempty"` // time -p 使用 POSIX 格式输出
+	Redirects  []Redirect `json:"redirects,omitempty"`
+	Pos        Pos        `json:"pos"` // 命令的起始位置
+
+	sync bool  // 同步命令，执行方收到后通知词法分析继续
+	err  error // 语法错误，执行方收到后报告错误
 
 	//Next       *Command // ;
 	//Child      *Command // $()
@@ -28,6 +47,7 @@ func (c *Command) CmdArgs() []string {
 type Parser struct {
 	tokens   <-chan Token
 	commands chan Command
+	back     *Token // 放回的 token
 }
 
 func NewParser(tokens <-chan Token) *Parser {
@@ -49,7 +69,77 @@ func (p *Parser) put(command Command) {
 	p.commands <- command
 }
 
+// redirect 解析重定向目标，带文件描述符或复制描述符的重定向记录到 Redirects
+func (p *Parser) redirect(ctx context.Context, command *Command, token Token, fd int) (err error) {
+	var next, run = p.token(ctx)
+	if !run {
+		return unexpectedEOF(token.Pos, "unexpected end of input after `%s'", token.Value)
+	}
+
+	if next.Type != TokenWord {
+		p.unread(next)
+		return p.unexpected(next)
+	}
+
+	var target = next.Value
+
+	if fd < 0 {
+		switch token.Type {
+		case TokenRedirectIn:
+			command.Input = target
+			return
+		case TokenRedirectOut:
+			command.Output = target
+			return
+		case TokenRedirectAppend:
+			command.Append = target
+			return
+		case TokenRedirectDupIn:
+			fd = 0
+		default:
+			fd = 1
+		}
+	}
+
+	command.Redirects = append(command.Redirects, Redirect{
+		Fd:     fd,
+		Op:     token.Value,
+		Target: target,
+	})
+
+	return
+}
+
+// unexpected 意外的 token
+func (p *Parser) unexpected(token Token) error {
+	return &SyntaxError{Pos: token.Pos, Msg: fmt.Sprintf("unexpected token `%s'", token.Value)}
+}
+
+// blockEnds 复合命令的保留字及对应的结束保留字
+var blockEnds = map[string]string{
+	"if":    "fi",
+	"while": "done",
+	"until": "done",
+	"for":   "done",
+	"case":  "esac",
+}
+
+// unread 放回读取的 token，下次读取时返回
+func (p *Parser) unread(token Token) {
+	p.back = &token
+}
+
+// empty 命令是否为空，仅有重定向的命令不为空
+func (c *Command) empty() bool {
+	return c.Path == "" && c.Input == "" && c.Output == "" && c.Append == "" && c.Heredoc == "" && len(c.Redirects) == 0
+}
+
 func (p *Parser) token(ctx context.Context) (token Token, run bool) {
+	if p.back != nil {
+		token, p.back = *p.back, nil
+		return token, true
+	}
+
 	select {
 	case <-ctx.Done():
 		return Token{}, false
@@ -68,96 +158,189 @@ func (p *Parser) Run(ctx context.Context) (err error) {
 		current    = &command
 		front      = current
 		background bool
+		fd         = -1
+		operator   *Token  // 之后需要命令的运算符
+		skip       bool    // 出错后跳过当前命令剩余的 token
+		blocks     []Token // 未结束的复合命令
 	)
 
+	// 语法错误作为命令发送给执行方，丢弃当前命令
+	var fail = func(err error) {
+		p.commands <- Command{err: err}
+		command, operator, background, skip, fd = Command{}, nil, false, true, -1
+		current, front = &command, &command
+	}
+
 	for {
 		if token, run = p.token(ctx); !run {
 			break
 		}
 
-		if background {
-			if token.Type == TokenHeredoc {
-				command.Heredoc = token.Value
-			}
+		if token.Type == TokenSync {
+			p.commands <- Command{sync: true}
+			continue
+		}
+
+		if skip {
+			skip = token.Type != TokenSemicolon
+			continue
+		}
+
+		// 后台命令之后的分隔符不需要命令
+		var detached = background
 
+		// 后台命令的 heredoc 之后的 token 提交命令
+		if background && token.Type == TokenHeredoc {
+			command.Heredoc = token.Value
+			continue
+		}
+
+		if background {
 			p.put(command)
 			command = Command{}
 			current = &command
+			front = current
 
 			background = false
-
-			if token.Type == TokenHeredoc {
-				continue
-			}
 		}
 
 		switch token.Type {
 		case TokenWord:
+			// 管道开头没有引号的 `time [-p]` 和 `!`
+			if current.empty() && !token.Quoted && (operator == nil || operator.Type != TokenPipe) {
+				switch {
+				case token.Value == "time" && !current.Time && !current.Negate:
+					current.Time = true
+					continue
+				case token.Value == "-p" && current.Time && !current.TimePosix && !current.Negate:
+					current.TimePosix = true
+					continue
+				case token.Value == "!" && !current.Negate:
+					current.Negate = true
+					continue
+				}
+			}
+
 			if current.Path == "" {
-				current.Path = token.Value
+				current.Path, current.Pos = token.Value, token.Pos
+
+				// 记录命令位置的复合命令保留字
+				if _, ok := blockEnds[token.Value]; ok {
+					blocks = append(blocks, token)
+				} else if n := len(blocks); n > 0 && blockEnds[blocks[n-1].Value] == token.Value {
+					blocks = blocks[:n-1]
+				}
 			} else {
 				current.Args = append(current.Args, token.Value)
 			}
-		case TokenPipe:
-			current.Pipe = new(Command)
-			current = current.Pipe
-			front = current
-		case TokenOr:
-			front.Or = new(Command)
-			current = front.Or
-			front = current
-		case TokenAnd:
-			current.And = new(Command)
-			current = current.And
-		case TokenRedirectIn:
-			current.Input = (<-p.tokens).Value
-		case TokenRedirectOut:
-			current.Output = (<-p.tokens).Value
-		case TokenRedirectAppend:
-			current.Append = (<-p.tokens).Value
+			operator = nil
+		case TokenPipe, TokenOr, TokenAnd, TokenBackground:
+			if current.empty() {
+				fail(p.unexpected(token))
+				continue
+			}
+
+			switch token.Type {
+			case TokenPipe:
+				current.Pipe = new(Command)
+				current = current.Pipe
+				front = current
+			case TokenOr:
+				front.Or = new(Command)
+				current = front.Or
+				front = current
+			case TokenAnd:
+				current.And = new(Command)
+				current = current.And
+			case TokenBackground:
+				background = true
+				current.Background = true
+			}
+
+			if op := token; op.Type != TokenBackground {
+				operator = &op
+			}
+		case TokenIONumber:
+			fd, _ = strconv.Atoi(token.Value)
+			continue
+		case TokenRedirectIn, TokenRedirectOut, TokenRedirectAppend, TokenRedirectDupIn, TokenRedirectDupOut:
+			if err = p.redirect(ctx, current, token, fd); err != nil {
+				if fail(err); isIncomplete(err) {
+					return nil
+				}
+				continue
+			}
+			operator = nil
 		case TokenHeredoc:
 			current.Heredoc = token.Value
-		case TokenBackground:
-			background = true
-			current.Background = true
+			operator = nil
 		case TokenSemicolon:
+			if operator != nil {
+				p.unread(token)
+				fail(p.unexpected(token))
+				continue
+			}
+
+			// 分号之前没有命令，case 中的 `;;` 除外
+			if current.empty() && !detached && (len(blocks) == 0 || blocks[len(blocks)-1].Value != "case") {
+				fail(p.unexpected(token))
+				continue
+			}
+
 			p.put(command)
 			command = Command{}
 			current = &command
+			front = current
 		case TokenVar:
 		case TokenCmd:
 		}
+
+		fd = -1
+	}
+
+	// 运算符之后输入结束
+	if operator != nil {
+		p.commands <- Command{err: unexpectedEOF(operator.Pos, "unexpected end of input after `%s'", operator.Value)}
+		return nil
 	}
 
 	p.put(command)
 
+	// 复合命令未结束
+	if n := len(blocks); n > 0 {
+		var block = blocks[n-1]
+		p.commands <- Command{err: unexpectedEOF(block.Pos, "unexpected end of input while looking for matching `%s'", blockEnds[block.Value])}
+	}
+
 	return nil
 }
 
-func ParseCommands(input string) (commands []Command, err error) {
+// Parse 解析输入中的全部命令，返回词法分析和语法分析的错误
+func Parse(reader io.Reader) (commands []Command, err error) {
 	var (
-		ctx     = context.Background()
-		lexer   = NewLexer(strings.NewReader(input))
-		parser  = NewParser(lexer.Token())
-		command = parser.Command()
-		errors  = make(chan error, 20)
+		ctx    = context.Background()
+		lexer  = NewLexer(reader)
+		parser = NewParser(lexer.Token())
+		errs   = make(chan error, 2)
 	)
 
-	go func() { errors <- lexer.Run(ctx) }()
+	go func() { errs <- lexer.Run(ctx) }()
+
+	go func() { errs <- parser.Run(ctx) }()
 
-	go func() { errors <- parser.Run(ctx) }()
+	var syntax []error
+	for c := range parser.Command() {
+		if c.err != nil {
+			syntax = append(syntax, lexer.snippet(c.err))
+			continue
+		}
 
-	for c := range command {
 		commands = append(commands, c)
 	}
 
-	close(errors)
-
-	for err = range errors {
-		if err != nil {
-			return
-		}
-	}
+	return commands, errors.Join(append(syntax, <-errs, <-errs)...)
+}
 
-	return
+func ParseCommands(input string) (commands []Command, err error) {
+	return Parse(strings.NewReader(input))
 }
@@ -1,7 +1,9 @@
 package shell
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -53,6 +55,33 @@ func TestNewParser1(t *testing.T) {
 			},
 			err: false,
 		},
+		{
+			input: "exec 3> log.txt 4<&0",
+			expected: []Command{
+				{
+					Path: "exec",
+					Redirects: []Redirect{
+						{Fd: 3, Op: ">", Target: "log.txt"},
+						{Fd: 4, Op: "<&", Target: "0"},
+					},
+				},
+			},
+			err: false,
+		},
+		{
+			input: "ls 2>&1 >&2 2>> err.log",
+			expected: []Command{
+				{
+					Path: "ls",
+					Redirects: []Redirect{
+						{Fd: 2, Op: ">&", Target: "1"},
+						{Fd: 1, Op: ">&", Target: "2"},
+						{Fd: 2, Op: ">>", Target: "err.log"},
+					},
+				},
+			},
+			err: false,
+		},
 		{
 			input: "cat << EOF\nThis is a test\nEOF\n",
 			expected: []Command{
@@ -490,9 +519,93 @@ func TestNewParser1(t *testing.T) {
 				t.Errorf("expected error: %v, got: %v", test.err, err)
 			}
 
+			// 位置由 TestParserPos 检查
+			for i := range commands {
+				clearPos(&commands[i])
+			}
+
 			if !reflect.DeepEqual(commands, test.expected) {
 				t.Errorf("expected: %v, got: %v", test.expected, commands)
 			}
 		})
 	}
 }
+
+// clearPos 清除命令及其后续命令的位置
+func clearPos(command *Command) {
+	if command == nil {
+		return
+	}
+
+	command.Pos = Pos{}
+	clearPos(command.Pipe)
+	clearPos(command.Or)
+	clearPos(command.And)
+}
+
+func TestParserPos(t *testing.T) {
+	commands, err := ParseCommands("echo a |  grep b\n  ls; pwd\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commands) != 3 || commands[0].Pipe == nil {
+		t.Fatalf("unexpected commands: %v", commands)
+	}
+
+	var positions = []Pos{commands[0].Pos, commands[0].Pipe.Pos, commands[1].Pos, commands[2].Pos}
+	var expected = []Pos{{Offset: 0, Line: 1, Col: 1}, {Offset: 10, Line: 1, Col: 11}, {Offset: 19, Line: 2, Col: 3}, {Offset: 23, Line: 2, Col: 7}}
+
+	if !reflect.DeepEqual(positions, expected) {
+		t.Errorf("expected: %v, got: %v", expected, positions)
+	}
+}
+
+func TestParserError(t *testing.T) {
+	var tests = []struct {
+		input    string
+		commands int
+		expected string
+	}{
+		{input: "cat <", expected: "syntax error at line 1, column 5: unexpected end of input after `<'"},
+		{input: "echo a > | wc", expected: "syntax error at line 1, column 10: unexpected token `|'"},
+		{input: "ls |", expected: "syntax error at line 1, column 4: unexpected end of input after `|'"},
+		{input: "ls && ; echo b", commands: 1, expected: "syntax error at line 1, column 7: unexpected token `;'"},
+		{input: "|| ls", expected: "syntax error at line 1, column 1: unexpected token `||'"},
+		{input: "ls & | wc; echo b", commands: 2, expected: "syntax error at line 1, column 6: unexpected token `|'"},
+		{input: "ls ;; ls", commands: 1, expected: "syntax error at line 1, column 5: unexpected token `;'"},
+		{input: "ls ; ; ls", commands: 1, expected: "syntax error at line 1, column 6: unexpected token `;'"},
+		{input: "; ls", expected: "syntax error at line 1, column 1: unexpected token `;'"},
+		{input: "ls\n; ls", commands: 1, expected: "syntax error at line 2, column 1: unexpected token `;'"},
+		{input: "\n\nls;\n\necho a &\n\ncat <<E &\nx\nE\n\n", commands: 3},
+		{input: "ls |\n  wc -l", commands: 1},
+		{input: "while true; do\n  if x; then y; fi\n", commands: 5, expected: "syntax error at line 1, column 1: unexpected end of input while looking for matching `done'"},
+		{input: "while true; do\n  if x; then y; fi\ndone", commands: 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var commands, err = ParseCommands(test.input)
+
+			if len(commands) != test.commands {
+				t.Errorf("expected %d commands, got: %v", test.commands, commands)
+			}
+
+			if test.expected == "" {
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+				return
+			}
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected syntax error, got: %v", err)
+			}
+
+			if msg, _, _ := strings.Cut(err.Error(), "\n"); msg != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, msg)
+			}
+		})
+	}
+}
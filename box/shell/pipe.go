@@ -0,0 +1,232 @@
+package shell
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/zooyer/gobox/types"
+)
+
+// pipeBufferSize 进程内管道的缓冲区大小，与 Linux 管道的默认容量相同
+const pipeBufferSize = 64 * 1024
+
+// sigpipe 写入已关闭的管道时的退出码，与被 SIGPIPE 终止的进程相同
+const sigpipe = 128 + int(syscall.SIGPIPE)
+
+// memPipe 进程内的缓冲管道，用于内置命令和 box 命令之间传递数据，不占用文件描述符
+type memPipe struct {
+	mutex   sync.Mutex
+	cond    sync.Cond
+	buf     []byte
+	head    int // 未读数据的起始位置
+	length  int // 未读数据的长度
+	rclosed bool
+	wclosed bool
+}
+
+// newMemPipe 创建进程内管道，读端关闭后写入返回 EPIPE，写端关闭后读完数据返回 EOF
+func newMemPipe() (r *memPipeReader, w *memPipeWriter) {
+	var p = &memPipe{buf: make([]byte, pipeBufferSize)}
+	p.cond.L = &p.mutex
+
+	return &memPipeReader{p}, &memPipeWriter{p}
+}
+
+func (p *memPipe) read(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for p.length == 0 && !p.wclosed && !p.rclosed {
+		p.cond.Wait()
+	}
+
+	switch {
+	case p.rclosed:
+		return 0, io.ErrClosedPipe
+	case p.length == 0:
+		return 0, io.EOF
+	}
+
+	// 环形缓冲区中的数据可能分为两段
+	for n < len(b) && p.length > 0 {
+		var m = copy(b[n:], p.buf[p.head:min(p.head+p.length, len(p.buf))])
+		n += m
+		p.head, p.length = (p.head+m)%len(p.buf), p.length-m
+	}
+
+	p.cond.Broadcast()
+
+	return
+}
+
+func (p *memPipe) write(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for len(b) > 0 {
+		for p.length == len(p.buf) && !p.rclosed && !p.wclosed {
+			p.cond.Wait()
+		}
+
+		switch {
+		case p.rclosed:
+			return n, syscall.EPIPE
+		case p.wclosed:
+			return n, io.ErrClosedPipe
+		}
+
+		var tail = (p.head + p.length) % len(p.buf)
+		var m = copy(p.buf[tail:min(tail+len(p.buf)-p.length, len(p.buf))], b)
+		n, b = n+m, b[m:]
+		p.length += m
+
+		p.cond.Broadcast()
+	}
+
+	return
+}
+
+func (p *memPipe) close(reader bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if reader {
+		p.rclosed = true
+	} else {
+		p.wclosed = true
+	}
+
+	p.cond.Broadcast()
+
+	return nil
+}
+
+type memPipeReader struct{ p *memPipe }
+
+func (r *memPipeReader) Read(b []byte) (int, error) { return r.p.read(b) }
+
+func (r *memPipeReader) Close() error { return r.p.close(true) }
+
+type memPipeWriter struct{ p *memPipe }
+
+func (w *memPipeWriter) Write(b []byte) (int, error) { return w.p.write(b) }
+
+func (w *memPipeWriter) Close() error { return w.p.close(false) }
+
+// pipeline 管道一侧的命令的退出状态，写入已关闭的管道后视为被 SIGPIPE 终止，不再输出错误信息
+type pipeline struct {
+	broken atomic.Bool
+}
+
+// stdout 包装写入管道的输出，写入失败为 EPIPE 时记录
+func (p *pipeline) stdout(w io.Writer) io.Writer {
+	return writerFunc(func(b []byte) (n int, err error) {
+		if p.broken.Load() {
+			return 0, syscall.EPIPE
+		}
+
+		if n, err = w.Write(b); err != nil && isBrokenPipe(err) {
+			p.broken.Store(true)
+		}
+
+		return
+	})
+}
+
+// stderr 包装错误输出，管道断开后丢弃
+func (p *pipeline) stderr(w io.Writer) io.Writer {
+	return writerFunc(func(b []byte) (int, error) {
+		if p.broken.Load() {
+			return len(b), nil
+		}
+
+		return w.Write(b)
+	})
+}
+
+// code 管道断开时返回 SIGPIPE 的退出码
+func (p *pipeline) code(code int) int {
+	if p.broken.Load() {
+		return sigpipe
+	}
+
+	return code
+}
+
+// option 进程内命令的输出写入管道时，包装标准输出和错误输出
+func (p *pipeline) option(option types.Option, command *Command) types.Option {
+	if command.Pipe != nil {
+		option.Stdout, option.Stderr = p.stdout(option.Stdout), p.stderr(option.Stderr)
+	}
+
+	return option
+}
+
+type writerFunc func(b []byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+// isBrokenPipe 是否为写入已关闭的管道的错误
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// inProcess 命令是否在当前进程内执行，命令名需要展开时无法预先确定，按外部命令处理
+func (sh *Gosh) inProcess(command *Command) bool {
+	var _, argv = splitAssign(command.CmdArgs())
+	if len(argv) == 0 {
+		return true
+	}
+
+	if strings.IndexByte(argv[0], '$') >= 0 {
+		return false
+	}
+
+	var name = unmark(argv[0])
+
+	return sh.Builtin[name] != nil || sh.applet(name) != nil
+}
+
+// pipe 创建管道：两端都在进程内执行时使用进程内管道，否则使用系统管道
+func (sh *Gosh) pipe(left, right *Command) (r io.ReadCloser, w io.WriteCloser, err error) {
+	if sh.inProcess(left) && sh.inProcess(right) {
+		r, w = newMemPipe()
+		return
+	}
+
+	return os.Pipe()
+}
+
+// pipeStatus 将管道中每个命令的退出码记录到 PIPESTATUS，返回管道的退出码：
+// 设置 pipefail 时为最后一个非零的退出码，以 `!` 开头的管道取反
+func (sh *Gosh) pipeStatus(command *Command, codes []int) (code int) {
+	var status = make([]string, 0, len(codes))
+	for _, c := range codes {
+		status = append(status, strconv.Itoa(c))
+	}
+	_ = sh.setArray("PIPESTATUS", status)
+
+	code = codes[len(codes)-1]
+	if sh.options["pipefail"] {
+		for _, c := range codes {
+			if c != 0 {
+				code = c
+			}
+		}
+	}
+
+	if command.Negate {
+		if code == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	return
+}
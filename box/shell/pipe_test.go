@@ -0,0 +1,286 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zooyer/gobox/types"
+)
+
+func TestMemPipe(t *testing.T) {
+	var r, w = newMemPipe()
+
+	// 超过缓冲区大小的数据分多次读取
+	var data = strings.Repeat("0123456789", pipeBufferSize/5)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Error(err)
+		}
+		_ = w.Close()
+	}()
+
+	var got, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wg.Wait(); string(got) != data {
+		t.Fatalf("expected %d bytes, got %d bytes", len(data), len(got))
+	}
+
+	// 读端关闭后写入返回 EPIPE
+	r, w = newMemPipe()
+	_ = r.Close()
+
+	if _, err = w.Write([]byte("x")); !errors.Is(err, syscall.EPIPE) {
+		t.Errorf("expected EPIPE, got: %v", err)
+	}
+}
+
+// yes 不断输出一行，直到写入失败
+func yes(opt types.Option, args []string) (code int) {
+	for {
+		if _, err := fmt.Fprintln(opt.Stdout, "y"); err != nil {
+			_, _ = fmt.Fprintln(opt.Stderr, "yes:", err)
+			return 1
+		}
+	}
+}
+
+// head 只读取第一行
+func head(opt types.Option, args []string) (code int) {
+	var line, _ = bufio.NewReader(opt.Stdin).ReadString('\n')
+	_, _ = fmt.Fprint(opt.Stdout, line)
+	return 0
+}
+
+func TestPipeline(t *testing.T) {
+	var tests = []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{
+			name:     "in-process",
+			script:   "echo hello | cat | cat",
+			expected: "hello\n",
+		},
+		{
+			// 读端结束后写端视为被 SIGPIPE 终止，不输出错误
+			name:     "sigpipe",
+			script:   "yes | head; echo $?",
+			expected: "y\n0\n",
+		},
+		{
+			name:     "exit status",
+			script:   "yes | head | false; echo $?; echo a | true && echo ok",
+			expected: "1\nok\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			sh.Builtin["yes"], sh.Builtin["head"] = yes, head
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+// slow 等待一段时间后输出到错误输出
+func slow(opt types.Option, args []string) (code int) {
+	time.Sleep(50 * time.Millisecond)
+	_, _ = fmt.Fprintln(opt.Stderr, "slow")
+	return 0
+}
+
+func TestPipeStatus(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string
+		status   []string
+	}{
+		{script: "yes | head | false; echo $?", expected: "1\n", status: []string{"0"}},
+		{script: "yes | head", expected: "y\n", status: []string{"141", "0"}},
+		{script: "false | true; echo $?", expected: "0\n"},
+		{script: "set -o pipefail; false | true; echo $?", expected: "1\n"},
+		{script: "set -o pipefail; exit 3 | false | true", status: []string{"3", "1", "0"}},
+		{script: "set -o pipefail; set +o pipefail; false | true; echo $?", expected: "0\n"},
+		{script: "! true; echo $?; ! false | false; echo $?", expected: "1\n0\n"},
+		{script: "true && ! false && echo ok", expected: "ok\n"},
+		{script: "echo a | true && echo ok", expected: "ok\n"},
+		{script: "slow | true && echo done", expected: "slow\ndone\n"},
+		{script: "set -o pipefail; set -o; set +o", expected: "pipefail       \ton\nset -o pipefail\n"},
+		{script: "set -o nope; echo $?", expected: "shell: set: nope: invalid option name\n2\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			sh.Builtin["yes"], sh.Builtin["head"], sh.Builtin["slow"] = yes, head, slow
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+
+			if status := sh.vars["PIPESTATUS"].Array; test.status != nil && !slices.Equal(status, test.status) {
+				t.Errorf("expected PIPESTATUS: %v, got: %v", test.status, status)
+			}
+		})
+	}
+}
+
+func TestParseNegate(t *testing.T) {
+	var commands, err = ParseCommands("! a | b; a && ! b; a | ! b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !commands[0].Negate || commands[0].Pipe.Negate {
+		t.Error("expected negated pipeline:", commands[0])
+	}
+
+	if commands[1].Negate || !commands[1].And.Negate {
+		t.Error("expected negated and:", commands[1])
+	}
+
+	// 管道中间的 `!` 为普通单词
+	if commands[2].Pipe.Negate || commands[2].Pipe.Path != "!" {
+		t.Error("unexpected negate:", commands[2])
+	}
+}
+
+func TestPipelineExternal(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+
+	var sh, stdout = newTestGosh()
+	sh.Builtin["yes"], sh.Builtin["head"] = yes, head
+
+	// 外部命令的错误输出由 exec 的协程写入，与标准输出并发写入同一个 bytes.Buffer
+	sh.Option.Stderr = io.Discard
+
+	// 外部命令两侧使用系统管道，外部命令提前退出后进程内命令同样结束
+	var script = "echo hello | /bin/sh -c 'cat' | cat; yes | /bin/sh -c 'read line; echo $line'; /bin/sh -c 'echo a; echo b' | head"
+	if _, err := sh.Run(strings.NewReader(script), sh.Option); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "hello\ny\na\n"; stdout.String() != expected {
+		t.Errorf("expected: %q, got: %q", expected, stdout.String())
+	}
+}
+
+func TestPipeKind(t *testing.T) {
+	var sh, _ = newTestGosh()
+
+	var tests = []struct {
+		script   string
+		expected bool
+	}{
+		{script: "echo a | cat", expected: true},
+		{script: "x=1 cat | read x", expected: true},
+		{script: "echo a | /bin/cat", expected: false},
+		{script: "$cmd a | cat", expected: false},
+	}
+
+	for _, test := range tests {
+		var commands, err = ParseCommands(test.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var r, w, _ = sh.pipe(&commands[0], commands[0].Pipe)
+		if _, ok := r.(*memPipeReader); ok != test.expected {
+			t.Errorf("%s: expected in-process pipe: %v", test.script, test.expected)
+		}
+
+		_, _ = r.Close(), w.Close()
+	}
+}
+
+// benchmarkPipe 通过管道传输数据的吞吐量
+func benchmarkPipe(b *testing.B, newPipe func() (io.ReadCloser, io.WriteCloser)) {
+	var chunk = make([]byte, 32*1024)
+
+	b.SetBytes(int64(len(chunk)))
+	b.ResetTimer()
+
+	var r, w = newPipe()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			_, _ = w.Write(chunk)
+		}
+		_ = w.Close()
+	}()
+
+	_, _ = io.CopyBuffer(io.Discard, r, make([]byte, len(chunk)))
+	_ = r.Close()
+}
+
+func BenchmarkMemPipe(b *testing.B) {
+	benchmarkPipe(b, func() (io.ReadCloser, io.WriteCloser) {
+		return newMemPipe()
+	})
+}
+
+func BenchmarkOSPipe(b *testing.B) {
+	benchmarkPipe(b, func() (io.ReadCloser, io.WriteCloser) {
+		var r, w, err = os.Pipe()
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r, w
+	})
+}
+
+// BenchmarkPipeline 进程内命令组成的管道
+func BenchmarkPipeline(b *testing.B) {
+	var sh, _ = newTestGosh()
+	sh.Option.Stdout = io.Discard
+
+	var file = filepath.Join(b.TempDir(), "data")
+	if err := os.WriteFile(file, make([]byte, 1024*1024), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	var commands, err = ParseCommands("cat " + file + " | cat | cat")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(1024 * 1024)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err = sh.Exec(&commands[0], sh.Option); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const readUsage = `read: read [-rs] [-a array] [-d delim] [-n nchars] [-p prompt] [-t timeout] [-u fd] [name ...]
+    Read a line from the standard input and split it into fields.
+
+    Reads a single line from the standard input.  The line is split into
+    fields as with word splitting, and the first word is assigned to the
+    first NAME, the second word to the second NAME, and so on, with any
+    leftover words assigned to the last NAME.  Only the characters found
+    in $IFS are recognized as word delimiters.  If no NAMEs are supplied,
+    the line read is stored in the REPLY variable.
+
+    Options:
+      -a array	assign the words read to sequential indices of the array
+    		variable ARRAY, starting at zero
+      -d delim	continue until the first character of DELIM is read, rather
+    		than newline
+      -n nchars	return after reading NCHARS characters rather than waiting
+    		for a newline, but honor a delimiter if fewer than
+    		NCHARS characters are read before the delimiter
+      -p prompt	output the string PROMPT without a trailing newline before
+    		attempting to read, if the input comes from a terminal
+      -r	do not allow backslashes to escape any characters
+      -s	do not echo input coming from a terminal
+      -t timeout	time out and return failure if a complete line of
+    		input is not read within TIMEOUT seconds
+      -u fd	read from file descriptor FD instead of the standard input
+
+    Exit Status:
+    The return code is zero, unless end-of-file is encountered, read times
+    out (in which case it's greater than 128), or an invalid option is
+    supplied.
+`
+
+// readTimeoutCode read 超时的退出码（128 + SIGALRM）
+const readTimeoutCode = 142
+
+// readOption read 的选项
+type readOption struct {
+	array   string
+	delim   byte
+	nchars  int
+	prompt  string
+	raw     bool
+	silent  bool
+	timeout time.Duration
+	timed   bool
+	fd      int
+}
+
+// readInput 读取的内容，escaped 标记被反斜杠转义的字节
+type readInput struct {
+	line    []byte
+	escaped []bool
+}
+
+// readOneByte 每次只读取一个字节，避免多读与后续命令共享的输入
+func readOneByte(r io.Reader) (c byte, err error) {
+	var buf [1]byte
+	for {
+		var n int
+		if n, err = r.Read(buf[:]); n == 1 {
+			return buf[0], nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readChar 读取一个完整的 UTF-8 字符
+func readChar(r io.Reader) (char []byte, err error) {
+	var c byte
+	if c, err = readOneByte(r); err != nil {
+		return
+	}
+
+	char = append(char, c)
+	for c >= utf8.RuneSelf && !utf8.FullRune(char) && len(char) < utf8.UTFMax {
+		if c, err = readOneByte(r); err != nil {
+			return char, nil
+		}
+		char = append(char, c)
+	}
+
+	return
+}
+
+// read 读取直到分隔符或读满指定字符数
+func (in *readInput) read(r io.Reader, opt readOption) (err error) {
+	for count := 0; opt.nchars < 0 || count < opt.nchars; count++ {
+		var char []byte
+		if char, err = readChar(r); err != nil {
+			return
+		}
+
+		if len(char) == 1 && char[0] == opt.delim {
+			return
+		}
+
+		// 反斜杠转义下一个字符，反斜杠换行为续行
+		if !opt.raw && len(char) == 1 && char[0] == '\\' {
+			if char, err = readChar(r); err != nil {
+				return
+			}
+
+			if len(char) == 1 && char[0] == '\n' {
+				count--
+				continue
+			}
+
+			for _, c := range char {
+				in.line = append(in.line, c)
+				in.escaped = append(in.escaped, true)
+			}
+			continue
+		}
+
+		for _, c := range char {
+			in.line = append(in.line, c)
+			in.escaped = append(in.escaped, false)
+		}
+	}
+
+	return
+}
+
+// split 按 IFS 将读取的内容分割为字段，n 大于 0 时最后一个字段保留剩余内容
+func (in *readInput) split(ifs string, n int) (fields []string) {
+	var (
+		line  = in.line
+		isSep = func(i int) bool {
+			return !in.escaped[i] && strings.IndexByte(ifs, line[i]) >= 0
+		}
+		isSpace = func(i int) bool {
+			return isSep(i) && isIFSSpace(line[i])
+		}
+		field = func(i int) int {
+			for i < len(line) && !isSep(i) {
+				i++
+			}
+			return i
+		}
+		delim = func(i int) int {
+			for i < len(line) && isSpace(i) {
+				i++
+			}
+			if i < len(line) && isSep(i) {
+				for i++; i < len(line) && isSpace(i); i++ {
+				}
+			}
+			return i
+		}
+		i int
+	)
+
+	for i < len(line) && isSpace(i) {
+		i++
+	}
+
+	for i < len(line) {
+		var end = field(i)
+
+		// 最后一个变量获取剩余内容，剩余内容仅有一个字段时去除结尾的分隔符
+		if n > 0 && len(fields) == n-1 && delim(end) < len(line) {
+			end = len(line)
+			for end > i && isSpace(end-1) {
+				end--
+			}
+		}
+
+		fields = append(fields, string(line[i:end]))
+		i = delim(end)
+	}
+
+	return
+}
+
+// parseRead 解析 read 的参数
+func parseRead(args []string) (opt readOption, names []string, err error) {
+	opt.delim, opt.nchars = '\n', -1
+
+	names, err = parseOptions(args[1:], "a:d:n:p:rst:u:", func(option byte, value string) (err error) {
+		switch option {
+		case 'a':
+			opt.array = value
+		case 'd':
+			if opt.delim = 0; value != "" {
+				opt.delim = value[0]
+			}
+		case 'n':
+			if opt.nchars, err = strconv.Atoi(value); err != nil || opt.nchars < 0 {
+				return fmt.Errorf("%s: invalid number", value)
+			}
+		case 'p':
+			opt.prompt = value
+		case 'r':
+			opt.raw = true
+		case 's':
+			opt.silent = true
+		case 't':
+			var seconds float64
+			if seconds, err = strconv.ParseFloat(value, 64); err != nil || seconds < 0 {
+				return fmt.Errorf("%s: invalid timeout specification", value)
+			}
+			opt.timeout, opt.timed = time.Duration(seconds*float64(time.Second)), true
+		case 'u':
+			if opt.fd, err = strconv.Atoi(value); err != nil || opt.fd < 0 {
+				return fmt.Errorf("%s: invalid file descriptor specification", value)
+			}
+		}
+		return
+	})
+
+	return
+}
+
+// read 从标准输入读取一行并按 IFS 分割赋值给变量
+func (sh *Gosh) read(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && args[1] == "--help" {
+		_, _ = fmt.Fprint(opt.Stdout, readUsage)
+		return
+	}
+
+	var option, names, err = parseRead(args)
+	if err != nil {
+		writeError(opt, fmt.Errorf("read: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, readUsage)
+		return 2
+	}
+
+	for _, name := range append(names, option.array) {
+		if name != "" && !isName(name) {
+			writeError(opt, fmt.Errorf("read: `%s': not a valid identifier", name))
+			return 1
+		}
+	}
+
+	// -u 从 exec 或 coproc 打开的文件描述符读取
+	if option.fd != 0 {
+		var stream, _ = getFd(&opt, sh.files, option.fd)
+		var r, ok = stream.(io.Reader)
+		if !ok {
+			writeError(opt, fmt.Errorf("read: %d: invalid file descriptor", option.fd))
+			return 1
+		}
+		opt.Stdin = r
+	}
+
+	// -t 0 仅检查是否有可读的输入
+	if option.timed && option.timeout == 0 {
+		if sh.readReady(opt.Stdin) {
+			return 0
+		}
+		return 1
+	}
+
+	var (
+		fd       = terminalFd(opt.Stdin)
+		terminal = fd >= 0
+	)
+
+	if option.prompt != "" && terminal {
+		_, _ = fmt.Fprint(opt.Stderr, option.prompt)
+	}
+
+	if option.silent && terminal {
+		var restore func()
+		if restore, err = setEcho(fd, false); err == nil {
+			defer restore()
+		}
+	}
+
+	var in readInput
+	if err = sh.readTimeout(&in, opt.Stdin, option); err != nil {
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			code = readTimeoutCode
+		case errors.Is(err, io.EOF):
+			code = 1
+		default:
+			writeError(opt, fmt.Errorf("read: %w", err))
+			return 1
+		}
+
+		// 未读取到任何内容时不修改变量
+		if len(in.line) == 0 {
+			return
+		}
+	}
+
+	var ifs = sh.ifs()
+
+	switch {
+	case option.array != "":
+		err = sh.setArray(option.array, in.split(ifs, 0))
+	case len(names) == 0:
+		err = sh.setvar("REPLY", string(in.line))
+	default:
+		var fields = in.split(ifs, len(names))
+		for i, name := range names {
+			var value string
+			if i < len(fields) {
+				value = fields[i]
+			}
+
+			if err = sh.setvar(name, value); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		writeError(opt, fmt.Errorf("read: %w", err))
+		return 1
+	}
+
+	return
+}
+
+// fileFd 获取文件描述符，不同于 File.Fd 不会将文件设置为阻塞模式
+func fileFd(file *os.File) (fd int, ok bool) {
+	if file == nil {
+		return
+	}
+
+	var conn, err = file.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	if err = conn.Control(func(f uintptr) { fd = int(f) }); err != nil {
+		return
+	}
+
+	return fd, true
+}
+
+// terminalFd 输入为终端时返回其文件描述符，否则返回 -1
+func terminalFd(r io.Reader) int {
+	var file, _ = r.(*os.File)
+	if fd, ok := fileFd(file); ok && isTerminal(fd) {
+		return fd
+	}
+
+	return -1
+}
+
+// pollReader 每次读取前等待文件可读，到截止时间后不再读取
+type pollReader struct {
+	file     *os.File
+	fd       int
+	deadline time.Time
+}
+
+func (p *pollReader) Read(b []byte) (n int, err error) {
+	var ready bool
+	if ready, err = waitRead(p.fd, time.Until(p.deadline)); err != nil {
+		return
+	}
+
+	if !ready {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	return p.file.Read(b)
+}
+
+// lateByte 后台读取的一个字节
+type lateByte struct {
+	c   byte
+	err error
+}
+
+// lateReader 无法取消读取的输入，后台每次只读取一个字节，
+// 超时或中断后仍在进行的读取结果保留给下一次从该输入读取的 read
+type lateReader struct {
+	r         io.Reader
+	result    chan lateByte
+	pending   bool
+	deadline  time.Time
+	interrupt <-chan struct{} // 收到通知时中断读取
+}
+
+func (l *lateReader) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+
+	if !l.pending {
+		l.pending = true
+		go func() {
+			var c, err = readOneByte(l.r)
+			l.result <- lateByte{c: c, err: err}
+		}()
+	}
+
+	var timeout <-chan time.Time
+	if !l.deadline.IsZero() {
+		var timer = time.NewTimer(time.Until(l.deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case result := <-l.result:
+		if l.pending = false; result.err != nil {
+			return 0, result.err
+		}
+		b[0] = result.c
+		return 1, nil
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	case <-l.interrupt:
+		return 0, errInterrupted
+	}
+}
+
+// pollFd 可以等待可读的文件描述符
+func pollFd(r io.Reader) (file *os.File, fd int, ok bool) {
+	if file, ok = r.(*os.File); !ok {
+		return
+	}
+
+	if fd, ok = fileFd(file); !ok {
+		return
+	}
+
+	var _, err = waitRead(fd, 0)
+
+	return file, fd, err == nil
+}
+
+// takeLate 取出输入上次超时后仍在进行的读取
+func (sh *Gosh) takeLate(r io.Reader) (late *lateReader) {
+	if r == nil || !reflect.TypeOf(r).Comparable() {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if late = sh.late[r]; late != nil {
+		delete(sh.late, r)
+	}
+
+	return
+}
+
+// readReady 输入是否有可以立即读取的内容，用于 read -t 0
+func (sh *Gosh) readReady(r io.Reader) bool {
+	if late := sh.takeLate(r); late != nil {
+		defer sh.keepLate(r, late)
+		return len(late.result) > 0
+	}
+
+	if _, fd, ok := pollFd(r); ok {
+		var ready, err = waitRead(fd, 0)
+		return err == nil && ready
+	}
+
+	if r, ok := r.(interface{ Len() int }); ok {
+		return r.Len() > 0
+	}
+
+	return false
+}
+
+// keepLate 保留仍在进行的读取
+func (sh *Gosh) keepLate(r io.Reader, late *lateReader) {
+	if !late.pending || !reflect.TypeOf(r).Comparable() {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if sh.late == nil {
+		sh.late = make(map[io.Reader]*lateReader)
+	}
+	sh.late[r] = late
+}
+
+// readTimeout 带超时读取，超时后不再从输入读取，已读取的内容保留在 in 中
+func (sh *Gosh) readTimeout(in *readInput, r io.Reader, opt readOption) (err error) {
+	// 先读取上次超时后读到的内容
+	var late = sh.takeLate(r)
+	if late == nil && !opt.timed {
+		return in.read(r, opt)
+	}
+
+	var deadline time.Time
+	if opt.timed {
+		deadline = time.Now().Add(opt.timeout)
+	}
+
+	if late == nil {
+		if file, fd, ok := pollFd(r); ok {
+			return in.read(&pollReader{file: file, fd: fd, deadline: deadline}, opt)
+		}
+
+		type readDeadline interface {
+			SetReadDeadline(t time.Time) error
+		}
+
+		if d, ok := r.(readDeadline); ok && d.SetReadDeadline(deadline) == nil {
+			defer func() { _ = d.SetReadDeadline(time.Time{}) }()
+			return in.read(r, opt)
+		}
+
+		// 内存中的输入不会阻塞
+		if _, ok := r.(interface{ Len() int }); ok {
+			return in.read(r, opt)
+		}
+
+		late = &lateReader{r: r, result: make(chan lateByte, 1)}
+	}
+
+	late.deadline = deadline
+	defer sh.keepLate(r, late)
+
+	return in.read(late, opt)
+}
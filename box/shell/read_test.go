@@ -0,0 +1,300 @@
+package shell
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zooyer/gobox/types"
+)
+
+func TestRead(t *testing.T) {
+	var tests = []struct {
+		input    string
+		args     []string
+		ifs      string
+		code     int
+		expected map[string]string
+	}{
+		{
+			// 默认保存到 REPLY，保留首尾空白
+			input:    "  hello world  \n",
+			args:     []string{"read"},
+			expected: map[string]string{"REPLY": "  hello world  "},
+		},
+		{
+			// 剩余内容赋值给最后一个变量
+			input:    "  x   y  z  \n",
+			args:     []string{"read", "a", "b"},
+			expected: map[string]string{"a": "x", "b": "y  z"},
+		},
+		{
+			// 变量多于字段
+			input:    "x\n",
+			args:     []string{"read", "a", "b"},
+			expected: map[string]string{"a": "x", "b": ""},
+		},
+		{
+			// 非空白分隔符，最后一个字段去除结尾分隔符
+			input:    "1:2:\n",
+			args:     []string{"read", "a", "b"},
+			ifs:      ":",
+			expected: map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			// 非空白分隔符，剩余多个字段时保留结尾分隔符
+			input:    "1:2:3:\n",
+			args:     []string{"read", "a", "b"},
+			ifs:      ":",
+			expected: map[string]string{"a": "1", "b": "2:3:"},
+		},
+		{
+			// 反斜杠转义
+			input:    `a\ b c\d` + "\n",
+			args:     []string{"read", "x", "y"},
+			expected: map[string]string{"x": "a b", "y": "cd"},
+		},
+		{
+			// -r 保留反斜杠
+			input:    `a\ b c\d` + "\n",
+			args:     []string{"read", "-r", "x", "y"},
+			expected: map[string]string{"x": `a\`, "y": `b c\d`},
+		},
+		{
+			// 反斜杠换行续行
+			input:    "a\\\nb\n",
+			args:     []string{"read", "x"},
+			expected: map[string]string{"x": "ab"},
+		},
+		{
+			// 自定义分隔符
+			input:    "a b,c",
+			args:     []string{"read", "-d", ",", "x"},
+			expected: map[string]string{"x": "a b"},
+		},
+		{
+			// 读取指定字符数
+			input:    "你好世界\n",
+			args:     []string{"read", "-n", "2", "x"},
+			expected: map[string]string{"x": "你好"},
+		},
+		{
+			// 遇到文件结束，仍然赋值
+			input:    "a b",
+			args:     []string{"read", "x"},
+			code:     1,
+			expected: map[string]string{"x": "a b"},
+		},
+		{
+			// 非法变量名
+			input: "a\n",
+			args:  []string{"read", "1x"},
+			code:  1,
+		},
+		{
+			// 非法选项
+			input: "a\n",
+			args:  []string{"read", "-z"},
+			code:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			var sh, _ = newTestGosh()
+			if test.ifs != "" {
+				_ = sh.setvar("IFS", test.ifs)
+			}
+
+			var opt = sh.Option
+			opt.Stdin = strings.NewReader(test.input)
+
+			if code := sh.read(opt, test.args); code != test.code {
+				t.Fatalf("expected code: %d, got: %d", test.code, code)
+			}
+
+			for name, value := range test.expected {
+				if v := sh.getenv(name); v != value {
+					t.Errorf("%s: expected: %q, got: %q", name, value, v)
+				}
+			}
+		})
+	}
+}
+
+func TestReadArray(t *testing.T) {
+	var sh, _ = newTestGosh()
+
+	var opt = sh.Option
+	opt.Stdin = strings.NewReader(" a b  c \n")
+
+	if code := sh.read(opt, []string{"read", "-a", "list"}); code != 0 {
+		t.Fatal("read -a failed:", code)
+	}
+
+	if v := sh.vars["list"]; v == nil || !reflect.DeepEqual(v.Array, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected array: %v", v)
+	}
+}
+
+func TestReadShared(t *testing.T) {
+	// 连续读取同一输入时不多读
+	var sh, stdout = newTestGosh()
+
+	sh.Option.Stdin = strings.NewReader("first line\nsecond line\n")
+	if code, err := sh.Run(strings.NewReader("read a\nread b\necho \"$b/$a\"\n"), sh.Option); err != nil || code != 0 {
+		t.Fatal("run failed:", code, err)
+	}
+
+	if stdout.String() != "second line/first line\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestReadScript(t *testing.T) {
+	// 从管道读取脚本时 read 读取脚本中之后的行
+	var r, w, err = os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	go func() {
+		_, _ = w.WriteString("read x\nhello\necho got $x\nread a b\nx y\necho \"$b$a\"\n")
+		_ = w.Close()
+	}()
+
+	var stdout strings.Builder
+	var sh = NewGosh(types.Option{Env: os.Environ(), Stdin: r, Stdout: &stdout, Stderr: &stdout})
+
+	if code := sh.Main([]string{"gosh"}); code != 0 {
+		t.Fatal("main failed:", code, stdout.String())
+	}
+
+	if stdout.String() != "got hello\nyx\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	var sh, _ = newTestGosh()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	defer func() { _ = w.Close() }()
+
+	_, _ = w.WriteString("part")
+
+	var opt = sh.Option
+	opt.Stdin = r
+
+	var start = time.Now()
+	if code := sh.read(opt, []string{"read", "-t", "0.1", "x"}); code != readTimeoutCode {
+		t.Fatal("expected timeout, got:", code)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("timeout too long:", elapsed)
+	}
+
+	if v := sh.getenv("x"); v != "part" {
+		t.Fatalf("unexpected value: %q", v)
+	}
+}
+
+func TestReadFd(t *testing.T) {
+	var sh, stdout = newTestGosh()
+
+	var path = t.TempDir() + "/input"
+	if err := os.WriteFile(path, []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// -u 从 exec 打开的文件描述符读取，不影响标准输入
+	sh.Option.Stdin = strings.NewReader("stdin\n")
+	var script = "exec 5<" + path + "\nread -u 5 a\nread b\nread -u 5 c\necho $a $b $c\nread -u 9 x; echo $?\nread -u x y; echo $?\n"
+	if _, err := sh.Run(strings.NewReader(script), sh.Option); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = "first stdin second\nshell: read: 9: invalid file descriptor\n1\nshell: read: x: invalid file descriptor specification\n"
+	if !strings.HasPrefix(stdout.String(), expected) {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestReadTimeoutPartial(t *testing.T) {
+	// 无法取消读取的输入超时后保留已读取的内容，之后到达的输入留给下一次 read
+	var sh, _ = newTestGosh()
+
+	var r, w = io.Pipe()
+	defer func() { _ = w.Close() }()
+
+	go func() { _, _ = w.Write([]byte("part")) }()
+
+	var opt = sh.Option
+	opt.Stdin = r
+
+	if code := sh.read(opt, []string{"read", "-t", "0.1", "x"}); code != readTimeoutCode {
+		t.Fatal("expected timeout, got:", code)
+	}
+
+	if v := sh.getenv("x"); v != "part" {
+		t.Fatalf("unexpected value: %q", v)
+	}
+
+	if code := sh.read(opt, []string{"read", "-t", "0", "x"}); code != 1 {
+		t.Fatal("expected no input, got:", code)
+	}
+
+	go func() { _, _ = w.Write([]byte("rest\n")) }()
+
+	if code := sh.read(opt, []string{"read", "-t", "1", "y"}); code != 0 {
+		t.Fatal("read failed:", code)
+	}
+
+	if v := sh.getenv("y"); v != "rest" {
+		t.Fatalf("unexpected value: %q", v)
+	}
+}
+
+func TestReadPoll(t *testing.T) {
+	var sh, _ = newTestGosh()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	defer func() { _ = w.Close() }()
+
+	var opt = sh.Option
+	opt.Stdin = r
+
+	// -t 0 等待文件可读
+	if code := sh.read(opt, []string{"read", "-t", "0"}); code != 1 {
+		t.Fatal("expected no input, got:", code)
+	}
+
+	_, _ = w.WriteString("a\nb\n")
+
+	if code := sh.read(opt, []string{"read", "-t", "0"}); code != 0 {
+		t.Fatal("expected input, got:", code)
+	}
+
+	// 超时的读取之后不再读取输入
+	if code := sh.read(opt, []string{"read", "-t", "1", "x"}); code != 0 || sh.getenv("x") != "a" {
+		t.Fatal("read failed:", code, sh.getenv("x"))
+	}
+
+	var rest = make([]byte, 2)
+	if n, _ := r.Read(rest); string(rest[:n]) != "b\n" {
+		t.Fatalf("unexpected rest: %q", rest[:n])
+	}
+}
@@ -0,0 +1,256 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+// closedFd 已关闭的文件描述符，读写均返回错误
+type closedFd struct{}
+
+func (closedFd) Read([]byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+func (closedFd) Write([]byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+// getFd 获取文件描述符对应的输入输出
+func getFd(option *types.Option, files map[int]*os.File, fd int) (stream any, exists bool) {
+	switch fd {
+	case 0:
+		return option.Stdin, option.Stdin != nil
+	case 1:
+		return option.Stdout, option.Stdout != nil
+	case 2:
+		return option.Stderr, option.Stderr != nil
+	}
+
+	var file *os.File
+	if file, exists = files[fd]; !exists {
+		return
+	}
+
+	return file, true
+}
+
+// setFd 设置文件描述符对应的输入输出，stream 为 nil 时关闭该描述符
+func setFd(option *types.Option, files map[int]*os.File, fd int, stream any) (err error) {
+	if stream == nil {
+		switch fd {
+		case 0:
+			option.Stdin = closedFd{}
+		case 1:
+			option.Stdout = closedFd{}
+		case 2:
+			option.Stderr = closedFd{}
+		default:
+			delete(files, fd)
+		}
+
+		return
+	}
+
+	var ok bool
+
+	switch fd {
+	case 0:
+		option.Stdin, ok = stream.(io.Reader)
+	case 1:
+		option.Stdout, ok = stream.(io.Writer)
+	case 2:
+		option.Stderr, ok = stream.(io.Writer)
+	default:
+		files[fd], ok = stream.(*os.File)
+	}
+
+	if !ok {
+		return fmt.Errorf("%d: bad file descriptor", fd)
+	}
+
+	return
+}
+
+// redirectFd 应用单个带文件描述符的重定向
+func redirectFd(r Redirect, option *types.Option, files map[int]*os.File) (opened *os.File, err error) {
+	var flag int
+
+	switch r.Op {
+	case "<":
+		flag = os.O_RDONLY
+	case ">":
+		flag = os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	case ">>":
+		flag = os.O_CREATE | os.O_APPEND | os.O_WRONLY
+	case "<<":
+		return nil, setFd(option, files, r.Fd, strings.NewReader(r.Target))
+	case "<&", ">&":
+		// 关闭文件描述符
+		if r.Target == "-" {
+			return nil, setFd(option, files, r.Fd, nil)
+		}
+
+		var fd int
+		if fd, err = strconv.Atoi(r.Target); err != nil {
+			return nil, fmt.Errorf("%s: ambiguous redirect", r.Target)
+		}
+
+		var (
+			stream any
+			exists bool
+		)
+
+		if stream, exists = getFd(option, files, fd); !exists {
+			return nil, fmt.Errorf("%d: bad file descriptor", fd)
+		}
+
+		return nil, setFd(option, files, r.Fd, stream)
+	default:
+		return nil, fmt.Errorf("unsupported redirect: %d%s", r.Fd, r.Op)
+	}
+
+	if opened, err = os.OpenFile(r.Target, flag, 0644); err != nil {
+		return
+	}
+
+	if err = setFd(option, files, r.Fd, opened); err != nil {
+		_ = opened.Close()
+		return nil, err
+	}
+
+	return
+}
+
+// expandTarget 展开重定向目标，结果必须为单个字段
+func (sh *Gosh) expandTarget(word string) (target string, err error) {
+	if word == "" {
+		return
+	}
+
+	var list []string
+	if list, err = sh.expand(word); err != nil {
+		return
+	}
+
+	if len(list) != 1 {
+		return "", fmt.Errorf("%s: ambiguous redirect", unmark(word))
+	}
+
+	return list[0], nil
+}
+
+// expandRedirects 展开重定向目标，按应用的顺序返回命令的全部重定向，文档输入的目标为文档内容
+func (sh *Gosh) expandRedirects(command *Command) (redirects []Redirect, err error) {
+	var files = []Redirect{
+		{Fd: 0, Op: "<", Target: command.Input},
+		{Fd: 1, Op: ">", Target: command.Output},
+		{Fd: 1, Op: ">>", Target: command.Append},
+	}
+
+	for _, r := range files {
+		if r.Target, err = sh.expandTarget(r.Target); err != nil {
+			return
+		}
+
+		if r.Target != "" {
+			redirects = append(redirects, r)
+		}
+
+		// 文档输入在文件输入之后
+		if r.Op == "<" && command.Heredoc != "" {
+			redirects = append(redirects, Redirect{Fd: 0, Op: "<<", Target: command.Heredoc})
+		}
+	}
+
+	for _, r := range command.Redirects {
+		if r.Target, err = sh.expandTarget(r.Target); err != nil {
+			return
+		}
+		redirects = append(redirects, r)
+	}
+
+	return
+}
+
+// redirect 按顺序应用展开后的重定向，返回打开的文件
+func (sh *Gosh) redirect(redirects []Redirect, option *types.Option, files map[int]*os.File) (opened []*os.File, err error) {
+	defer func() {
+		if err != nil {
+			for _, file := range opened {
+				_ = file.Close()
+			}
+			opened = nil
+		}
+	}()
+
+	for _, r := range redirects {
+		var file *os.File
+		if file, err = redirectFd(r, option, files); err != nil {
+			return
+		}
+
+		if file != nil {
+			opened = append(opened, file)
+		}
+	}
+
+	return
+}
+
+// extraFiles 转换为外部命令继承的文件描述符，第 i 个为描述符 3+i
+func extraFiles(files map[int]*os.File) (extra []*os.File) {
+	for fd, file := range files {
+		if fd < 3 {
+			continue
+		}
+
+		for len(extra) <= fd-3 {
+			extra = append(extra, nil)
+		}
+
+		extra[fd-3] = file
+	}
+
+	return
+}
+
+// referenced 文件是否仍被 shell 的文件描述符引用
+func (sh *Gosh) referenced(file *os.File) bool {
+	for _, frame := range append(slices.Clip(sh.frames), sh.Option) {
+		if frame.Stdin == io.Reader(file) || frame.Stdout == io.Writer(file) || frame.Stderr == io.Writer(file) {
+			return true
+		}
+	}
+
+	for _, f := range sh.files {
+		if f == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// persist 将 exec 的重定向保存为 shell 的文件描述符，并关闭不再引用的文件
+func (sh *Gosh) persist(option types.Option, files map[int]*os.File, opened []*os.File) {
+	sh.Option.Stdin, sh.Option.Stdout, sh.Option.Stderr = option.Stdin, option.Stdout, option.Stderr
+	sh.files = files
+
+	var all = append(sh.opened, opened...)
+
+	sh.opened = nil
+	for _, file := range all {
+		if sh.referenced(file) {
+			sh.opened = append(sh.opened, file)
+		} else {
+			_ = file.Close()
+		}
+	}
+}
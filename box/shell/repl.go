@@ -0,0 +1,146 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+// ps2 输出续行提示符，默认为 `> `
+func (sh *Gosh) ps2(option types.Option) {
+	var prompt, set = sh.lookup("PS2")
+	if !set {
+		prompt = "> "
+	}
+
+	_, _ = fmt.Fprint(option.Stdout, prompt)
+}
+
+// readLine 读取一行，包含结尾的换行符，不多读之后的输入
+func readLine(r io.Reader) (line string, err error) {
+	var sb strings.Builder
+	for {
+		var c byte
+		if c, err = readOneByte(r); err != nil {
+			return sb.String(), err
+		}
+
+		if sb.WriteByte(c); c == '\n' {
+			return sb.String(), nil
+		}
+	}
+}
+
+// incomplete 输入是否不完整：以反斜杠换行结尾，或仅有输入意外结束的语法错误
+func incomplete(text string) bool {
+	if body, ok := strings.CutSuffix(text, "\n"); ok {
+		var escapes = len(body) - len(strings.TrimRight(body, "\\"))
+		if escapes%2 == 1 {
+			return true
+		}
+	}
+
+	var _, err = Parse(strings.NewReader(text))
+
+	var errs = syntaxErrors(err)
+	if len(errs) == 0 {
+		return false
+	}
+
+	for _, e := range errs {
+		if !isIncomplete(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// errInterrupted 读取命令时收到 SIGINT，丢弃已读取的内容
+var errInterrupted = errors.New("interrupted")
+
+// readCommand 读取完整的命令，输入不完整时输出续行提示符继续读取，
+// 读取时收到信号则执行 trap，收到 SIGINT 时丢弃已读取的内容并返回 errInterrupted
+func (sh *Gosh) readCommand(r io.Reader) (text string, err error) {
+	var late = sh.takeLate(r)
+	if late == nil {
+		late = &lateReader{r: r, result: make(chan lateByte, 1)}
+	}
+
+	late.interrupt = sh.signaled
+	defer func() { late.interrupt = nil; sh.keepLate(r, late) }()
+
+	var line string
+	for {
+		line, err = readLine(late)
+		text += line
+
+		if errors.Is(err, errInterrupted) {
+			if sh.interrupted() || sh.exited {
+				return "", errInterrupted
+			}
+			continue
+		}
+
+		if err != nil || !incomplete(text) {
+			return
+		}
+
+		sh.ps2(sh.Option)
+	}
+}
+
+// interrupted 执行等待中的信号的 trap，返回是否收到 SIGINT
+func (sh *Gosh) interrupted() (interrupt bool) {
+	sh.mutex.RLock()
+	for _, signal := range sh.pending {
+		if name, _ := signalOf(signal); name == "INT" {
+			interrupt = true
+		}
+	}
+	sh.mutex.RUnlock()
+
+	sh.trapped()
+
+	return
+}
+
+// repl 交互式读取并执行命令，每次执行一条完整的命令
+func (sh *Gosh) repl(stdin io.Reader) (code int, err error) {
+	for {
+		sh.ps1(sh.Option)
+
+		var text string
+		switch text, err = sh.readCommand(stdin); {
+		case errors.Is(err, errInterrupted):
+			if sh.exited {
+				return sh.status, nil
+			}
+
+			// 丢弃未完成的命令，重新输出主提示符
+			_, _ = fmt.Fprintln(sh.Option.Stdout)
+			continue
+		case err != nil && !errors.Is(err, io.EOF):
+			return 1, err
+		}
+
+		var eof = err != nil
+
+		if text != "" {
+			if _, err = sh.run(strings.NewReader(text)); err != nil {
+				writeError(sh.Option, err)
+			}
+
+			if sh.exited {
+				return sh.status, nil
+			}
+		}
+
+		if eof {
+			return sh.status, nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package shell
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// restrictedVars 受限模式下不能修改的变量
+var restrictedVars = []string{"SHELL", "PATH", "ENV"}
+
+// restrictedBuiltins 受限模式下不能执行的内置命令
+var restrictedBuiltins = []string{"cd", "pushd", "popd", "exec"}
+
+// isRestricted 是否以受限模式启动：指定 -r、--restricted 或以 rgosh 的名称运行
+func isRestricted(name string, opt Option) bool {
+	return opt.GNUOption.Restricted || opt.RunOption.Restricted || filepath.Base(strings.TrimPrefix(name, "-")) == "rgosh"
+}
+
+// restrictVar 受限模式下检查变量是否可以修改
+func (sh *Gosh) restrictVar(name string) (err error) {
+	if sh.restricted && slices.Contains(restrictedVars, name) {
+		return fmt.Errorf("%s: restricted: cannot modify", name)
+	}
+
+	return
+}
+
+// restrict 受限模式下检查命令是否允许执行
+func (sh *Gosh) restrict(command *Command, assigns, argv []string) (err error) {
+	if !sh.restricted {
+		return
+	}
+
+	for _, assign := range assigns {
+		var name, _, _ = strings.Cut(assign, "=")
+		if err = sh.restrictVar(assignBase(name)); err != nil {
+			return
+		}
+	}
+
+	// 输出重定向
+	for _, target := range []string{command.Output, command.Append} {
+		if target != "" {
+			return fmt.Errorf("%s: restricted: cannot redirect output", unmark(target))
+		}
+	}
+
+	for _, r := range command.Redirects {
+		if r.Op == ">" || r.Op == ">>" {
+			return fmt.Errorf("%s: restricted: cannot redirect output", unmark(r.Target))
+		}
+
+		// 复制到文件描述符不受限制
+		if _, e := strconv.Atoi(r.Target); r.Op == ">&" && e != nil && r.Target != "-" {
+			return fmt.Errorf("%s: restricted: cannot redirect output", unmark(r.Target))
+		}
+	}
+
+	if len(argv) == 0 {
+		return
+	}
+
+	var name = argv[0]
+
+	switch {
+	case strings.ContainsRune(name, '/'):
+		return fmt.Errorf("%s: restricted: cannot specify `/' in command names", name)
+	case slices.Contains(restrictedBuiltins, name) && sh.Builtin[name] != nil:
+		return fmt.Errorf("%s: restricted", name)
+	case (name == "hash" || name == "command") && hasOption(argv[1:], 'p') && sh.Builtin[name] != nil:
+		return fmt.Errorf("%s: -p: restricted", name)
+	case (name == "source" || name == ".") && len(argv) > 1 && strings.ContainsRune(argv[1], '/'):
+		return fmt.Errorf("%s: %s: restricted", name, argv[1])
+	case sh.Builtin[name] == nil && sh.Command[name] != nil && sh.Allow != nil && !slices.Contains(sh.Allow, name):
+		return fmt.Errorf("%s: restricted: command not allowed", name)
+	}
+
+	return
+}
+
+// hasOption 内置命令的参数中是否有短选项 c
+func hasOption(args []string, c byte) bool {
+	for _, arg := range args {
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		if strings.IndexByte(arg[1:], c) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
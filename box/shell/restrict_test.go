@@ -0,0 +1,67 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestricted(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		output = filepath.Join(dir, "out")
+	)
+
+	var tests = []struct {
+		script   string
+		allow    []string
+		expected string
+	}{
+		{script: `cd /; echo $?`, expected: "shell: cd: restricted\n1\n"},
+		{script: `PATH=/tmp; echo $?`, expected: "shell: PATH: restricted: cannot modify\n1\n"},
+		{script: `SHELL=/bin/sh true; echo $?`, expected: "shell: SHELL: restricted: cannot modify\n1\n"},
+		{script: "read ENV <<EOF\nx\nEOF\necho $?", expected: "shell: read: ENV: restricted: cannot modify\n1\n"},
+		{script: `/bin/echo hi`, expected: "shell: /bin/echo: restricted: cannot specify `/' in command names\n"},
+		{script: `echo hi > ` + output, expected: "shell: " + output + ": restricted: cannot redirect output\n"},
+		{script: `echo hi 2>> ` + output, expected: "shell: " + output + ": restricted: cannot redirect output\n"},
+		{script: `echo hi 2>&1`, expected: "hi\n"},
+		{script: `exec echo hi; echo $?`, expected: "shell: exec: restricted\n1\n"},
+		{script: `set +r; echo $?`, expected: "shell: set: +r: restricted\n1\n"},
+		{script: `source /etc/profile`, expected: "shell: source: /etc/profile: restricted\n"},
+		{script: `gosh -c 'cd /'`, expected: "shell: cd: restricted\n"},
+		{script: `cat /dev/null; echo ok`, allow: []string{"echo"}, expected: "shell: cat: restricted: command not allowed\nok\n"},
+		{script: `command cat /dev/null; command pushd /`, allow: []string{"echo"}, expected: "shell: cat: restricted: command not allowed\nshell: pushd: restricted\n"},
+		{script: `PATH[1]=/tmp; echo $?; unset PATH; declare -x SHELL; echo $?`, expected: "shell: PATH: restricted: cannot modify\n1\nshell: unset: PATH: restricted: cannot modify\nshell: declare: SHELL: restricted: cannot modify\n1\n"},
+		{script: `hash -p /bin/sh cat; command -p cat /dev/null`, expected: "shell: hash: -p: restricted\nshell: command: -p: restricted\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			sh.Allow = test.allow
+
+			_ = sh.Main([]string{"gosh", "-r", "-c", test.script})
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+
+			if _, err := os.Stat(output); err == nil {
+				t.Fatal("output redirected in restricted mode")
+			}
+		})
+	}
+}
+
+func TestSetRestricted(t *testing.T) {
+	var sh, stdout = newTestGosh()
+
+	if _, err := sh.Run(strings.NewReader("set -r a b; echo $#; cd /"), sh.Option); err != nil {
+		t.Fatal("run failed:", err)
+	}
+
+	if expected := "2\nshell: cd: restricted\n"; stdout.String() != expected {
+		t.Errorf("expected: %q, got: %q", expected, stdout.String())
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package shell
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// cpuMetrics 运行时估算的 CPU 时间，运行时自身的开销计入内核态
+var cpuMetrics = []metrics.Sample{
+	{Name: "/cpu/classes/user:cpu-seconds"},
+	{Name: "/cpu/classes/gc/total:cpu-seconds"},
+	{Name: "/cpu/classes/scavenge/total:cpu-seconds"},
+}
+
+// selfTimes 当前进程使用的用户态和内核态 CPU 时间，无法获取 rusage 时使用运行时的统计
+func selfTimes() (user, sys time.Duration) {
+	var samples = append([]metrics.Sample(nil), cpuMetrics...)
+	metrics.Read(samples)
+
+	var seconds = func(sample metrics.Sample) float64 {
+		if sample.Value.Kind() != metrics.KindFloat64 {
+			return 0
+		}
+		return sample.Value.Float64()
+	}
+
+	user = time.Duration(seconds(samples[0]) * float64(time.Second))
+	sys = time.Duration((seconds(samples[1]) + seconds(samples[2])) * float64(time.Second))
+
+	return
+}
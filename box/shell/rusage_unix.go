@@ -0,0 +1,18 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shell
+
+import (
+	"syscall"
+	"time"
+)
+
+// selfTimes 当前进程使用的用户态和内核态 CPU 时间，用于统计进程内执行的命令
+func selfTimes() (user, sys time.Duration) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return
+	}
+
+	return time.Duration(usage.Utime.Nano()), time.Duration(usage.Stime.Nano())
+}
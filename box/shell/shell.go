@@ -14,6 +14,7 @@ type GNUOption struct {
 	Debugger    bool   `json:"debugger"`
 	DumpPo      bool   `json:"dump-po-strings"`
 	DumpStrings bool   `json:"dump-strings"`
+	DumpAST     bool   `json:"dump-ast"`
 	Help        bool   `json:"help"`
 	InitFile    string `json:"init-file"`
 	Login       bool   `json:"login"`
@@ -36,7 +37,7 @@ type RunOption struct {
 	Verbose     bool `json:"v"`
 	NoClobber   bool `json:"C"`
 	Debug       bool `json:"D"`
-	NoEditing   bool `json:"n"`
+	NoExec      bool `json:"n"`
 }
 
 type ConfigOption struct {
@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/zooyer/gobox/types"
+)
+
+// openPty 打开伪终端，返回主设备和从设备
+func openPty() (master, slave *os.File, err error) {
+	if master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0); err != nil {
+		return
+	}
+
+	var unlock, number int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		_ = master.Close()
+		return nil, nil, errno
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); errno != 0 {
+		_ = master.Close()
+		return nil, nil, errno
+	}
+
+	if slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0); err != nil {
+		_ = master.Close()
+	}
+
+	return
+}
+
+func TestTerminalForeground(t *testing.T) {
+	// 子进程中以终端为控制终端运行交互式 shell
+	if os.Getenv("GOSH_TEST_TTY") != "" {
+		var sh = NewGosh(types.Option{Env: os.Environ(), Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr})
+		os.Exit(sh.Main([]string{"gosh"}))
+	}
+
+	if _, err := os.Stat("/bin/sleep"); err != nil {
+		t.Skip("no /bin/sleep")
+	}
+
+	var master, slave, err = openPty()
+	if err != nil {
+		t.Skip("no pty:", err)
+	}
+	defer func() { _ = master.Close() }()
+
+	var cmd = exec.Command(os.Args[0], "-test.run=^TestTerminalForeground$")
+	cmd.Env = append(os.Environ(), "GOSH_TEST_TTY=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_ = slave.Close()
+
+	var output = make(chan string, 1)
+	go func() {
+		var (
+			buf [1024]byte
+			out bytes.Buffer
+		)
+		for {
+			var n, err = master.Read(buf[:])
+			if out.Write(buf[:n]); err != nil {
+				output <- out.String()
+				return
+			}
+		}
+	}()
+
+	// 外部命令结束后 shell 重新成为前台进程组，可以继续读取终端
+	_, _ = master.WriteString("/bin/sleep 0.2\n")
+	time.Sleep(500 * time.Millisecond)
+	_, _ = master.WriteString("echo ali\"\"ve\nexit 3\n")
+
+	var done = make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-time.After(10 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("shell not exited")
+	}
+
+	// 从设备全部关闭后读取结束
+	var out string
+	select {
+	case out = <-output:
+	case <-time.After(5 * time.Second):
+	}
+
+	if code := cmd.ProcessState.ExitCode(); code != 3 || !strings.Contains(out, "alive") {
+		t.Fatalf("unexpected exit: %v, output: %q", err, out)
+	}
+}
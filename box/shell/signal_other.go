@@ -0,0 +1,43 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package shell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// signals 支持的信号
+var signals = []signalName{
+	{"INT", syscall.Signal(2)},
+	{"KILL", syscall.Signal(9)},
+}
+
+// Signals 需要转发给 shell 的信号
+var Signals = []os.Signal{
+	os.Interrupt,
+}
+
+func notifyStop(c chan<- os.Signal) (stop func()) {
+	return func() {}
+}
+
+func ignoreTTY() (restore func()) {
+	return func() {}
+}
+
+func setpgid(cmd *exec.Cmd, tty bool) {}
+
+func killpg(process *os.Process, signal os.Signal) error {
+	return process.Signal(signal)
+}
+
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+func setForeground(fd int) error {
+	return errors.ErrUnsupported
+}
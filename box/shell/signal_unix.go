@@ -0,0 +1,103 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// signals 支持的信号
+var signals = []signalName{
+	{"HUP", syscall.SIGHUP},
+	{"INT", syscall.SIGINT},
+	{"QUIT", syscall.SIGQUIT},
+	{"ILL", syscall.SIGILL},
+	{"TRAP", syscall.SIGTRAP},
+	{"ABRT", syscall.SIGABRT},
+	{"BUS", syscall.SIGBUS},
+	{"FPE", syscall.SIGFPE},
+	{"KILL", syscall.SIGKILL},
+	{"USR1", syscall.SIGUSR1},
+	{"SEGV", syscall.SIGSEGV},
+	{"USR2", syscall.SIGUSR2},
+	{"PIPE", syscall.SIGPIPE},
+	{"ALRM", syscall.SIGALRM},
+	{"TERM", syscall.SIGTERM},
+	{"CHLD", syscall.SIGCHLD},
+	{"CONT", syscall.SIGCONT},
+	{"STOP", syscall.SIGSTOP},
+	{"TSTP", syscall.SIGTSTP},
+	{"TTIN", syscall.SIGTTIN},
+	{"TTOU", syscall.SIGTTOU},
+	{"URG", syscall.SIGURG},
+	{"XCPU", syscall.SIGXCPU},
+	{"XFSZ", syscall.SIGXFSZ},
+	{"VTALRM", syscall.SIGVTALRM},
+	{"PROF", syscall.SIGPROF},
+	{"WINCH", syscall.SIGWINCH},
+	{"IO", syscall.SIGIO},
+	{"SYS", syscall.SIGSYS},
+}
+
+// Signals 需要转发给 shell 的信号，不包括 TSTP，非交互式 shell 收到 TSTP 时按默认方式暂停，
+// 交互式 shell 通过 notifyStop 订阅
+var Signals = []os.Signal{
+	syscall.SIGHUP,
+	syscall.SIGINT,
+	syscall.SIGQUIT,
+	syscall.SIGTERM,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// notifyStop 订阅进程收到的 SIGTSTP，交互式 shell 不因终端的暂停键暂停，返回取消订阅的函数
+func notifyStop(c chan<- os.Signal) (stop func()) {
+	signal.Notify(c, syscall.SIGTSTP)
+	return func() { signal.Stop(c) }
+}
+
+// setpgid 外部命令使用独立的进程组，tty 为 true 时将其设为终端的前台进程组
+func setpgid(cmd *exec.Cmd, tty bool) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Foreground: tty}
+}
+
+// killpg 向外部命令的进程组发送信号
+func killpg(process *os.Process, signal os.Signal) error {
+	var sig, ok = signal.(syscall.Signal)
+	if !ok {
+		return process.Signal(signal)
+	}
+
+	return syscall.Kill(-process.Pid, sig)
+}
+
+// exitCode 外部命令的退出码，被信号终止时为 128 加信号值
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
+
+// ignoreTTY 交互式 shell 忽略 SIGTTOU 和 SIGTTIN，不在前台时设置终端的前台进程组和读取终端不会被暂停，
+// 返回恢复默认处理的函数
+func ignoreTTY() (restore func()) {
+	signal.Ignore(syscall.SIGTTOU, syscall.SIGTTIN)
+	return func() { signal.Reset(syscall.SIGTTOU, syscall.SIGTTIN) }
+}
+
+// setForeground 将 shell 的进程组设为终端的前台进程组
+func setForeground(fd int) (err error) {
+	var pgrp = int32(syscall.Getpgrp())
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	if errno != 0 {
+		return errno
+	}
+
+	return
+}
@@ -0,0 +1,111 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+// 启动文件
+var (
+	systemProfile = "/etc/profile"  // 登录 shell 的系统配置
+	userProfile   = ".gosh_profile" // 登录 shell 的用户配置，位于家目录
+	posixProfile  = ".profile"      // POSIX 模式下登录 shell 的用户配置，位于家目录
+	userRC        = ".goshrc"       // 交互式非登录 shell 的用户配置，位于家目录
+)
+
+// isLogin 是否为登录 shell：指定 -l、--login 或 $0 以 - 开头
+func isLogin(name string, opt Option) bool {
+	return opt.GNUOption.Login || opt.RunOption.Login || strings.HasPrefix(name, "-")
+}
+
+// home 用户的家目录
+func (sh *Gosh) home() string {
+	if home := sh.getenv("HOME"); home != "" {
+		return home
+	}
+
+	var home, _ = os.UserHomeDir()
+
+	return home
+}
+
+// startup 按 bash 的顺序执行启动文件
+func (sh *Gosh) startup(login bool, opt Option) {
+	var home = sh.home()
+
+	switch {
+	case login:
+		// 登录 shell 读取系统和用户的 profile
+		if opt.GNUOption.NoProfile || opt.ConfigOption.NoProfile {
+			return
+		}
+
+		sh.startupFile(systemProfile)
+
+		if sh.posix {
+			sh.startupFile(filepath.Join(home, posixProfile))
+		} else {
+			sh.startupFile(filepath.Join(home, userProfile))
+		}
+	case !sh.interactive:
+	case sh.posix:
+		// POSIX 模式下交互式 shell 读取 $ENV 指定的文件
+		if env, err := sh.expandString(sh.getenv("ENV")); err == nil && env != "" {
+			sh.startupFile(env)
+		}
+	case !opt.NoRC:
+		// 交互式非登录 shell 读取 rc 文件
+		var rc = filepath.Join(home, userRC)
+
+		switch {
+		case opt.RCFile != "":
+			rc = opt.RCFile
+		case opt.InitFile != "":
+			rc = opt.InitFile
+		}
+
+		sh.startupFile(rc)
+	}
+}
+
+// startupFile 执行启动文件，文件不存在时忽略，执行出错时报告位置并继续
+func (sh *Gosh) startupFile(path string) {
+	if sh.exited {
+		return
+	}
+
+	var file, err = os.Open(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			writeError(sh.Option, err)
+		}
+		return
+	}
+
+	defer func() { _ = file.Close() }()
+
+	var script, line = sh.script, sh.line
+	sh.script = path
+
+	defer func() { sh.script, sh.line = script, line }()
+
+	if _, err = sh.run(file); err != nil {
+		sh.writeError(sh.Option, err)
+	}
+}
+
+// writeError 输出错误，执行启动文件时带上文件名和行号
+func (sh *Gosh) writeError(opt types.Option, err error) {
+	if sh.script == "" {
+		writeError(opt, err)
+		return
+	}
+
+	_, _ = fmt.Fprintf(opt.Stderr, "%s: line %d: %v\n", sh.script, sh.line, err)
+}
@@ -0,0 +1,136 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartup(t *testing.T) {
+	var dir = t.TempDir()
+
+	var files = map[string]string{
+		"profile":       "echo profile\n",
+		".gosh_profile": "echo gosh_profile\n",
+		".profile":      "echo posix_profile\n",
+		".goshrc":       "echo goshrc\nnosuchcommand\necho after\n",
+		"custom":        "echo custom\n",
+		"env":           "echo env\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var saved = systemProfile
+	systemProfile = filepath.Join(dir, "profile")
+	defer func() { systemProfile = saved }()
+
+	var tests = []struct {
+		name     string
+		args     []string
+		expected []string
+		excluded []string
+	}{
+		{
+			name:     "login",
+			args:     []string{"gosh", "-l", "-c", "echo main"},
+			expected: []string{"profile\ngosh_profile\nmain\n"},
+			excluded: []string{"goshrc"},
+		},
+		{
+			name:     "login name",
+			args:     []string{"-gosh", "-c", "echo main"},
+			expected: []string{"profile\ngosh_profile\nmain\n"},
+		},
+		{
+			name:     "noprofile",
+			args:     []string{"gosh", "--login", "--noprofile", "-c", "echo main"},
+			expected: []string{"main\n"},
+			excluded: []string{"profile"},
+		},
+		{
+			name:     "posix login",
+			args:     []string{"gosh", "--posix", "-l", "-c", "echo main"},
+			expected: []string{"profile\nposix_profile\nmain\n"},
+		},
+		{
+			name:     "non-interactive",
+			args:     []string{"gosh", "-c", "echo main"},
+			expected: []string{"main\n"},
+			excluded: []string{"goshrc", "profile"},
+		},
+		{
+			// rc 文件中的错误报告位置，不影响之后的命令
+			name: "rc",
+			args: []string{"gosh", "-i", "-c", "echo main"},
+			expected: []string{
+				"goshrc\n" + filepath.Join(dir, ".goshrc") + ": line 2: nosuchcommand: command not found\nafter\n",
+				"main\n",
+			},
+		},
+		{
+			name:     "rcfile",
+			args:     []string{"gosh", "-i", "--rcfile", filepath.Join(dir, "custom"), "-c", "echo main"},
+			expected: []string{"custom\n", "main\n"},
+			excluded: []string{"goshrc"},
+		},
+		{
+			name:     "norc",
+			args:     []string{"gosh", "-i", "--norc", "-c", "echo main"},
+			expected: []string{"main\n"},
+			excluded: []string{"goshrc"},
+		},
+		{
+			name:     "env",
+			args:     []string{"gosh", "-i", "--posix", "-c", "echo main"},
+			expected: []string{"env\n", "main\n"},
+			excluded: []string{"goshrc"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			_ = sh.setvar("HOME", dir)
+			_ = sh.setvar("ENV", "$HOME/env")
+
+			if code := sh.Main(test.args); code != 0 {
+				t.Fatalf("unexpected code: %d, output: %q", code, stdout.String())
+			}
+
+			for _, expected := range test.expected {
+				if !strings.Contains(stdout.String(), expected) {
+					t.Errorf("expected: %q, got: %q", expected, stdout.String())
+				}
+			}
+
+			for _, excluded := range test.excluded {
+				if strings.Contains(stdout.String(), excluded) {
+					t.Errorf("unexpected: %q, got: %q", excluded, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func TestStartupExit(t *testing.T) {
+	var dir = t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "rc"), []byte("exit 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sh, stdout = newTestGosh()
+
+	if code := sh.Main([]string{"gosh", "-i", "--rcfile", filepath.Join(dir, "rc"), "-c", "echo main"}); code != 3 {
+		t.Fatal("expected code 3, got:", code)
+	}
+
+	if strings.Contains(stdout.String(), "main") {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
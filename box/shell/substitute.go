@@ -0,0 +1,151 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+// substitution 进程替换，内部命令在子 shell 中与外部命令同时执行，
+// shell 一侧持有管道的一端，通过 /dev/fd/N 传递给命令
+type substitution struct {
+	file   *os.File      // shell 一侧的管道
+	output bool          // 是否为 >(...)，写入管道的数据作为内部命令的输入
+	done   chan struct{} // 内部命令结束
+}
+
+// hasSubstitution 单词中是否有进程替换
+func hasSubstitution(word string) bool {
+	return strings.Contains(word, "$<(") || strings.Contains(word, "$>(")
+}
+
+// startSubstitution 在子 shell 中启动进程替换的内部命令
+func (sh *Gosh) startSubstitution(script string, output bool, option types.Option) (s *substitution, err error) {
+	var r, w *os.File
+	if r, w, err = os.Pipe(); err != nil {
+		return
+	}
+
+	var (
+		inner = option
+		end   *os.File // 内部命令一侧的管道
+	)
+
+	s = &substitution{output: output, done: make(chan struct{})}
+	if output {
+		s.file, end, inner.Stdin = w, r, r
+	} else {
+		s.file, end, inner.Stdout = r, w, w
+	}
+
+	var child = sh.subshell(inner)
+	go func() {
+		defer close(s.done)
+		_, _ = child.Run(strings.NewReader(script), inner)
+		_ = end.Close()
+	}()
+
+	return
+}
+
+// substitute 启动单词中的进程替换，替换为转义的 /dev/fd/N，管道加入 files 由外部命令继承
+func (sh *Gosh) substitute(word string, option types.Option, files map[int]*os.File, substs *[]*substitution) (_ string, err error) {
+	var sb strings.Builder
+	for i := 0; i < len(word); i++ {
+		var c = word[i]
+
+		switch {
+		case c == markEscape && i+1 < len(word):
+			sb.WriteString(word[i : i+2])
+			i++
+			continue
+		case c != '$' || i+2 >= len(word) || word[i+2] != '(' || (word[i+1] != '<' && word[i+1] != '>'):
+			sb.WriteByte(c)
+			continue
+		case i > 0 && word[i-1] == markQuoted:
+			// 双引号内的 `$<(` 为字面量
+			sb.WriteByte(c)
+			continue
+		}
+
+		// 命令中的字符均已转义，第一个未转义的 `)` 为结束
+		var (
+			script strings.Builder
+			j      = i + 3
+		)
+
+		for ; j < len(word) && word[j] != ')'; j++ {
+			if word[j] == markEscape {
+				j++
+			}
+			if j < len(word) {
+				script.WriteByte(word[j])
+			}
+		}
+
+		var s *substitution
+		if s, err = sh.startSubstitution(script.String(), word[i+1] == '>', option); err != nil {
+			return
+		}
+
+		*substs = append(*substs, s)
+		files[int(s.file.Fd())] = s.file
+
+		for _, b := range []byte(fmt.Sprintf("/dev/fd/%d", s.file.Fd())) {
+			sb.WriteByte(markEscape)
+			sb.WriteByte(b)
+		}
+
+		i = j
+	}
+
+	return sb.String(), nil
+}
+
+// substituteCommand 启动命令参数和重定向目标中的进程替换，返回替换后的命令副本
+func (sh *Gosh) substituteCommand(command *Command, option types.Option, files map[int]*os.File, substs *[]*substitution) (_ *Command, err error) {
+	var words = append(command.CmdArgs(), command.Input, command.Output, command.Append)
+	for _, r := range command.Redirects {
+		words = append(words, r.Target)
+	}
+
+	if !slices.ContainsFunc(words, hasSubstitution) {
+		return command, nil
+	}
+
+	var copied = *command
+	copied.Args = slices.Clone(command.Args)
+	copied.Redirects = slices.Clone(command.Redirects)
+
+	var targets = []*string{&copied.Path, &copied.Input, &copied.Output, &copied.Append}
+	for i := range copied.Args {
+		targets = append(targets, &copied.Args[i])
+	}
+	for i := range copied.Redirects {
+		targets = append(targets, &copied.Redirects[i].Target)
+	}
+
+	for _, target := range targets {
+		if *target, err = sh.substitute(*target, option, files, substs); err != nil {
+			return
+		}
+	}
+
+	return &copied, nil
+}
+
+// closeSubstitutions 命令结束后关闭 shell 一侧的管道，并等待 >(...) 的内部命令读取完成
+func closeSubstitutions(substs []*substitution) {
+	for _, s := range substs {
+		_ = s.file.Close()
+	}
+
+	for _, s := range substs {
+		if s.output {
+			<-s.done
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package shell
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncWriter 串行写入，进程替换的内部命令与外部命令的输出同时写入同一个 bytes.Buffer
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (w *syncWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.w.Write(b)
+}
+
+func TestProcessSubstitution(t *testing.T) {
+	if _, err := os.Stat("/dev/fd"); err != nil {
+		t.Skip("no /dev/fd")
+	}
+
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+
+	var tests = []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{name: "input", script: "cat <(echo hello) <(echo world)", expected: "hello\nworld\n"},
+		{name: "redirect", script: "cat < <(echo in); echo $?", expected: "in\n0\n"},
+		{name: "output", script: "echo hi > >(cat); echo done", expected: "hi\ndone\n"},
+		{name: "subshell", script: "x=1; cat <(x=2; echo $x); echo $x", expected: "2\n1\n"},
+		{name: "nested", script: `cat <(echo '(a)' ")" \))`, expected: "(a) ) )\n"},
+		{name: "quoted", script: `echo "<(x)" '$<(y)' \<\(z\)`, expected: "<(x) $<(y) <(z)\n"},
+		{name: "external", script: `/bin/sh -c 'cat "$1" "$2"' sh <(echo a) <(echo b); /bin/sh -c 'echo x > "$1"' sh >(cat)`, expected: "a\nb\nx\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			sh.Option.Stdout = &syncWriter{w: stdout}
+			sh.Option.Stderr = sh.Option.Stdout
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestParseSubstitution(t *testing.T) {
+	if _, err := ParseCommands("cat <(echo"); err == nil || !strings.Contains(err.Error(), "matching `)'") {
+		t.Errorf("expected unexpected EOF, got: %v", err)
+	}
+
+	var commands, err = ParseCommands("diff <(sort a) >(cat)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commands[0].Args) != 2 || !hasSubstitution(commands[0].Args[0]) || commands[0].Input != "" {
+		t.Errorf("unexpected command: %+v", commands[0])
+	}
+}
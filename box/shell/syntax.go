@@ -0,0 +1,283 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Pos 源码中的位置，行和列从 1 开始，列按字符计算
+type Pos struct {
+	Offset int `json:"offset"` // 字节偏移
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// SyntaxError 语法错误，Err 为 io.ErrUnexpectedEOF 时表示输入不完整
+type SyntaxError struct {
+	Pos    Pos
+	Msg    string
+	Err    error
+	Source string // 出错的行，不为空时输出并标记出错的列
+}
+
+func (e *SyntaxError) Error() string {
+	var msg = fmt.Sprintf("syntax error at line %d, column %d: %s", e.Pos.Line, e.Pos.Col, e.Msg)
+	if e.Source == "" {
+		return msg
+	}
+
+	// 标记出错的列，制表符保持对齐，宽字符占两列
+	var caret strings.Builder
+	for i, r := range []rune(e.Source) {
+		if i >= e.Pos.Col-1 {
+			break
+		}
+
+		if r == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+	caret.WriteByte('^')
+
+	return msg + "\n" + e.Source + "\n" + caret.String()
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// syntaxErrors 展开合并的错误，得到其中的每个语法错误
+func syntaxErrors(err error) (errs []error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, syntaxErrors(e)...)
+		}
+		return
+	}
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return
+}
+
+// isIncomplete 是否因输入意外结束而出错
+func isIncomplete(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// pushback 放回输入的内容，读完后执行 done
+type pushback struct {
+	text string
+	done func()
+}
+
+// source 词法分析的输入，记录读取的位置和内容，放回的内容不计入位置
+type source struct {
+	input  io.Reader // 原始输入
+	reader *bufio.Reader
+	pushed []pushback // 放回的内容，栈顶在末尾
+	pos    Pos        // 下一个读取的字符的位置
+
+	pending int // 多字节字符剩余的字节数
+
+	mutex   sync.Mutex
+	lines   []string        // 已读取的行
+	current strings.Builder // 正在读取的行
+}
+
+func newSource(reader io.Reader) *source {
+	return &source{
+		input:  reader,
+		reader: bufio.NewReader(reader),
+		pos:    Pos{Line: 1, Col: 1},
+	}
+}
+
+// lineReader 每次只读取一个字节，开始读取新的一行之前调用 wait，不多读与执行的命令共享的输入
+type lineReader struct {
+	reader io.Reader
+	wait   func() error
+	start  bool // 下一个字节位于新的一行
+}
+
+func (r *lineReader) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+
+	if r.start && r.wait != nil {
+		if err = r.wait(); err != nil {
+			return
+		}
+	}
+
+	var c byte
+	if c, err = readOneByte(r.reader); err != nil {
+		return
+	}
+
+	b[0], r.start = c, c == '\n'
+
+	return 1, nil
+}
+
+// unbuffered 改为每次只从原始输入读取一个字节，需要在读取之前调用
+func (s *source) unbuffered() (lines *lineReader) {
+	lines = &lineReader{reader: s.input}
+	s.reader = bufio.NewReaderSize(lines, 16)
+
+	return
+}
+
+// push 放回内容，读完之后再次读取时执行 done
+func (s *source) push(text string, done func()) {
+	s.pushed = append(s.pushed, pushback{text: text, done: done})
+}
+
+// pop 移除已读完的放回内容
+func (s *source) pop() {
+	for len(s.pushed) > 0 && s.pushed[len(s.pushed)-1].text == "" {
+		var top = s.pushed[len(s.pushed)-1]
+		if s.pushed = s.pushed[:len(s.pushed)-1]; top.done != nil {
+			top.done()
+		}
+	}
+}
+
+// advance 读取字符后更新位置
+func (s *source) advance(c byte) {
+	s.pos.Offset++
+
+	s.mutex.Lock()
+	if c == '\n' {
+		s.lines = append(s.lines, strings.TrimSuffix(s.current.String(), "\r"))
+		s.current.Reset()
+	} else {
+		s.current.WriteByte(c)
+	}
+	s.mutex.Unlock()
+
+	switch {
+	case c == '\n':
+		s.pos.Line++
+		s.pos.Col = 1
+	case c&0xC0 != 0x80:
+		// UTF-8 的后续字节不计入列
+		s.pos.Col++
+	}
+}
+
+// line 获取第 n 行已读取的内容
+func (s *source) line(n int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case n >= 1 && n <= len(s.lines):
+		return s.lines[n-1]
+	case n == len(s.lines)+1:
+		return s.current.String()
+	}
+
+	return ""
+}
+
+func (s *source) ReadByte() (c byte, err error) {
+	if s.pop(); len(s.pushed) > 0 {
+		var top = &s.pushed[len(s.pushed)-1]
+		c, top.text = top.text[0], top.text[1:]
+		return
+	}
+
+	if c, err = s.reader.ReadByte(); err != nil {
+		return
+	}
+
+	if err = s.validate(c); err != nil {
+		return
+	}
+
+	s.advance(c)
+
+	return
+}
+
+// validate 检查读取的字节是否为合法的 UTF-8 编码
+func (s *source) validate(c byte) (err error) {
+	if c < utf8.RuneSelf {
+		return
+	}
+
+	// 多字节字符的后续字节已在首字节时检查
+	if s.pending > 0 {
+		s.pending--
+		return
+	}
+
+	var peek, _ = s.reader.Peek(utf8.UTFMax - 1)
+	var r, n = utf8.DecodeRune(append([]byte{c}, peek...))
+	if r == utf8.RuneError && n <= 1 {
+		return &SyntaxError{Pos: s.pos, Msg: "invalid UTF-8 encoding"}
+	}
+
+	s.pending = n - 1
+
+	return
+}
+
+// Peek 查看之后的 n 个字节，不会触发放回内容读完的处理
+func (s *source) Peek(n int) (peek []byte, err error) {
+	for i := len(s.pushed) - 1; i >= 0 && len(peek) < n; i-- {
+		var text = s.pushed[i].text
+		peek = append(peek, text[:min(len(text), n-len(peek))]...)
+	}
+
+	if len(peek) < n {
+		var rest []byte
+		rest, err = s.reader.Peek(n - len(peek))
+		peek = append(peek, rest...)
+	}
+
+	return
+}
+
+func (s *source) Discard(n int) (discarded int, err error) {
+	for ; discarded < n; discarded++ {
+		if _, err = s.ReadByte(); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (s *source) ReadBytes(delim byte) (line []byte, err error) {
+	for {
+		var c byte
+		if c, err = s.ReadByte(); err != nil {
+			return
+		}
+
+		if line = append(line, c); c == delim {
+			return
+		}
+	}
+}
+
+// unexpectedEOF 输入意外结束的语法错误
+func unexpectedEOF(pos Pos, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...), Err: io.ErrUnexpectedEOF}
+}
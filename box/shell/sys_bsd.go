@@ -0,0 +1,56 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shell
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup2(oldfd, newfd)
+}
+
+// fdSet 在 FdSet 中加入文件描述符，各系统 FdSet 的字段名和元素类型不同
+func fdSet(set *syscall.FdSet, fd int) bool {
+	var (
+		words = reflect.ValueOf(set).Elem().Field(0)
+		bits  = int(words.Type().Elem().Size()) * 8
+	)
+
+	if fd < 0 || fd >= words.Len()*bits {
+		return false
+	}
+
+	var word, mask = words.Index(fd / bits), uint64(1) << (fd % bits)
+	if word.CanInt() {
+		word.SetInt(word.Int() | int64(mask))
+	} else {
+		word.SetUint(word.Uint() | mask)
+	}
+
+	return true
+}
+
+// waitRead 等待文件描述符可读，超时返回 false
+func waitRead(fd int, timeout time.Duration) (ready bool, err error) {
+	var tv = syscall.NsecToTimeval(max(timeout, 0).Nanoseconds())
+	for {
+		var set syscall.FdSet
+		if !fdSet(&set, fd) {
+			return false, errors.ErrUnsupported
+		}
+
+		// select 返回后只保留可读的描述符
+		if err = syscall.Select(fd+1, &set, nil, nil, &tv); !errors.Is(err, syscall.EINTR) {
+			return err == nil && set != syscall.FdSet{}, err
+		}
+	}
+}
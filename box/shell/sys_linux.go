@@ -0,0 +1,40 @@
+package shell
+
+import (
+	"errors"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
+
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
+
+// waitRead 等待文件描述符可读，超时返回 false
+func waitRead(fd int, timeout time.Duration) (ready bool, err error) {
+	var (
+		set  syscall.FdSet
+		bits = int(unsafe.Sizeof(set.Bits[0])) * 8
+	)
+
+	if fd < 0 || fd >= len(set.Bits)*bits {
+		return false, errors.ErrUnsupported
+	}
+
+	var tv = syscall.NsecToTimeval(max(timeout, 0).Nanoseconds())
+	for {
+		set = syscall.FdSet{}
+		set.Bits[fd/bits] |= 1 << (fd % bits)
+
+		var n int
+		if n, err = syscall.Select(fd+1, &set, nil, nil, &tv); !errors.Is(err, syscall.EINTR) {
+			return n > 0, err
+		}
+	}
+}
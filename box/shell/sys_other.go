@@ -0,0 +1,32 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package shell
+
+import (
+	"errors"
+	"time"
+)
+
+func dup2(oldfd, newfd int) error {
+	return errors.ErrUnsupported
+}
+
+func dupAbove(fd, least int) (int, error) {
+	return -1, errors.ErrUnsupported
+}
+
+func clearCloexec(fd int) error {
+	return errors.ErrUnsupported
+}
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func setEcho(fd int, echo bool) (restore func(), err error) {
+	return nil, errors.ErrUnsupported
+}
+
+func waitRead(fd int, timeout time.Duration) (ready bool, err error) {
+	return false, errors.ErrUnsupported
+}
@@ -0,0 +1,25 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shell
+
+import "syscall"
+
+// dupAbove 复制文件描述符到不小于 least 的描述符，新描述符在 exec 时关闭
+func dupAbove(fd, least int) (int, error) {
+	var nfd, _, errno = syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_DUPFD_CLOEXEC, uintptr(least))
+	if errno != 0 {
+		return -1, errno
+	}
+
+	return int(nfd), nil
+}
+
+// clearCloexec 清除文件描述符的 FD_CLOEXEC 标志，exec 后保持打开
+func clearCloexec(fd int) error {
+	var _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_SETFD, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shell
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func getTermios(fd int) (termios syscall.Termios, err error) {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlGetTermios, uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		return termios, errno
+	}
+
+	return
+}
+
+func setTermios(fd int, termios syscall.Termios) (err error) {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlSetTermios, uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		return errno
+	}
+
+	return
+}
+
+// isTerminal 文件描述符是否为终端
+func isTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// setEcho 设置终端是否回显输入，返回恢复函数
+func setEcho(fd int, echo bool) (restore func(), err error) {
+	var old, termios syscall.Termios
+	if old, err = getTermios(fd); err != nil {
+		return
+	}
+
+	if termios = old; echo {
+		termios.Lflag |= syscall.ECHO
+	} else {
+		termios.Lflag &^= syscall.ECHO
+	}
+
+	if err = setTermios(fd, termios); err != nil {
+		return
+	}
+
+	return func() { _ = setTermios(fd, old) }, nil
+}
@@ -0,0 +1,149 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const (
+	defaultTimeFormat = "\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS" // 未设置 TIMEFORMAT 时的输出格式
+	posixTimeFormat   = "real %2R\nuser %2U\nsys %2S"         // time -p 的输出格式
+)
+
+// timer time 保留字的计时，外部命令的时间由结束时的 ProcessState 累加，
+// 进程内执行的命令使用当前进程的 CPU 时间之差
+type timer struct {
+	start     time.Time
+	user, sys time.Duration // 开始时当前进程的 CPU 时间
+
+	children struct {
+		user, sys time.Duration // 已结束的外部命令的 CPU 时间
+	}
+}
+
+// startTimer 开始计时，计时期间结束的外部命令的时间计入所有正在计时的管道
+func (sh *Gosh) startTimer() *timer {
+	var t = &timer{start: time.Now()}
+	t.user, t.sys = selfTimes()
+
+	sh.mutex.Lock()
+	sh.timers = append(sh.timers, t)
+	sh.mutex.Unlock()
+
+	return t
+}
+
+// childTime 累加结束的外部命令的 CPU 时间
+func (sh *Gosh) childTime(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	for _, t := range sh.timers {
+		t.children.user += state.UserTime()
+		t.children.sys += state.SystemTime()
+	}
+}
+
+// stopTimer 结束计时，按 TIMEFORMAT 输出到错误输出，posix 为 true 时使用 time -p 的格式
+func (sh *Gosh) stopTimer(t *timer, posix bool, option types.Option) {
+	var wall = time.Since(t.start)
+	var user, sys = selfTimes()
+
+	sh.mutex.Lock()
+	sh.timers = slices.DeleteFunc(sh.timers, func(e *timer) bool { return e == t })
+	user, sys = user-t.user+t.children.user, sys-t.sys+t.children.sys
+	sh.mutex.Unlock()
+
+	var format, set = sh.lookup("TIMEFORMAT")
+	switch {
+	case posix:
+		format = posixTimeFormat
+	case !set:
+		format = defaultTimeFormat
+	case format == "":
+		return
+	}
+
+	_, _ = fmt.Fprintln(option.Stderr, formatTime(format, wall, user, sys))
+}
+
+// formatTime 按 TIMEFORMAT 格式化时间：%[p][l]R、%[p][l]U、%[p][l]S 分别为实际、用户态和内核态时间，
+// p 为小数位数（0 至 3，默认 3），l 为 MMmSS.FFs 的长格式，%P 为 CPU 使用率，%% 为 %
+func formatTime(format string, wall, user, sys time.Duration) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		var (
+			j         = i + 1
+			precision = 3
+			long      bool
+		)
+
+		if c := format[j]; c >= '0' && c <= '9' {
+			precision = min(int(c-'0'), 3)
+			j++
+		}
+
+		if j < len(format) && format[j] == 'l' {
+			long = true
+			j++
+		}
+
+		if j >= len(format) {
+			sb.WriteString(format[i:])
+			break
+		}
+
+		var value time.Duration
+		switch format[j] {
+		case '%':
+			sb.WriteByte('%')
+			i = j
+			continue
+		case 'P':
+			if wall > 0 {
+				_, _ = fmt.Fprintf(&sb, "%.2f", float64(user+sys)*100/float64(wall))
+			} else {
+				sb.WriteString("0.00")
+			}
+			i = j
+			continue
+		case 'R':
+			value = wall
+		case 'U':
+			value = user
+		case 'S':
+			value = sys
+		default:
+			// 未知的格式原样输出
+			sb.WriteString(format[i : j+1])
+			i = j
+			continue
+		}
+
+		var seconds = value.Seconds()
+		if long {
+			var minutes = int(seconds / 60)
+			_, _ = fmt.Fprintf(&sb, "%dm%.*fs", minutes, precision, seconds-float64(minutes*60))
+		} else {
+			_, _ = fmt.Fprintf(&sb, "%.*f", precision, seconds)
+		}
+
+		i = j
+	}
+
+	return sb.String()
+}
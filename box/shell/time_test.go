@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	var (
+		wall = 62*time.Second + 345*time.Millisecond
+		user = 1500 * time.Millisecond
+		sys  = 250 * time.Millisecond
+	)
+
+	var tests = []struct {
+		format   string
+		expected string
+	}{
+		{format: defaultTimeFormat, expected: "\nreal\t1m2.345s\nuser\t0m1.500s\nsys\t0m0.250s"},
+		{format: posixTimeFormat, expected: "real 62.34\nuser 1.50\nsys 0.25"},
+		{format: "%R %0R %9U %lS", expected: "62.345 62 1.500 0m0.250s"},
+		{format: "%P%% %x %", expected: "2.81% %x %"},
+	}
+
+	for _, test := range tests {
+		if got := formatTime(test.format, wall, user, sys); got != test.expected {
+			t.Errorf("%q: expected: %q, got: %q", test.format, test.expected, got)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string // 匹配输出的正则表达式
+	}{
+		{script: "time true", expected: `^\nreal\t0m0\.\d{3}s\nuser\t0m\d\.\d{3}s\nsys\t0m\d\.\d{3}s\n$`},
+		{script: "time -p echo a | cat", expected: `^a\nreal \d\.\d\d\nuser \d\.\d\d\nsys \d\.\d\d\n$`},
+		{script: "TIMEFORMAT='took %1R'; time true 2>/dev/null", expected: `^took 0\.\d\n$`},
+		{script: "TIMEFORMAT=; time true", expected: `^$`},
+		{script: "TIMEFORMAT=%0R; time false; echo $?; time ! true; echo $?", expected: `^0\n1\n0\n1\n$`},
+		{script: "TIMEFORMAT=%0R; true && time false || echo or", expected: `^0\nor\n$`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if !regexp.MustCompile(test.expected).MatchString(stdout.String()) {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	var commands, err = ParseCommands("time -p ! a | b; time a -p; a | time")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := commands[0]; !c.Time || !c.TimePosix || !c.Negate || c.Path != "a" {
+		t.Error("expected timed pipeline:", c)
+	}
+
+	if c := commands[1]; !c.Time || c.TimePosix || len(c.Args) != 1 {
+		t.Error("expected -p as argument:", c)
+	}
+
+	if c := commands[2]; c.Time || c.Pipe.Path != "time" {
+		t.Error("unexpected time:", c)
+	}
+}
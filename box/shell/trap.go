@@ -0,0 +1,404 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const trapUsage = `trap: trap [-lp] [[action] signal_spec ...]
+    Trap signals and other events.
+
+    Defines and activates handlers to be run when the shell receives signals
+    or other conditions.
+
+    ACTION is a command to be read and executed when the shell receives the
+    signal(s) SIGNAL_SPEC.  If ACTION is absent (and a single SIGNAL_SPEC
+    is supplied) or '-', each specified signal is reset to its original
+    value.  If ACTION is the null string each SIGNAL_SPEC is ignored by the
+    shell and by the commands it invokes.
+
+    If a SIGNAL_SPEC is EXIT (0) ACTION is executed on exit from the shell.
+    If a SIGNAL_SPEC is DEBUG, ACTION is executed before every command.
+    If a SIGNAL_SPEC is RETURN, ACTION is executed each time a script run
+    by the . or source builtins finishes executing.  A SIGNAL_SPEC of ERR
+    means to execute ACTION each time a command's failure would cause the
+    shell to exit when the -e option is enabled.
+
+    If no arguments are supplied, trap prints the list of commands
+    associated with each trapped signal in a form that may be reused as
+    shell input to restore the same signal dispositions.
+
+    Options:
+      -l	print a list of signal names and their corresponding numbers
+      -p	display the trap commands associated with each SIGNAL_SPEC in a
+    		form that may be reused as shell input; or for all trapped
+    		signals if no arguments are supplied
+
+    Each SIGNAL_SPEC is either a signal name in <signal.h> or a signal number.
+    Signal names are case insensitive and the SIG prefix is optional.
+
+    Exit Status:
+    Returns success unless a SIGSPEC is invalid or an invalid option is given.
+`
+
+// signalName 信号及其名称（不含 SIG 前缀）
+type signalName struct {
+	name   string
+	signal syscall.Signal
+}
+
+// 非信号的 trap 条件
+const (
+	trapExit   = "EXIT"
+	trapErr    = "ERR"
+	trapDebug  = "DEBUG"
+	trapReturn = "RETURN"
+)
+
+// forwards 转发给前台命令的信号
+var forwards = []string{"INT", "TERM", "QUIT", "TSTP"}
+
+// lookupSignal 根据信号名（大小写不敏感，可省略 SIG 前缀）或信号值查找 trap 条件
+func lookupSignal(spec string) (name string, ok bool) {
+	if number, err := strconv.Atoi(spec); err == nil {
+		if number == 0 {
+			return trapExit, true
+		}
+
+		for _, s := range signals {
+			if int(s.signal) == number {
+				return s.name, true
+			}
+		}
+
+		return
+	}
+
+	name = strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+
+	switch name {
+	case trapExit, trapErr, trapDebug, trapReturn:
+		return name, true
+	}
+
+	for _, s := range signals {
+		if s.name == name {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// signalOf 获取信号对应的名称
+func signalOf(signal os.Signal) (name string, number int) {
+	if s, ok := signal.(syscall.Signal); ok {
+		for _, n := range signals {
+			if n.signal == s {
+				return n.name, int(s)
+			}
+		}
+		return "", int(s)
+	}
+
+	if signal == os.Interrupt {
+		return "INT", 2
+	}
+
+	return "", 0
+}
+
+// trapOrder trap 条件的输出顺序：EXIT、信号、DEBUG、ERR、RETURN
+func trapOrder(name string) int {
+	switch name {
+	case trapExit:
+		return 0
+	case trapDebug:
+		return 1000
+	case trapErr:
+		return 1001
+	case trapReturn:
+		return 1002
+	}
+
+	for _, s := range signals {
+		if s.name == name {
+			return int(s.signal)
+		}
+	}
+
+	return 999
+}
+
+// displayTrap 输出可作为 shell 输入的 trap 命令
+func displayTrap(opt types.Option, name, action string) {
+	if name != trapExit && name != trapErr && name != trapDebug && name != trapReturn {
+		name = "SIG" + name
+	}
+
+	_, _ = fmt.Fprintf(opt.Stdout, "trap -- %s %s\n", quote(action), name)
+}
+
+// listSignals 输出信号名和对应的信号值
+func listSignals(opt types.Option) {
+	var list = slices.SortedFunc(slices.Values(signals), func(a, b signalName) int {
+		return int(a.signal) - int(b.signal)
+	})
+
+	var sb strings.Builder
+	for i, s := range list {
+		fmt.Fprintf(&sb, "%2d) SIG%s", int(s.signal), s.name)
+		if i%5 == 4 || i == len(list)-1 {
+			sb.WriteByte('\n')
+		} else {
+			sb.WriteByte('\t')
+		}
+	}
+
+	_, _ = fmt.Fprint(opt.Stdout, sb.String())
+}
+
+// trap 设置信号和事件的处理命令
+func (sh *Gosh) trap(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, trapUsage)
+		return
+	}
+
+	var list, display bool
+
+	args, err := parseOptions(args[1:], "lp", func(option byte, value string) error {
+		switch option {
+		case 'l':
+			list = true
+		case 'p':
+			display = true
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("trap: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, trapUsage)
+		return 2
+	}
+
+	if list {
+		listSignals(opt)
+		return
+	}
+
+	// 输出 trap 命令
+	if display || len(args) == 0 {
+		sh.mutex.RLock()
+		defer sh.mutex.RUnlock()
+
+		var names = args
+		if len(names) == 0 {
+			for name := range sh.traps {
+				names = append(names, name)
+			}
+			slices.SortFunc(names, func(a, b string) int { return trapOrder(a) - trapOrder(b) })
+		}
+
+		for _, spec := range names {
+			var name, ok = lookupSignal(spec)
+			if !ok {
+				writeError(opt, fmt.Errorf("trap: %s: invalid signal specification", spec))
+				code = 1
+				continue
+			}
+
+			if action, exists := sh.traps[name]; exists {
+				displayTrap(opt, name, action)
+			}
+		}
+
+		return
+	}
+
+	// 只有一个参数或第一个参数为数字时重置为默认处理
+	var (
+		action = args[0]
+		reset  = action == "-"
+	)
+
+	if _, err = strconv.Atoi(action); err == nil || len(args) == 1 {
+		reset = true
+	} else {
+		args = args[1:]
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	for _, spec := range args {
+		var name, ok = lookupSignal(spec)
+		if !ok {
+			writeError(opt, fmt.Errorf("trap: %s: invalid signal specification", spec))
+			code = 1
+			continue
+		}
+
+		if reset {
+			delete(sh.traps, name)
+		} else {
+			sh.traps[name] = action
+		}
+	}
+
+	return
+}
+
+// notify 处理 shell 收到的信号：转发给 track 记录的前台命令并等待在命令之间执行
+func (sh *Gosh) notify(signal os.Signal) {
+	var name, _ = signalOf(signal)
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	// 忽略的信号不转发给命令
+	if action, trapped := sh.traps[name]; trapped && action == "" {
+		return
+	}
+
+	if slices.Contains(forwards, name) {
+		for _, job := range sh.jobs {
+			job(signal)
+		}
+	}
+
+	sh.pending = append(sh.pending, signal)
+
+	select {
+	case sh.signaled <- struct{}{}:
+	default:
+	}
+}
+
+// handleSignals 订阅 shell 进程的信号，交互式 shell 同时订阅 TSTP 并忽略 TTOU 和 TTIN，返回取消订阅的函数
+func (sh *Gosh) handleSignals() (stop func()) {
+	var (
+		c    = make(chan os.Signal, 8)
+		done = make(chan struct{})
+		tstp = func() {}
+	)
+
+	sh.Notify(c, Signals...)
+	if sh.interactive {
+		sh.Notify(c, syscall.SIGTSTP)
+
+		var stop, restore = notifyStop(c), ignoreTTY()
+		tstp = func() { stop(); restore() }
+	}
+
+	go func() {
+		defer close(done)
+		for signal := range c {
+			sh.notify(signal)
+		}
+	}()
+
+	// 取消订阅后不再有信号发送到 c，关闭后等待已收到的信号加入 pending
+	return func() {
+		tstp()
+		sh.StopNotify(c)
+		close(c)
+		<-done
+	}
+}
+
+// track 记录前台运行的命令，用于转发信号，返回取消记录的函数
+func (sh *Gosh) track(signal func(os.Signal)) (untrack func()) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	sh.jobID++
+
+	var id = sh.jobID
+	sh.jobs[id] = signal
+
+	return func() {
+		sh.mutex.Lock()
+		defer sh.mutex.Unlock()
+
+		delete(sh.jobs, id)
+	}
+}
+
+// trapped 处理等待中的信号，未设置 trap 的信号按默认方式处理
+func (sh *Gosh) trapped() {
+	sh.mutex.Lock()
+	var pending = sh.pending
+	sh.pending = nil
+	sh.mutex.Unlock()
+
+	for _, signal := range pending {
+		var name, number = signalOf(signal)
+
+		sh.mutex.RLock()
+		var _, trapped = sh.traps[name]
+		sh.mutex.RUnlock()
+
+		if trapped {
+			sh.runTrap(name)
+			continue
+		}
+
+		// 交互式 shell 忽略 INT、TERM、QUIT 和 TSTP
+		if sh.interactive && slices.Contains(forwards, name) {
+			continue
+		}
+
+		sh.status, sh.exited = 128+number, true
+	}
+}
+
+// runTrap 执行 trap 命令，执行前后保持 $? 不变
+func (sh *Gosh) runTrap(name string) {
+	sh.mutex.RLock()
+	var action, trapped = sh.traps[name]
+	sh.mutex.RUnlock()
+
+	if !trapped || action == "" || sh.intrap {
+		return
+	}
+
+	var status, line = sh.status, sh.line
+
+	sh.intrap = true
+	defer func() { sh.intrap, sh.line = false, line }()
+
+	_, _ = sh.Run(strings.NewReader(action), sh.Option)
+
+	if !sh.exited {
+		sh.status = status
+	}
+}
+
+// exitTrap 执行 EXIT trap，返回 shell 的退出码
+func (sh *Gosh) exitTrap(code int) int {
+	sh.mutex.Lock()
+	var action, trapped = sh.traps[trapExit]
+	delete(sh.traps, trapExit)
+	sh.mutex.Unlock()
+
+	if !trapped || action == "" {
+		return code
+	}
+
+	sh.status, sh.exited = code, false
+
+	_, _ = sh.Run(strings.NewReader(action), sh.Option)
+
+	if sh.exited {
+		return sh.status
+	}
+
+	return code
+}
@@ -0,0 +1,214 @@
+package shell
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zooyer/gobox/types"
+)
+
+func TestTrap(t *testing.T) {
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			script:   `trap 'echo bye' EXIT; trap 'echo x' int; trap -p`,
+			expected: "trap -- 'echo bye' EXIT\ntrap -- 'echo x' SIGINT\n",
+		},
+		{
+			script:   `trap 'echo x' SIGINT 15; trap -p TERM`,
+			expected: "trap -- 'echo x' SIGTERM\n",
+		},
+		{
+			script:   `trap 'echo x' INT QUIT; trap - INT; trap QUIT; trap; echo $?`,
+			expected: "0\n",
+		},
+		{
+			script:   `trap 'echo x' FOO; echo $?`,
+			expected: "shell: trap: FOO: invalid signal specification\n1\n",
+		},
+		{
+			script:   `trap 'echo err $?' ERR; false; true`,
+			expected: "err 1\n",
+		},
+		{
+			script:   `trap 'echo debug' DEBUG; true; trap - DEBUG; true`,
+			expected: "debug\ndebug\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if _, err := sh.Run(strings.NewReader(test.script), sh.Option); err != nil {
+				t.Fatal("run failed:", err)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestTrapList(t *testing.T) {
+	var sh, stdout = newTestGosh()
+
+	if code := sh.trap(sh.Option, []string{"trap", "-l"}); code != 0 {
+		t.Fatal("trap -l failed:", code)
+	}
+
+	if !strings.Contains(stdout.String(), " 2) SIGINT") {
+		t.Errorf("unexpected signal list: %q", stdout.String())
+	}
+}
+
+func TestTrapExit(t *testing.T) {
+	var tests = []struct {
+		script   string
+		code     int
+		expected string
+	}{
+		{script: `trap 'echo bye' EXIT; echo hi`, expected: "hi\nbye\n"},
+		{script: `trap 'echo bye $?' 0; exit 3`, code: 3, expected: "bye 3\n"},
+		{script: `trap 'exit 4' EXIT; true`, code: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+
+			if code := sh.Main([]string{"gosh", "-c", test.script}); code != test.code {
+				t.Fatalf("expected code: %d, got: %d", test.code, code)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestTrapSignal(t *testing.T) {
+	var tests = []struct {
+		name     string
+		script   string
+		code     int
+		expected string
+	}{
+		{
+			// 未设置 trap 时在命令结束后退出，非交互式 shell 不向同一进程组的命令转发信号
+			name:   "default",
+			script: "ready=1; sleep 0.3; echo after",
+			code:   128 + int(syscall.SIGINT),
+		},
+		{
+			// 设置 trap 时在命令结束后执行 trap，命令不会被终止
+			name:     "trap",
+			script:   "trap 'echo caught' INT; ready=1; sleep 0.3; echo after $?",
+			expected: "caught\nafter 0\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			var sh = NewGosh(types.Option{
+				Env:    os.Environ(),
+				Stdin:  strings.NewReader(""),
+				Stdout: &stdout,
+				Stderr: &stdout,
+			})
+
+			var done = make(chan int, 1)
+			go func() { done <- sh.Main([]string{"gosh", "-c", test.script}) }()
+
+			// 等待 trap 设置完成
+			for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+				sh.mutex.RLock()
+				var ready = sh.vars["ready"] != nil
+				sh.mutex.RUnlock()
+
+				if ready {
+					break
+				}
+
+				if time.Since(start) > 5*time.Second {
+					t.Fatal("script not started")
+				}
+			}
+
+			sh.Signal(syscall.SIGINT)
+
+			select {
+			case code := <-done:
+				if code != test.code {
+					t.Fatalf("expected code: %d, got: %d", test.code, code)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("signal not handled")
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+
+			// Main 返回后取消订阅，发送信号不会阻塞
+			for range 16 {
+				sh.Signal(syscall.SIGINT)
+			}
+		})
+	}
+}
+
+func TestTrapPending(t *testing.T) {
+	var tests = []struct {
+		name     string
+		traps    map[string]string
+		code     int
+		expected string
+	}{
+		{
+			name:     "trap",
+			traps:    map[string]string{"USR1": "echo usr1"},
+			expected: "usr1\n",
+		},
+		{
+			name: "default",
+			code: 128 + int(syscall.SIGUSR1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			var sh = NewGosh(types.Option{
+				Env:    os.Environ(),
+				Stdin:  strings.NewReader(""),
+				Stdout: &stdout,
+				Stderr: &stdout,
+			})
+
+			// 没有命令可执行时收到的信号在退出前处理
+			maps.Copy(sh.traps, test.traps)
+			sh.pending = append(sh.pending, syscall.SIGUSR1)
+
+			if code := sh.Main([]string{"gosh", "-c", ""}); code != test.code {
+				t.Fatalf("expected code: %d, got: %d", test.code, code)
+			}
+
+			if stdout.String() != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
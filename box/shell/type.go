@@ -0,0 +1,363 @@
+package shell
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/zooyer/gobox/types"
+)
+
+const typeUsage = `type: type [-afptP] name [name ...]
+    Display information about command type.
+
+    For each NAME, indicate how it would be interpreted if used as a
+    command name.
+
+    Options:
+      -a	display all locations containing an executable named NAME;
+    		includes aliases, builtins and applets, and files
+      -f	suppress shell function lookup
+      -P	force a PATH search for each NAME, even if it is an alias,
+    		builtin, or applet, and returns the name of the disk file
+    		that would be executed
+      -p	returns either the name of the disk file that would be executed,
+    		or nothing if 'type -t NAME' would not return 'file'
+      -t	output a single word which is one of 'alias', 'keyword',
+    		'builtin', 'applet', or 'file', if NAME is an alias, shell
+    		reserved word, shell builtin, gosh applet, or disk file,
+    		respectively
+
+    Arguments:
+      NAME	Command name to be interpreted.
+
+    Exit Status:
+    Returns success if all of the NAMEs are found; fails if any are not found.
+`
+
+const commandUsage = `command: command [-pVv] command [arg ...]
+    Execute a simple command or display information about commands.
+
+    Runs COMMAND with ARGS suppressing alias lookup, or display
+    information about the specified COMMANDs.
+
+    Options:
+      -p	use a default value for PATH that is guaranteed to find all of
+    		the standard utilities
+      -v	print a single word indicating the command or filename that
+    		invokes COMMAND
+      -V	print a more verbose description of each COMMAND
+
+    Exit Status:
+    Returns exit status of COMMAND, or failure if COMMAND is not found.
+`
+
+const whichUsage = `which: which [-a] name [name ...]
+    Locate a command.
+
+    Print the full path of the file that would be executed for each NAME
+    by searching the directories in PATH.
+
+    Options:
+      -a	print all matching executables in PATH, not just the first
+
+    Exit Status:
+    Returns success if all of the NAMEs are found; fails if any are not found.
+`
+
+// defaultPath command -p 使用的 PATH
+const defaultPath = "/usr/bin:/bin:/usr/sbin:/sbin"
+
+// keywords shell 的保留字
+var keywords = []string{"!", "[[", "]]", "case", "coproc", "do", "done", "elif", "else", "esac", "fi", "for", "if", "in", "then", "time", "until", "while"}
+
+// resolution 命令名的解析结果
+type resolution struct {
+	kind   string // alias、keyword、builtin、applet 或 file，与 type -t 的输出相同
+	value  string // 别名的值或文件的路径
+	hashed bool   // 路径是否来自 hash 表
+}
+
+// resolve 按执行时的顺序解析命令名，all 为 true 时返回全部结果，
+// path 不为空时在 path 中查找外部命令且不使用 hash 表
+func (sh *Gosh) resolve(name string, all, aliases bool, path string) (results []resolution) {
+	var add = func(r resolution) (done bool) {
+		results = append(results, r)
+		return !all
+	}
+
+	// 未启用别名展开时仍然报告定义的别名
+	sh.mutex.RLock()
+	var value, alias = sh.aliases[name]
+	sh.mutex.RUnlock()
+
+	if alias && aliases && add(resolution{kind: "alias", value: value}) {
+		return
+	}
+
+	if slices.Contains(keywords, name) && add(resolution{kind: "keyword"}) {
+		return
+	}
+
+	if sh.Builtin[name] != nil && add(resolution{kind: "builtin"}) {
+		return
+	}
+
+	// 被外部命令遮蔽的 box 命令排在外部命令之后
+	var applet = sh.Command[name] != nil
+	if applet && sh.applet(name) != nil {
+		if add(resolution{kind: "applet"}) {
+			return
+		}
+		applet = false
+	}
+
+	for _, file := range sh.findFiles(name, all, path) {
+		if add(file) {
+			return
+		}
+	}
+
+	if applet {
+		add(resolution{kind: "applet"})
+	}
+
+	return
+}
+
+// findFiles 查找命令对应的文件，path 为空时优先使用 hash 表中记住的路径
+func (sh *Gosh) findFiles(name string, all bool, path string) (results []resolution) {
+	if strings.ContainsRune(name, '/') {
+		if isExecutable(name) {
+			results = append(results, resolution{kind: "file", value: name})
+		}
+		return
+	}
+
+	if path == "" {
+		path = sh.getenv("PATH")
+
+		if entry, ok := sh.hashed(name); ok && (entry.pinned || isExecutable(entry.path)) {
+			if results = append(results, resolution{kind: "file", value: entry.path, hashed: true}); !all {
+				return
+			}
+		}
+	}
+
+	for _, file := range searchPath(path, name, all) {
+		if !slices.ContainsFunc(results, func(r resolution) bool { return r.value == file }) {
+			results = append(results, resolution{kind: "file", value: file})
+		}
+	}
+
+	return
+}
+
+// describe 输出 type 和 command -V 格式的命令说明
+func describe(opt types.Option, name string, r resolution) {
+	switch r.kind {
+	case "alias":
+		_, _ = fmt.Fprintf(opt.Stdout, "%s is aliased to `%s'\n", name, r.value)
+	case "keyword":
+		_, _ = fmt.Fprintf(opt.Stdout, "%s is a shell keyword\n", name)
+	case "builtin":
+		_, _ = fmt.Fprintf(opt.Stdout, "%s is a shell builtin\n", name)
+	case "applet":
+		_, _ = fmt.Fprintf(opt.Stdout, "%s is a gosh applet\n", name)
+	case "file":
+		if r.hashed {
+			_, _ = fmt.Fprintf(opt.Stdout, "%s is hashed (%s)\n", name, r.value)
+		} else {
+			_, _ = fmt.Fprintf(opt.Stdout, "%s is %s\n", name, r.value)
+		}
+	}
+}
+
+// typeof 输出命令名的类型
+func (sh *Gosh) typeof(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, typeUsage)
+		return
+	}
+
+	var all, path, force, word bool
+
+	args, err := parseOptions(args[1:], "afptP", func(option byte, value string) error {
+		switch option {
+		case 'a':
+			all = true
+		case 'p':
+			path = true
+		case 'P':
+			force = true
+		case 't':
+			word = true
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("type: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, typeUsage)
+		return 2
+	}
+
+	for _, name := range args {
+		var results []resolution
+		if force {
+			results = sh.findFiles(name, all, "")
+		} else {
+			results = sh.resolve(name, all, true, "")
+		}
+
+		if len(results) == 0 {
+			if !word && !path && !force {
+				writeError(opt, fmt.Errorf("type: %s: not found", name))
+			}
+			code = 1
+			continue
+		}
+
+		for _, r := range results {
+			switch {
+			case word:
+				_, _ = fmt.Fprintln(opt.Stdout, r.kind)
+			case path || force:
+				if r.kind == "file" {
+					_, _ = fmt.Fprintln(opt.Stdout, r.value)
+				}
+			default:
+				describe(opt, name, r)
+			}
+		}
+	}
+
+	return
+}
+
+// command 跳过别名执行命令，或输出命令的说明
+func (sh *Gosh) command(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, commandUsage)
+		return
+	}
+
+	var path, short, verbose bool
+
+	args, err := parseOptions(args[1:], "pvV", func(option byte, value string) error {
+		switch option {
+		case 'p':
+			path = true
+		case 'v':
+			short = true
+		case 'V':
+			verbose = true
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("command: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, commandUsage)
+		return 2
+	}
+
+	var search string
+	if path {
+		search = defaultPath
+	}
+
+	if short || verbose {
+		for _, name := range args {
+			var results = sh.resolve(name, false, true, search)
+			if len(results) == 0 {
+				if verbose {
+					writeError(opt, fmt.Errorf("command: %s: not found", name))
+				}
+				code = 1
+				continue
+			}
+
+			switch r := results[0]; {
+			case verbose:
+				describe(opt, name, r)
+			case r.kind == "alias":
+				displayAlias(opt, name, r.value)
+			case r.kind == "file":
+				_, _ = fmt.Fprintln(opt.Stdout, r.value)
+			default:
+				_, _ = fmt.Fprintln(opt.Stdout, name)
+			}
+		}
+		return
+	}
+
+	if len(args) == 0 {
+		return
+	}
+
+	// 通过 command 执行的命令同样受限
+	if err = sh.restrict(&Command{}, nil, args); err != nil {
+		sh.writeError(opt, err)
+		return 1
+	}
+
+	// -p 在默认的 PATH 中查找外部命令
+	if r := sh.resolve(args[0], false, false, search); path && len(r) > 0 && r[0].kind == "file" {
+		args = append([]string{r[0].value}, args[1:]...)
+	}
+
+	// 声明命令的参数按未展开的单词处理，已展开的参数需要转义
+	if slices.Contains(declarations, args[0]) {
+		args = slices.Clone(args)
+		for i := 1; i < len(args); i++ {
+			args[i] = escapeWord(args[i])
+		}
+	}
+
+	if code, err = sh.invoke(&Command{}, opt, args, sh.files); err != nil {
+		sh.writeError(opt, err)
+		return 1
+	}
+
+	return
+}
+
+// which 输出 PATH 中命令对应的文件
+func (sh *Gosh) which(opt types.Option, args []string) (code int) {
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		_, _ = fmt.Fprint(opt.Stdout, whichUsage)
+		return
+	}
+
+	var all bool
+
+	args, err := parseOptions(args[1:], "a", func(option byte, value string) error {
+		all = true
+		return nil
+	})
+	if err != nil {
+		writeError(opt, fmt.Errorf("which: %w", err))
+		_, _ = fmt.Fprint(opt.Stderr, whichUsage)
+		return 2
+	}
+
+	for _, name := range args {
+		var paths []string
+		if strings.ContainsRune(name, '/') {
+			if isExecutable(name) {
+				paths = []string{name}
+			}
+		} else {
+			paths = searchPath(sh.getenv("PATH"), name, all)
+		}
+
+		if len(paths) == 0 {
+			code = 1
+		}
+
+		for _, path := range paths {
+			_, _ = fmt.Fprintln(opt.Stdout, path)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandLookup(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+
+	var dir, other = t.TempDir(), t.TempDir()
+
+	// PATH 中与 box 命令同名的 cat 和只存在于 PATH 中的 tool
+	for _, file := range []string{filepath.Join(dir, "cat"), filepath.Join(dir, "tool"), filepath.Join(other, "tool")} {
+		var script = "#!/bin/sh\necho external " + filepath.Base(file) + "\n"
+		if err := os.WriteFile(file, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var replacer = strings.NewReplacer("DIR", dir, "OTHER", other)
+
+	var tests = []struct {
+		script   string
+		expected string
+	}{
+		{
+			script:   "type cd time cat tool nope; echo $?",
+			expected: "cd is a shell builtin\ntime is a shell keyword\ncat is a gosh applet\ntool is DIR/tool\nshell: type: nope: not found\n1\n",
+		},
+		{
+			script:   "type -t cd cat tool; type -a cat; type -P cat; type -p cat",
+			expected: "builtin\napplet\nfile\ncat is a gosh applet\ncat is DIR/cat\nDIR/cat\n",
+		},
+		{
+			script:   "tool; tool; hash; hash -t tool; type tool",
+			expected: "external tool\nexternal tool\nhits\tcommand\n   2\tDIR/tool\nDIR/tool\ntool is hashed (DIR/tool)\n",
+		},
+		{
+			script:   "tool; PATH=OTHER:DIR; hash; tool; hash -r; hash",
+			expected: "external tool\nhash: hash table empty\nexternal tool\nhash: hash table empty\n",
+		},
+		{
+			script:   "hash -p DIR/cat cat; cat; type cat; hash -l; hash -d cat; cat; hash -t cat; echo $?",
+			expected: "external cat\ncat is hashed (DIR/cat)\nhash -p DIR/cat cat\nshell: hash: cat: not found\n1\n",
+		},
+		{
+			script:   "shopt -s prefer_external; cat; type -t cat; command -v cat; shopt -u prefer_external; echo x | cat; command -v cat",
+			expected: "external cat\nfile\nDIR/cat\nx\ncat\n",
+		},
+		{
+			script:   "shopt -s expand_aliases; alias ll=ls\ncommand -v ll; command -V cd; command -v nope; echo $?; command echo hi",
+			expected: "alias ll='ls'\ncd is a shell builtin\n1\nhi\n",
+		},
+		{
+			// 未启用别名展开时仍然报告别名
+			script:   "alias ll='ls -l'; type ll; type -t ll; command -v ll; command -V ll",
+			expected: "ll is aliased to `ls -l'\nalias\nalias ll='ls -l'\nll is aliased to `ls -l'\n",
+		},
+		{
+			script:   "command tool a; command nope; echo $?",
+			expected: "external tool\nshell: nope: command not found\n127\n",
+		},
+		{
+			script:   "which cat tool nope; echo $?; which -a tool",
+			expected: "DIR/cat\nDIR/tool\n1\nDIR/tool\nOTHER/tool\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			var sh, stdout = newTestGosh()
+			_ = sh.setvar("PATH", dir+string(filepath.ListSeparator)+other)
+
+			if _, err := sh.Run(strings.NewReader(replacer.Replace(test.script)), sh.Option); err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := replacer.Replace(test.expected); stdout.String() != expected {
+				t.Errorf("expected: %q, got: %q", expected, stdout.String())
+			}
+		})
+	}
+}
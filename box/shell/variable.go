@@ -0,0 +1,271 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Variable shell 变量
+type Variable struct {
+	Value  string            // 变量值
+	Array  []string          // 索引数组，非 nil 时为数组变量
+	Index  []int             // 索引数组元素的下标，nil 时下标从 0 连续
+	Assoc  map[string]string // 关联数组，非 nil 时为关联数组变量
+	Export bool              // 是否导出到命令的环境变量
+}
+
+// String 变量的字符串值，数组变量取下标为 0 的元素
+func (v *Variable) String() string {
+	if v.Assoc != nil {
+		return v.Assoc["0"]
+	}
+
+	var value, _ = v.element(0)
+
+	return value
+}
+
+// isName 是否为合法的变量名
+func isName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		var c = name[i]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+
+	return true
+}
+
+// splitAssign 拆分单词开头的变量赋值 `name=value`
+func splitAssign(words []string) (assigns, args []string) {
+	for i, word := range words {
+		if name, _, ok := strings.Cut(word, "="); !ok || !isAssignName(name) {
+			return words[:i], words[i:]
+		}
+	}
+
+	return words, nil
+}
+
+// initVars 使用环境变量初始化 shell 变量
+func (sh *Gosh) initVars(env []string) {
+	if env == nil {
+		env = os.Environ()
+	}
+
+	sh.vars = make(map[string]*Variable, len(env))
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok && isName(name) {
+			sh.vars[name] = &Variable{Value: value, Export: true}
+		}
+	}
+}
+
+// param 获取特殊参数和位置参数
+func (sh *Gosh) param(name string) (value string, set bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(sh.status), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "#":
+		return strconv.Itoa(len(sh.args)), true
+	case "@", "*":
+		return strings.Join(sh.args, " "), true
+	case "0":
+		return sh.name, true
+	}
+
+	var index, err = strconv.Atoi(name)
+	if err != nil || index < 1 || index > len(sh.args) {
+		return "", false
+	}
+
+	return sh.args[index-1], true
+}
+
+// setArgs 设置位置参数，getopts 重新从参数开头解析当前参数
+func (sh *Gosh) setArgs(args []string) {
+	sh.args, sh.optpos = args, 0
+}
+
+// lookup 获取变量的值，包括特殊参数、位置参数和数组元素 name[subscript]
+func (sh *Gosh) lookup(name string) (value string, set bool) {
+	if base, sub, ok := parseSubscript(name); ok {
+		return sh.lookupElement(base, sub)
+	}
+
+	if !isName(name) {
+		return sh.param(name)
+	}
+
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	var v = sh.vars[name]
+	if v == nil {
+		return "", false
+	}
+
+	return v.String(), true
+}
+
+// varNames 以 prefix 开头的变量名，按名称排序
+func (sh *Gosh) varNames(prefix string) (names []string) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	for name := range sh.vars {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return
+}
+
+// getenv 获取 shell 变量的值
+func (sh *Gosh) getenv(name string) string {
+	var value, _ = sh.lookup(name)
+	return value
+}
+
+// setvar 设置 shell 变量，已导出的变量保持导出，数组变量设置下标为 0 的元素
+func (sh *Gosh) setvar(name, value string) (err error) {
+	if !isName(name) {
+		return fmt.Errorf("`%s': not a valid identifier", name)
+	}
+
+	if err = sh.restrictVar(name); err != nil {
+		return
+	}
+
+	// 修改 OPTIND 时 getopts 重新从参数开头解析
+	if name == "OPTIND" {
+		sh.optpos = 0
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	switch v := sh.vars[name]; {
+	case v == nil:
+		sh.vars[name] = &Variable{Value: value}
+	case v.Assoc != nil:
+		v.Assoc["0"] = value
+	case v.Array != nil:
+		v.setElement(0, value)
+	default:
+		v.Value = value
+	}
+
+	return
+}
+
+// unsetvar 删除 shell 变量
+func (sh *Gosh) unsetvar(name string) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	delete(sh.vars, name)
+}
+
+// setArray 设置索引数组变量
+func (sh *Gosh) setArray(name string, values []string) (err error) {
+	if !isName(name) {
+		return fmt.Errorf("`%s': not a valid identifier", name)
+	}
+
+	if err = sh.restrictVar(name); err != nil {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if values == nil {
+		values = []string{}
+	}
+
+	if v := sh.vars[name]; v != nil {
+		v.Value, v.Array, v.Index, v.Assoc = "", values, nil, nil
+		return
+	}
+
+	sh.vars[name] = &Variable{Array: values}
+
+	return
+}
+
+// assign 执行变量赋值，export 为 true 时同时导出，返回恢复原值的函数
+func (sh *Gosh) assign(assigns []string, export bool) (restore func(), err error) {
+	var saved = make(map[string]*Variable, len(assigns))
+
+	restore = func() {
+		sh.mutex.Lock()
+		defer sh.mutex.Unlock()
+
+		for name, v := range saved {
+			if v == nil {
+				delete(sh.vars, name)
+			} else {
+				sh.vars[name] = v
+			}
+		}
+	}
+
+	for _, assign := range assigns {
+		var left, word, _ = strings.Cut(assign, "=")
+		var name = assignBase(left)
+
+		sh.mutex.Lock()
+		if _, exists := saved[name]; !exists {
+			if v := sh.vars[name]; v != nil {
+				saved[name] = v.clone()
+			} else {
+				saved[name] = nil
+			}
+		}
+		sh.mutex.Unlock()
+
+		if err = sh.assignVar(left, word); err != nil {
+			return
+		}
+
+		if export {
+			sh.mutex.Lock()
+			sh.vars[name].Export = true
+			sh.mutex.Unlock()
+		}
+	}
+
+	return
+}
+
+// environ 导出变量组成的环境变量
+func (sh *Gosh) environ() []string {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	var env = make([]string, 0, len(sh.vars))
+	for name, v := range sh.vars {
+		if v.Export {
+			env = append(env, name+"="+v.String())
+		}
+	}
+
+	slices.Sort(env)
+
+	return env
+}
@@ -0,0 +1,37 @@
+package shell
+
+import "unicode"
+
+// wideRanges 东亚宽字符和表情符号，在终端中占两列
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// runeWidth 字符在终端中占的列数，控制字符和组合字符不占列
+func runeWidth(r rune) int {
+	if r < 0x20 || r >= 0x7F && r < 0xA0 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+
+	for _, rng := range wideRanges {
+		if r >= rng[0] && r <= rng[1] {
+			return 2
+		}
+	}
+
+	return 1
+}
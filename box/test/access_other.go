@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package test
+
+import "os"
+
+// access 按文件的权限位检查文件是否可读（4）、可写（2）或可执行（1）
+func access(path string, mode uint32) bool {
+	var info, err = os.Stat(path)
+
+	return err == nil && uint32(info.Mode().Perm())&(mode*0111) != 0
+}
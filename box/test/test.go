@@ -1,29 +1,426 @@
 package test
 
 import (
+	"errors"
 	"fmt"
-	"time"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/zooyer/gobox/box"
 	"github.com/zooyer/gobox/types"
 )
 
+const usage = `Usage: test EXPRESSION
+  or:  test
+  or:  [ EXPRESSION ]
+  or:  [ ]
+Exit with the status determined by EXPRESSION.
+
+An omitted EXPRESSION defaults to false.  Otherwise,
+EXPRESSION is true or false and sets exit status.  It is one of:
+
+  ( EXPRESSION )               EXPRESSION is true
+  ! EXPRESSION                 EXPRESSION is false
+  EXPRESSION1 -a EXPRESSION2   both EXPRESSION1 and EXPRESSION2 are true
+  EXPRESSION1 -o EXPRESSION2   either EXPRESSION1 or EXPRESSION2 is true
+
+  -n STRING            the length of STRING is nonzero
+  STRING               equivalent to -n STRING
+  -z STRING            the length of STRING is zero
+  STRING1 = STRING2    the strings are equal
+  STRING1 != STRING2   the strings are not equal
+  STRING1 < STRING2    STRING1 sorts before STRING2
+  STRING1 > STRING2    STRING1 sorts after STRING2
+
+  INTEGER1 -eq INTEGER2   INTEGER1 is equal to INTEGER2
+  INTEGER1 -ge INTEGER2   INTEGER1 is greater than or equal to INTEGER2
+  INTEGER1 -gt INTEGER2   INTEGER1 is greater than INTEGER2
+  INTEGER1 -le INTEGER2   INTEGER1 is less than or equal to INTEGER2
+  INTEGER1 -lt INTEGER2   INTEGER1 is less than INTEGER2
+  INTEGER1 -ne INTEGER2   INTEGER1 is not equal to INTEGER2
+
+  FILE1 -ef FILE2   FILE1 and FILE2 have the same device and inode numbers
+  FILE1 -nt FILE2   FILE1 is newer (modification date) than FILE2
+  FILE1 -ot FILE2   FILE1 is older than FILE2
+
+  -b FILE     FILE exists and is block special
+  -c FILE     FILE exists and is character special
+  -d FILE     FILE exists and is a directory
+  -e FILE     FILE exists
+  -f FILE     FILE exists and is a regular file
+  -g FILE     FILE exists and is set-group-ID
+  -h FILE     FILE exists and is a symbolic link (same as -L)
+  -k FILE     FILE exists and has its sticky bit set
+  -L FILE     FILE exists and is a symbolic link (same as -h)
+  -p FILE     FILE exists and is a named pipe
+  -r FILE     FILE exists and the user has read access
+  -s FILE     FILE exists and has a size greater than zero
+  -S FILE     FILE exists and is a socket
+  -t FD       file descriptor FD is opened on a terminal
+  -u FILE     FILE exists and its set-user-ID bit is set
+  -w FILE     FILE exists and the user has write access
+  -x FILE     FILE exists and the user has execute (or search) access
+
+Exit status is 0 if EXPRESSION is true, 1 if false, 2 if an error occurred.
+`
+
+// unary 单目运算符
+var unary = []string{"-b", "-c", "-d", "-e", "-f", "-g", "-h", "-k", "-L", "-n", "-p", "-r", "-s", "-S", "-t", "-u", "-w", "-x", "-z"}
+
+// binary 双目运算符
+var binary = []string{"=", "==", "!=", "<", ">", "-eq", "-ne", "-lt", "-le", "-gt", "-ge", "-nt", "-ot", "-ef", "-a", "-o"}
+
 type Test struct {
 	box.Process
 }
 
+func writeError(opt types.Option, name string, err error) {
+	_, _ = fmt.Fprintf(opt.Stderr, "%s: %v\n", name, err)
+}
+
+// FileTest 文件测试，-h 和 -L 不跟随符号链接
+func FileTest(op, path string) bool {
+	if op == "-h" || op == "-L" {
+		var info, err = os.Lstat(path)
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	}
+
+	var info, err = os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	var mode = info.Mode()
+	switch op {
+	case "-a", "-e":
+		return true
+	case "-f":
+		return mode.IsRegular()
+	case "-d":
+		return mode.IsDir()
+	case "-s":
+		return info.Size() > 0
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+	case "-c":
+		return mode&os.ModeCharDevice != 0
+	case "-p":
+		return mode&os.ModeNamedPipe != 0
+	case "-S":
+		return mode&os.ModeSocket != 0
+	case "-g":
+		return mode&os.ModeSetgid != 0
+	case "-u":
+		return mode&os.ModeSetuid != 0
+	case "-k":
+		return mode&os.ModeSticky != 0
+	case "-r":
+		return access(path, 4)
+	case "-w":
+		return access(path, 2)
+	case "-x":
+		return access(path, 1)
+	}
+
+	return false
+}
+
+// FileCompare 比较文件：-nt 修改时间较新，-ot 修改时间较旧，-ef 为同一个文件
+func FileCompare(left, op, right string) bool {
+	var a, errA = os.Stat(left)
+	var b, errB = os.Stat(right)
+
+	switch op {
+	case "-nt":
+		return errA == nil && (errB != nil || a.ModTime().After(b.ModTime()))
+	case "-ot":
+		return errB == nil && (errA != nil || a.ModTime().Before(b.ModTime()))
+	case "-ef":
+		return errA == nil && errB == nil && os.SameFile(a, b)
+	}
+
+	return false
+}
+
+// errSyntax 表达式的语法错误
+var errSyntax = errors.New("syntax error")
+
+// expr 表达式的计算
+type expr struct {
+	opt  types.Option
+	args []string
+	pos  int
+}
+
+// path 文件路径，相对路径基于工作目录
+func (e *expr) path(name string) string {
+	if e.opt.Dir != "" && !filepath.IsAbs(name) {
+		return filepath.Join(e.opt.Dir, name)
+	}
+
+	return name
+}
+
+// terminal 文件描述符 0、1、2 对应的输入输出是否为终端
+func (e *expr) terminal(fd int64) bool {
+	var streams = []any{e.opt.Stdin, e.opt.Stdout, e.opt.Stderr}
+	if fd < 0 || fd >= int64(len(streams)) {
+		return false
+	}
+
+	var file, ok = streams[fd].(*os.File)
+	if !ok {
+		return false
+	}
+
+	var info, err = file.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// integer 解析整数操作数
+func integer(s string) (n int64, err error) {
+	if n, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64); err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+
+	return
+}
+
+// unary 计算单目运算
+func (e *expr) unary(op, operand string) (bool, error) {
+	switch op {
+	case "-n":
+		return operand != "", nil
+	case "-z":
+		return operand == "", nil
+	case "-t":
+		var fd, err = integer(operand)
+		return err == nil && e.terminal(fd), err
+	}
+
+	return FileTest(op, e.path(operand)), nil
+}
+
+// binary 计算双目运算
+func (e *expr) binary(left, op, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-a":
+		return left != "" && right != "", nil
+	case "-o":
+		return left != "" || right != "", nil
+	case "-nt", "-ot", "-ef":
+		return FileCompare(e.path(left), op, e.path(right)), nil
+	}
+
+	var a, b int64
+	var err error
+	if a, err = integer(left); err != nil {
+		return false, err
+	}
+	if b, err = integer(right); err != nil {
+		return false, err
+	}
+
+	switch op {
+	case "-eq":
+		return a == b, nil
+	case "-ne":
+		return a != b, nil
+	case "-lt":
+		return a < b, nil
+	case "-le":
+		return a <= b, nil
+	case "-gt":
+		return a > b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+// eval 按 POSIX 的参数个数规则计算 args，超过 4 个参数时按优先级解析
+func (e *expr) eval(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		switch {
+		case args[0] == "!":
+			var result, err = e.eval(args[1:])
+			return !result, err
+		case slices.Contains(unary, args[0]):
+			return e.unary(args[0], args[1])
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		switch {
+		case slices.Contains(binary, args[1]):
+			return e.binary(args[0], args[1], args[2])
+		case args[0] == "!":
+			var result, err = e.eval(args[1:])
+			return !result, err
+		case args[0] == "(" && args[2] == ")":
+			return e.eval(args[1:2])
+		}
+		return false, fmt.Errorf("%s: binary operator expected", args[1])
+	case 4:
+		switch {
+		case args[0] == "!":
+			var result, err = e.eval(args[1:])
+			return !result, err
+		case args[0] == "(" && args[3] == ")":
+			return e.eval(args[1:3])
+		}
+	}
+
+	e.args, e.pos = args, 0
+
+	var result, err = e.or()
+	if err == nil && e.pos < len(e.args) {
+		err = fmt.Errorf("%s: %w", e.args[e.pos], errSyntax)
+	}
+
+	return result, err
+}
+
+// peek 下一个参数
+func (e *expr) peek() (arg string, ok bool) {
+	if e.pos < len(e.args) {
+		return e.args[e.pos], true
+	}
+
+	return
+}
+
+// or expr -o expr
+func (e *expr) or() (result bool, err error) {
+	if result, err = e.and(); err != nil {
+		return
+	}
+
+	for arg, ok := e.peek(); ok && arg == "-o"; arg, ok = e.peek() {
+		e.pos++
+
+		var right bool
+		if right, err = e.and(); err != nil {
+			return
+		}
+		result = result || right
+	}
+
+	return
+}
+
+// and expr -a expr
+func (e *expr) and() (result bool, err error) {
+	if result, err = e.not(); err != nil {
+		return
+	}
+
+	for arg, ok := e.peek(); ok && arg == "-a"; arg, ok = e.peek() {
+		e.pos++
+
+		var right bool
+		if right, err = e.not(); err != nil {
+			return
+		}
+		result = result && right
+	}
+
+	return
+}
+
+// not ! expr
+func (e *expr) not() (result bool, err error) {
+	if arg, ok := e.peek(); ok && arg == "!" {
+		e.pos++
+		result, err = e.not()
+		return !result, err
+	}
+
+	return e.primary()
+}
+
+// primary ( expr )、单目运算、双目运算或单个字符串
+func (e *expr) primary() (result bool, err error) {
+	var arg, ok = e.peek()
+	if !ok {
+		return false, fmt.Errorf("argument expected")
+	}
+	e.pos++
+
+	// 之后的参数为双目运算符时，arg 为左侧的操作数
+	if op, ok := e.peek(); ok && slices.Contains(binary, op) && op != "-a" && op != "-o" && e.pos+1 < len(e.args) {
+		e.pos += 2
+		return e.binary(arg, op, e.args[e.pos-1])
+	}
+
+	switch {
+	case arg == "(":
+		if result, err = e.or(); err != nil {
+			return
+		}
+		if next, ok := e.peek(); !ok || next != ")" {
+			return false, errors.New("')' expected")
+		}
+		e.pos++
+		return
+	case slices.Contains(unary, arg) && e.pos < len(e.args):
+		e.pos++
+		return e.unary(arg, e.args[e.pos-1])
+	}
+
+	return arg != "", nil
+}
+
 func (t *Test) Main(args []string) (code int) {
 	t.Start()
 	defer t.Stop()
 
-	fmt.Println("args:", args)
+	var name = "test"
+	if len(args) > 0 {
+		name, args = filepath.Base(args[0]), args[1:]
+	}
+
+	if name == "[" {
+		if len(args) == 1 && (args[0] == "--help" || args[0] == "--version") {
+			_, _ = fmt.Fprint(t.Option.Stdout, usage)
+			return
+		}
+
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			writeError(t.Option, name, errors.New("missing `]'"))
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+
+	var e = &expr{opt: t.Option}
+
+	var result, err = e.eval(args)
+	if err != nil {
+		writeError(t.Option, name, err)
+		return 2
+	}
 
-	for i := 0; i < 10; i++ {
-		fmt.Println("i :", i)
-		time.Sleep(1 * time.Second)
+	if !result {
+		return 1
 	}
 
-	return 0
+	return
 }
 
 func New(option types.Option) types.Process {
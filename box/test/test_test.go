@@ -1,6 +1,10 @@
 package test
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/zooyer/gobox/types"
@@ -20,3 +24,63 @@ func TestNew(t *testing.T) {
 	test.Kill()
 	test.Wait()
 }
+
+func TestTest(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		args     []string
+		expected int
+		stderr   string
+	}{
+		{args: []string{"test"}, expected: 1},
+		{args: []string{"test", ""}, expected: 1},
+		{args: []string{"test", "-n"}, expected: 0},
+		{args: []string{"test", "!", ""}, expected: 0},
+		{args: []string{"test", "-z", ""}, expected: 0},
+		{args: []string{"test", "!", "=", "x"}, expected: 1},
+		{args: []string{"test", "-a", "=", "-a"}, expected: 0},
+		{args: []string{"test", "(", "x", ")"}, expected: 0},
+		{args: []string{"test", "!", "a", "=", "a"}, expected: 1},
+		{args: []string{"test", "(", "-z", "x", ")"}, expected: 1},
+		{args: []string{"test", "a", "<", "b"}, expected: 0},
+		{args: []string{"test", "10", "-gt", "9"}, expected: 0},
+		{args: []string{"test", " 1", "-eq", "1 "}, expected: 0},
+		{args: []string{"test", "-f", "file", "-a", "-L", "link", "-a", "!", "-d", "file"}, expected: 0},
+		{args: []string{"test", "-d", "file", "-o", "-s", "file"}, expected: 0},
+		{args: []string{"test", "x", "-o", "", "-a", ""}, expected: 0},
+		{args: []string{"test", "(", "x", "-o", "", ")", "-a", ""}, expected: 1},
+		{args: []string{"test", "link", "-ef", "file", "-a", "file", "-ef", "file"}, expected: 0},
+		{args: []string{"test", "-e", "nope"}, expected: 1},
+		{args: []string{"test", "-t", "0"}, expected: 1},
+		{args: []string{"[", "a", "=", "a", "]"}, expected: 0},
+		{args: []string{"/usr/bin/[", "]"}, expected: 1},
+		{args: []string{"[", "a"}, expected: 2, stderr: "[: missing `]'\n"},
+		{args: []string{"test", "1", "-eq", "a"}, expected: 2, stderr: "test: a: integer expression expected\n"},
+		{args: []string{"test", "a", "b"}, expected: 2, stderr: "test: a: unary operator expected\n"},
+		{args: []string{"test", "a", "b", "c"}, expected: 2, stderr: "test: b: binary operator expected\n"},
+		{args: []string{"test", "(", "a", "-a", "b"}, expected: 2, stderr: "test: ')' expected\n"},
+		{args: []string{"test", "a", "-a", "b", "c", "d"}, expected: 2, stderr: "test: c: syntax error\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			var stderr bytes.Buffer
+
+			var code = New(types.Option{Dir: dir, Stdin: strings.NewReader(""), Stderr: &stderr}).Main(test.args)
+			if code != test.expected {
+				t.Errorf("expected: %d, got: %d", test.expected, code)
+			}
+
+			if stderr.String() != test.stderr {
+				t.Errorf("expected stderr: %q, got: %q", test.stderr, stderr.String())
+			}
+		})
+	}
+}
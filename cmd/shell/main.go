@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"os/signal"
 
 	"github.com/zooyer/gobox/box/shell"
 	"github.com/zooyer/gobox/types"
@@ -16,5 +17,21 @@ func main() {
 		Stderr: os.Stderr,
 	}
 
-	os.Exit(shell.NewGosh(opt).Main(os.Args))
+	var (
+		sh      = shell.NewGosh(opt)
+		signals = make(chan os.Signal, 8)
+	)
+
+	// 将进程收到的信号转发给 shell，Signals 不包括 TSTP，执行脚本时按默认方式暂停
+	signal.Notify(signals, shell.Signals...)
+	go func() {
+		for sig := range signals {
+			sh.Signal(sig)
+		}
+	}()
+
+	var code = sh.Main(os.Args)
+	signal.Stop(signals)
+
+	os.Exit(code)
 }